@@ -0,0 +1,741 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.28.1
+// 	protoc        (unknown)
+// source: commands/user/v1/user.proto
+
+package v1
+
+import (
+	v1 "github.com/jacktantram/user-service/build/go/shared/user/v1"
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Status the status of a processed command.
+type UserCommandResult_Status int32
+
+const (
+	UserCommandResult_STATUS_UNSPECIFIED UserCommandResult_Status = 0
+	// The command was applied.
+	UserCommandResult_STATUS_SUCCEEDED UserCommandResult_Status = 1
+	// The command was rejected and will not be retried.
+	UserCommandResult_STATUS_FAILED UserCommandResult_Status = 2
+)
+
+// Enum value maps for UserCommandResult_Status.
+var (
+	UserCommandResult_Status_name = map[int32]string{
+		0: "STATUS_UNSPECIFIED",
+		1: "STATUS_SUCCEEDED",
+		2: "STATUS_FAILED",
+	}
+	UserCommandResult_Status_value = map[string]int32{
+		"STATUS_UNSPECIFIED": 0,
+		"STATUS_SUCCEEDED":   1,
+		"STATUS_FAILED":      2,
+	}
+)
+
+func (x UserCommandResult_Status) Enum() *UserCommandResult_Status {
+	p := new(UserCommandResult_Status)
+	*p = x
+	return p
+}
+
+func (x UserCommandResult_Status) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (UserCommandResult_Status) Descriptor() protoreflect.EnumDescriptor {
+	return file_commands_user_v1_user_proto_enumTypes[0].Descriptor()
+}
+
+func (UserCommandResult_Status) Type() protoreflect.EnumType {
+	return &file_commands_user_v1_user_proto_enumTypes[0]
+}
+
+func (x UserCommandResult_Status) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use UserCommandResult_Status.Descriptor instead.
+func (UserCommandResult_Status) EnumDescriptor() ([]byte, []int) {
+	return file_commands_user_v1_user_proto_rawDescGZIP(), []int{5, 0}
+}
+
+// UserCommand requests a change to a user asynchronously.
+type UserCommand struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Unique ID of the command, used as the idempotency key. A redelivered command with the same ID is only applied once.
+	CommandId string `protobuf:"bytes,1,opt,name=command_id,json=commandId,proto3" json:"command_id,omitempty"`
+	// The change to make.
+	//
+	// Types that are assignable to Command:
+	//	*UserCommand_Create
+	//	*UserCommand_Update
+	//	*UserCommand_Delete
+	//	*UserCommand_Erase
+	Command isUserCommand_Command `protobuf_oneof:"command"`
+}
+
+func (x *UserCommand) Reset() {
+	*x = UserCommand{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_commands_user_v1_user_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *UserCommand) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UserCommand) ProtoMessage() {}
+
+func (x *UserCommand) ProtoReflect() protoreflect.Message {
+	mi := &file_commands_user_v1_user_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UserCommand.ProtoReflect.Descriptor instead.
+func (*UserCommand) Descriptor() ([]byte, []int) {
+	return file_commands_user_v1_user_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *UserCommand) GetCommandId() string {
+	if x != nil {
+		return x.CommandId
+	}
+	return ""
+}
+
+func (m *UserCommand) GetCommand() isUserCommand_Command {
+	if m != nil {
+		return m.Command
+	}
+	return nil
+}
+
+func (x *UserCommand) GetCreate() *CreateUserCommand {
+	if x, ok := x.GetCommand().(*UserCommand_Create); ok {
+		return x.Create
+	}
+	return nil
+}
+
+func (x *UserCommand) GetUpdate() *UpdateUserCommand {
+	if x, ok := x.GetCommand().(*UserCommand_Update); ok {
+		return x.Update
+	}
+	return nil
+}
+
+func (x *UserCommand) GetDelete() *DeleteUserCommand {
+	if x, ok := x.GetCommand().(*UserCommand_Delete); ok {
+		return x.Delete
+	}
+	return nil
+}
+
+func (x *UserCommand) GetErase() *EraseUserCommand {
+	if x, ok := x.GetCommand().(*UserCommand_Erase); ok {
+		return x.Erase
+	}
+	return nil
+}
+
+type isUserCommand_Command interface {
+	isUserCommand_Command()
+}
+
+type UserCommand_Create struct {
+	Create *CreateUserCommand `protobuf:"bytes,2,opt,name=create,proto3,oneof"`
+}
+
+type UserCommand_Update struct {
+	Update *UpdateUserCommand `protobuf:"bytes,3,opt,name=update,proto3,oneof"`
+}
+
+type UserCommand_Delete struct {
+	Delete *DeleteUserCommand `protobuf:"bytes,4,opt,name=delete,proto3,oneof"`
+}
+
+type UserCommand_Erase struct {
+	Erase *EraseUserCommand `protobuf:"bytes,5,opt,name=erase,proto3,oneof"`
+}
+
+func (*UserCommand_Create) isUserCommand_Command() {}
+
+func (*UserCommand_Update) isUserCommand_Command() {}
+
+func (*UserCommand_Delete) isUserCommand_Command() {}
+
+func (*UserCommand_Erase) isUserCommand_Command() {}
+
+// CreateUserCommand creates a user.
+type CreateUserCommand struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The user to create, the ID is generated.
+	User *v1.User `protobuf:"bytes,1,opt,name=user,proto3" json:"user,omitempty"`
+}
+
+func (x *CreateUserCommand) Reset() {
+	*x = CreateUserCommand{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_commands_user_v1_user_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CreateUserCommand) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateUserCommand) ProtoMessage() {}
+
+func (x *CreateUserCommand) ProtoReflect() protoreflect.Message {
+	mi := &file_commands_user_v1_user_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateUserCommand.ProtoReflect.Descriptor instead.
+func (*CreateUserCommand) Descriptor() ([]byte, []int) {
+	return file_commands_user_v1_user_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *CreateUserCommand) GetUser() *v1.User {
+	if x != nil {
+		return x.User
+	}
+	return nil
+}
+
+// UpdateUserCommand updates a user.
+type UpdateUserCommand struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The user to update.
+	User *v1.User `protobuf:"bytes,1,opt,name=user,proto3" json:"user,omitempty"`
+	// The fields to update.
+	UpdateFields []v1.UpdateUserField `protobuf:"varint,2,rep,packed,name=update_fields,json=updateFields,proto3,enum=shared.user.v1.UpdateUserField" json:"update_fields,omitempty"`
+	// The etag of the user the update is based on. When set the command fails if the user has changed since.
+	Etag string `protobuf:"bytes,3,opt,name=etag,proto3" json:"etag,omitempty"`
+}
+
+func (x *UpdateUserCommand) Reset() {
+	*x = UpdateUserCommand{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_commands_user_v1_user_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *UpdateUserCommand) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateUserCommand) ProtoMessage() {}
+
+func (x *UpdateUserCommand) ProtoReflect() protoreflect.Message {
+	mi := &file_commands_user_v1_user_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateUserCommand.ProtoReflect.Descriptor instead.
+func (*UpdateUserCommand) Descriptor() ([]byte, []int) {
+	return file_commands_user_v1_user_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *UpdateUserCommand) GetUser() *v1.User {
+	if x != nil {
+		return x.User
+	}
+	return nil
+}
+
+func (x *UpdateUserCommand) GetUpdateFields() []v1.UpdateUserField {
+	if x != nil {
+		return x.UpdateFields
+	}
+	return nil
+}
+
+func (x *UpdateUserCommand) GetEtag() string {
+	if x != nil {
+		return x.Etag
+	}
+	return ""
+}
+
+// DeleteUserCommand deletes a user.
+type DeleteUserCommand struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The ID of the user to delete.
+	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// The etag of the user expected to be deleted. When set the command fails if the user has changed since.
+	Etag string `protobuf:"bytes,2,opt,name=etag,proto3" json:"etag,omitempty"`
+}
+
+func (x *DeleteUserCommand) Reset() {
+	*x = DeleteUserCommand{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_commands_user_v1_user_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DeleteUserCommand) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteUserCommand) ProtoMessage() {}
+
+func (x *DeleteUserCommand) ProtoReflect() protoreflect.Message {
+	mi := &file_commands_user_v1_user_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteUserCommand.ProtoReflect.Descriptor instead.
+func (*DeleteUserCommand) Descriptor() ([]byte, []int) {
+	return file_commands_user_v1_user_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *DeleteUserCommand) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *DeleteUserCommand) GetEtag() string {
+	if x != nil {
+		return x.Etag
+	}
+	return ""
+}
+
+// EraseUserCommand erases a user's personal data, i.e. for a GDPR erasure request.
+// The user is deleted and the published deleted event only carries the user ID.
+type EraseUserCommand struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The ID of the user to erase.
+	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+}
+
+func (x *EraseUserCommand) Reset() {
+	*x = EraseUserCommand{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_commands_user_v1_user_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *EraseUserCommand) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EraseUserCommand) ProtoMessage() {}
+
+func (x *EraseUserCommand) ProtoReflect() protoreflect.Message {
+	mi := &file_commands_user_v1_user_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EraseUserCommand.ProtoReflect.Descriptor instead.
+func (*EraseUserCommand) Descriptor() ([]byte, []int) {
+	return file_commands_user_v1_user_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *EraseUserCommand) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+// UserCommandResult the outcome of a command, published once the command has been processed.
+// A redelivered command publishes the original result again.
+type UserCommandResult struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The ID of the command.
+	CommandId string `protobuf:"bytes,1,opt,name=command_id,json=commandId,proto3" json:"command_id,omitempty"`
+	// Whether the command was applied.
+	Status UserCommandResult_Status `protobuf:"varint,2,opt,name=status,proto3,enum=commands.user.v1.UserCommandResult_Status" json:"status,omitempty"`
+	// The user after a create or update command.
+	User *v1.User `protobuf:"bytes,3,opt,name=user,proto3" json:"user,omitempty"`
+	// Machine readable reason the command failed, i.e. EMAIL_ALREADY_EXISTS.
+	Reason string `protobuf:"bytes,4,opt,name=reason,proto3" json:"reason,omitempty"`
+	// Human readable description of the failure.
+	Error string `protobuf:"bytes,5,opt,name=error,proto3" json:"error,omitempty"`
+	// The date the command was processed.
+	ProcessedAt *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=processed_at,json=processedAt,proto3" json:"processed_at,omitempty"`
+}
+
+func (x *UserCommandResult) Reset() {
+	*x = UserCommandResult{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_commands_user_v1_user_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *UserCommandResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UserCommandResult) ProtoMessage() {}
+
+func (x *UserCommandResult) ProtoReflect() protoreflect.Message {
+	mi := &file_commands_user_v1_user_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UserCommandResult.ProtoReflect.Descriptor instead.
+func (*UserCommandResult) Descriptor() ([]byte, []int) {
+	return file_commands_user_v1_user_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *UserCommandResult) GetCommandId() string {
+	if x != nil {
+		return x.CommandId
+	}
+	return ""
+}
+
+func (x *UserCommandResult) GetStatus() UserCommandResult_Status {
+	if x != nil {
+		return x.Status
+	}
+	return UserCommandResult_STATUS_UNSPECIFIED
+}
+
+func (x *UserCommandResult) GetUser() *v1.User {
+	if x != nil {
+		return x.User
+	}
+	return nil
+}
+
+func (x *UserCommandResult) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *UserCommandResult) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *UserCommandResult) GetProcessedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ProcessedAt
+	}
+	return nil
+}
+
+var File_commands_user_v1_user_proto protoreflect.FileDescriptor
+
+var file_commands_user_v1_user_proto_rawDesc = []byte{
+	0x0a, 0x1b, 0x63, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x73, 0x2f, 0x75, 0x73, 0x65, 0x72, 0x2f,
+	0x76, 0x31, 0x2f, 0x75, 0x73, 0x65, 0x72, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x10, 0x63,
+	0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x73, 0x2e, 0x75, 0x73, 0x65, 0x72, 0x2e, 0x76, 0x31, 0x1a,
+	0x1f, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66,
+	0x2f, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x1a, 0x19, 0x73, 0x68, 0x61, 0x72, 0x65, 0x64, 0x2f, 0x75, 0x73, 0x65, 0x72, 0x2f, 0x76, 0x31,
+	0x2f, 0x75, 0x73, 0x65, 0x72, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0xb0, 0x02, 0x0a, 0x0b,
+	0x55, 0x73, 0x65, 0x72, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x12, 0x1d, 0x0a, 0x0a, 0x63,
+	0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x09, 0x63, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x49, 0x64, 0x12, 0x3d, 0x0a, 0x06, 0x63, 0x72,
+	0x65, 0x61, 0x74, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x23, 0x2e, 0x63, 0x6f, 0x6d,
+	0x6d, 0x61, 0x6e, 0x64, 0x73, 0x2e, 0x75, 0x73, 0x65, 0x72, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x72,
+	0x65, 0x61, 0x74, 0x65, 0x55, 0x73, 0x65, 0x72, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x48,
+	0x00, 0x52, 0x06, 0x63, 0x72, 0x65, 0x61, 0x74, 0x65, 0x12, 0x3d, 0x0a, 0x06, 0x75, 0x70, 0x64,
+	0x61, 0x74, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x23, 0x2e, 0x63, 0x6f, 0x6d, 0x6d,
+	0x61, 0x6e, 0x64, 0x73, 0x2e, 0x75, 0x73, 0x65, 0x72, 0x2e, 0x76, 0x31, 0x2e, 0x55, 0x70, 0x64,
+	0x61, 0x74, 0x65, 0x55, 0x73, 0x65, 0x72, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x48, 0x00,
+	0x52, 0x06, 0x75, 0x70, 0x64, 0x61, 0x74, 0x65, 0x12, 0x3d, 0x0a, 0x06, 0x64, 0x65, 0x6c, 0x65,
+	0x74, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x23, 0x2e, 0x63, 0x6f, 0x6d, 0x6d, 0x61,
+	0x6e, 0x64, 0x73, 0x2e, 0x75, 0x73, 0x65, 0x72, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x65, 0x6c, 0x65,
+	0x74, 0x65, 0x55, 0x73, 0x65, 0x72, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x48, 0x00, 0x52,
+	0x06, 0x64, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x12, 0x3a, 0x0a, 0x05, 0x65, 0x72, 0x61, 0x73, 0x65,
+	0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x22, 0x2e, 0x63, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64,
+	0x73, 0x2e, 0x75, 0x73, 0x65, 0x72, 0x2e, 0x76, 0x31, 0x2e, 0x45, 0x72, 0x61, 0x73, 0x65, 0x55,
+	0x73, 0x65, 0x72, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x48, 0x00, 0x52, 0x05, 0x65, 0x72,
+	0x61, 0x73, 0x65, 0x42, 0x09, 0x0a, 0x07, 0x63, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x22, 0x3d,
+	0x0a, 0x11, 0x43, 0x72, 0x65, 0x61, 0x74, 0x65, 0x55, 0x73, 0x65, 0x72, 0x43, 0x6f, 0x6d, 0x6d,
+	0x61, 0x6e, 0x64, 0x12, 0x28, 0x0a, 0x04, 0x75, 0x73, 0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x14, 0x2e, 0x73, 0x68, 0x61, 0x72, 0x65, 0x64, 0x2e, 0x75, 0x73, 0x65, 0x72, 0x2e,
+	0x76, 0x31, 0x2e, 0x55, 0x73, 0x65, 0x72, 0x52, 0x04, 0x75, 0x73, 0x65, 0x72, 0x22, 0x97, 0x01,
+	0x0a, 0x11, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x55, 0x73, 0x65, 0x72, 0x43, 0x6f, 0x6d, 0x6d,
+	0x61, 0x6e, 0x64, 0x12, 0x28, 0x0a, 0x04, 0x75, 0x73, 0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x14, 0x2e, 0x73, 0x68, 0x61, 0x72, 0x65, 0x64, 0x2e, 0x75, 0x73, 0x65, 0x72, 0x2e,
+	0x76, 0x31, 0x2e, 0x55, 0x73, 0x65, 0x72, 0x52, 0x04, 0x75, 0x73, 0x65, 0x72, 0x12, 0x44, 0x0a,
+	0x0d, 0x75, 0x70, 0x64, 0x61, 0x74, 0x65, 0x5f, 0x66, 0x69, 0x65, 0x6c, 0x64, 0x73, 0x18, 0x02,
+	0x20, 0x03, 0x28, 0x0e, 0x32, 0x1f, 0x2e, 0x73, 0x68, 0x61, 0x72, 0x65, 0x64, 0x2e, 0x75, 0x73,
+	0x65, 0x72, 0x2e, 0x76, 0x31, 0x2e, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x55, 0x73, 0x65, 0x72,
+	0x46, 0x69, 0x65, 0x6c, 0x64, 0x52, 0x0c, 0x75, 0x70, 0x64, 0x61, 0x74, 0x65, 0x46, 0x69, 0x65,
+	0x6c, 0x64, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x65, 0x74, 0x61, 0x67, 0x18, 0x03, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x04, 0x65, 0x74, 0x61, 0x67, 0x22, 0x37, 0x0a, 0x11, 0x44, 0x65, 0x6c, 0x65, 0x74,
+	0x65, 0x55, 0x73, 0x65, 0x72, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x12, 0x0e, 0x0a, 0x02,
+	0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x12, 0x0a, 0x04,
+	0x65, 0x74, 0x61, 0x67, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x65, 0x74, 0x61, 0x67,
+	0x22, 0x22, 0x0a, 0x10, 0x45, 0x72, 0x61, 0x73, 0x65, 0x55, 0x73, 0x65, 0x72, 0x43, 0x6f, 0x6d,
+	0x6d, 0x61, 0x6e, 0x64, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x02, 0x69, 0x64, 0x22, 0xd8, 0x02, 0x0a, 0x11, 0x55, 0x73, 0x65, 0x72, 0x43, 0x6f, 0x6d,
+	0x6d, 0x61, 0x6e, 0x64, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x12, 0x1d, 0x0a, 0x0a, 0x63, 0x6f,
+	0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09,
+	0x63, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x49, 0x64, 0x12, 0x42, 0x0a, 0x06, 0x73, 0x74, 0x61,
+	0x74, 0x75, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x2a, 0x2e, 0x63, 0x6f, 0x6d, 0x6d,
+	0x61, 0x6e, 0x64, 0x73, 0x2e, 0x75, 0x73, 0x65, 0x72, 0x2e, 0x76, 0x31, 0x2e, 0x55, 0x73, 0x65,
+	0x72, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x2e, 0x53,
+	0x74, 0x61, 0x74, 0x75, 0x73, 0x52, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x28, 0x0a,
+	0x04, 0x75, 0x73, 0x65, 0x72, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x73, 0x68,
+	0x61, 0x72, 0x65, 0x64, 0x2e, 0x75, 0x73, 0x65, 0x72, 0x2e, 0x76, 0x31, 0x2e, 0x55, 0x73, 0x65,
+	0x72, 0x52, 0x04, 0x75, 0x73, 0x65, 0x72, 0x12, 0x16, 0x0a, 0x06, 0x72, 0x65, 0x61, 0x73, 0x6f,
+	0x6e, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x12,
+	0x14, 0x0a, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05,
+	0x65, 0x72, 0x72, 0x6f, 0x72, 0x12, 0x3d, 0x0a, 0x0c, 0x70, 0x72, 0x6f, 0x63, 0x65, 0x73, 0x73,
+	0x65, 0x64, 0x5f, 0x61, 0x74, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f,
+	0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69,
+	0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x0b, 0x70, 0x72, 0x6f, 0x63, 0x65, 0x73, 0x73,
+	0x65, 0x64, 0x41, 0x74, 0x22, 0x49, 0x0a, 0x06, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x16,
+	0x0a, 0x12, 0x53, 0x54, 0x41, 0x54, 0x55, 0x53, 0x5f, 0x55, 0x4e, 0x53, 0x50, 0x45, 0x43, 0x49,
+	0x46, 0x49, 0x45, 0x44, 0x10, 0x00, 0x12, 0x14, 0x0a, 0x10, 0x53, 0x54, 0x41, 0x54, 0x55, 0x53,
+	0x5f, 0x53, 0x55, 0x43, 0x43, 0x45, 0x45, 0x44, 0x45, 0x44, 0x10, 0x01, 0x12, 0x11, 0x0a, 0x0d,
+	0x53, 0x54, 0x41, 0x54, 0x55, 0x53, 0x5f, 0x46, 0x41, 0x49, 0x4c, 0x45, 0x44, 0x10, 0x02, 0x42,
+	0x3f, 0x5a, 0x3d, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x6a, 0x61,
+	0x63, 0x6b, 0x74, 0x61, 0x6e, 0x74, 0x72, 0x61, 0x6d, 0x2f, 0x75, 0x73, 0x65, 0x72, 0x2d, 0x73,
+	0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x2f, 0x62, 0x75, 0x69, 0x6c, 0x64, 0x2f, 0x67, 0x6f, 0x2f,
+	0x63, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x73, 0x2f, 0x75, 0x73, 0x65, 0x72, 0x2f, 0x76, 0x31,
+	0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_commands_user_v1_user_proto_rawDescOnce sync.Once
+	file_commands_user_v1_user_proto_rawDescData = file_commands_user_v1_user_proto_rawDesc
+)
+
+func file_commands_user_v1_user_proto_rawDescGZIP() []byte {
+	file_commands_user_v1_user_proto_rawDescOnce.Do(func() {
+		file_commands_user_v1_user_proto_rawDescData = protoimpl.X.CompressGZIP(file_commands_user_v1_user_proto_rawDescData)
+	})
+	return file_commands_user_v1_user_proto_rawDescData
+}
+
+var file_commands_user_v1_user_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_commands_user_v1_user_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_commands_user_v1_user_proto_goTypes = []interface{}{
+	(UserCommandResult_Status)(0), // 0: commands.user.v1.UserCommandResult.Status
+	(*UserCommand)(nil),           // 1: commands.user.v1.UserCommand
+	(*CreateUserCommand)(nil),     // 2: commands.user.v1.CreateUserCommand
+	(*UpdateUserCommand)(nil),     // 3: commands.user.v1.UpdateUserCommand
+	(*DeleteUserCommand)(nil),     // 4: commands.user.v1.DeleteUserCommand
+	(*EraseUserCommand)(nil),      // 5: commands.user.v1.EraseUserCommand
+	(*UserCommandResult)(nil),     // 6: commands.user.v1.UserCommandResult
+	(*v1.User)(nil),               // 7: shared.user.v1.User
+	(v1.UpdateUserField)(0),       // 8: shared.user.v1.UpdateUserField
+	(*timestamppb.Timestamp)(nil), // 9: google.protobuf.Timestamp
+}
+var file_commands_user_v1_user_proto_depIdxs = []int32{
+	2,  // 0: commands.user.v1.UserCommand.create:type_name -> commands.user.v1.CreateUserCommand
+	3,  // 1: commands.user.v1.UserCommand.update:type_name -> commands.user.v1.UpdateUserCommand
+	4,  // 2: commands.user.v1.UserCommand.delete:type_name -> commands.user.v1.DeleteUserCommand
+	5,  // 3: commands.user.v1.UserCommand.erase:type_name -> commands.user.v1.EraseUserCommand
+	7,  // 4: commands.user.v1.CreateUserCommand.user:type_name -> shared.user.v1.User
+	7,  // 5: commands.user.v1.UpdateUserCommand.user:type_name -> shared.user.v1.User
+	8,  // 6: commands.user.v1.UpdateUserCommand.update_fields:type_name -> shared.user.v1.UpdateUserField
+	0,  // 7: commands.user.v1.UserCommandResult.status:type_name -> commands.user.v1.UserCommandResult.Status
+	7,  // 8: commands.user.v1.UserCommandResult.user:type_name -> shared.user.v1.User
+	9,  // 9: commands.user.v1.UserCommandResult.processed_at:type_name -> google.protobuf.Timestamp
+	10, // [10:10] is the sub-list for method output_type
+	10, // [10:10] is the sub-list for method input_type
+	10, // [10:10] is the sub-list for extension type_name
+	10, // [10:10] is the sub-list for extension extendee
+	0,  // [0:10] is the sub-list for field type_name
+}
+
+func init() { file_commands_user_v1_user_proto_init() }
+func file_commands_user_v1_user_proto_init() {
+	if File_commands_user_v1_user_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_commands_user_v1_user_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*UserCommand); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_commands_user_v1_user_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CreateUserCommand); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_commands_user_v1_user_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*UpdateUserCommand); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_commands_user_v1_user_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DeleteUserCommand); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_commands_user_v1_user_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*EraseUserCommand); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_commands_user_v1_user_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*UserCommandResult); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	file_commands_user_v1_user_proto_msgTypes[0].OneofWrappers = []interface{}{
+		(*UserCommand_Create)(nil),
+		(*UserCommand_Update)(nil),
+		(*UserCommand_Delete)(nil),
+		(*UserCommand_Erase)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_commands_user_v1_user_proto_rawDesc,
+			NumEnums:      1,
+			NumMessages:   6,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_commands_user_v1_user_proto_goTypes,
+		DependencyIndexes: file_commands_user_v1_user_proto_depIdxs,
+		EnumInfos:         file_commands_user_v1_user_proto_enumTypes,
+		MessageInfos:      file_commands_user_v1_user_proto_msgTypes,
+	}.Build()
+	File_commands_user_v1_user_proto = out.File
+	file_commands_user_v1_user_proto_rawDesc = nil
+	file_commands_user_v1_user_proto_goTypes = nil
+	file_commands_user_v1_user_proto_depIdxs = nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jacktantram/user-service/internal/deadletter"
+	"github.com/jacktantram/user-service/internal/store"
+	"github.com/jacktantram/user-service/pkg/driver/v1/config"
+	v1 "github.com/jacktantram/user-service/pkg/driver/v1/postgres"
+	log "github.com/sirupsen/logrus"
+)
+
+// runDeadLetter inspects, retries or purges dead letters held in Postgres, i.e.
+//
+//	app deadletter list -limit=20
+//	app deadletter retry -id=a8bdce5a-31dc-4647-98b5-ce9cb343138f
+//	app deadletter purge -all
+func runDeadLetter(args []string) {
+	if len(args) == 0 {
+		log.Fatal("usage: deadletter list|retry|purge [flags]")
+	}
+	action := args[0]
+
+	flags := flag.NewFlagSet("deadletter "+action, flag.ExitOnError)
+	var (
+		id     = flags.String("id", "", "id of the dead letter to retry or purge")
+		all    = flags.Bool("all", false, "retry or purge every dead letter")
+		offset = flags.Uint64("offset", 0, "offset when listing dead letters")
+		limit  = flags.Uint64("limit", 100, "maximum number of dead letters to list or retry")
+	)
+	if err := flags.Parse(args[1:]); err != nil {
+		log.WithError(err).Fatal("unable to parse deadletter flags")
+	}
+	if action != "list" && *id == "" && !*all {
+		log.Fatal("either -id or -all must be provided")
+	}
+
+	cfg := &Cfg{}
+	if err := config.LoadConfig(cfg); err != nil {
+		log.WithError(err).Fatalf("unable to load config")
+	}
+	client, err := v1.NewClient(cfg.DatabaseURI, "users")
+	if err != nil {
+		log.WithError(err).Fatal("failed to setup postgres client")
+	}
+	defer client.DB.Close()
+	userStore := store.NewStore(client)
+
+	ctx := context.Background()
+	switch action {
+	case "list":
+		deadLetters, err := deadletter.NewManager(userStore, nil, nil).List(ctx, *offset, *limit)
+		if err != nil {
+			log.WithError(err).Fatal("unable to list dead letters")
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tTOPIC\tKEY\tTYPE\tATTEMPTS\tCREATED AT\tERROR")
+		for _, d := range deadLetters {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n", d.ID, d.Topic, d.Key, d.MessageType, d.Attempts,
+				d.CreatedAt.Format(time.RFC3339), d.Error)
+		}
+		_ = w.Flush()
+	case "retry":
+		topics, err := cfg.topics()
+		if err != nil {
+			log.WithError(err).Fatal("invalid kafka topic name")
+		}
+		// dead letters are republished to the event bus they failed to reach. The sync producer is used so a
+		// dead letter is only removed once the broker has acknowledged it.
+		cfg.Kafka.Async.Enabled = false
+		bus, err := newEventBus(cfg, topics, topics.All())
+		if err != nil {
+			log.WithError(err).Fatal("unable to create event bus")
+		}
+		defer bus.close()
+		manager := deadletter.NewManager(userStore, userStore, bus.producer)
+		if *all {
+			published, failed, err := manager.RetryAll(ctx, *limit)
+			if err != nil {
+				log.WithError(err).Fatal("unable to retry dead letters")
+			}
+			log.WithFields(log.Fields{"published": published, "failed": failed}).Info("dead letters retried")
+			return
+		}
+		if err = manager.Retry(ctx, *id); err != nil {
+			log.WithError(err).Fatal("unable to retry dead letter")
+		}
+		log.WithField("dead_letter_id", *id).Info("dead letter published")
+	case "purge":
+		manager := deadletter.NewManager(userStore, nil, nil)
+		if *all {
+			purged, err := manager.PurgeAll(ctx)
+			if err != nil {
+				log.WithError(err).Fatal("unable to purge dead letters")
+			}
+			log.WithField("purged", purged).Info("dead letters purged")
+			return
+		}
+		if err = manager.Purge(ctx, *id); err != nil {
+			log.WithError(err).Fatal("unable to purge dead letter")
+		}
+		log.WithField("dead_letter_id", *id).Info("dead letter purged")
+	default:
+		log.Fatalf("unknown deadletter action %q, expected list, retry or purge", action)
+	}
+}
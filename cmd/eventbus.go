@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hellofresh/health-go/v5"
+	"github.com/jacktantram/user-service/internal/service"
+	"github.com/jacktantram/user-service/pkg/driver/v1/file"
+	"github.com/jacktantram/user-service/pkg/driver/v1/kafka"
+	"github.com/jacktantram/user-service/pkg/driver/v1/memory"
+	"github.com/jacktantram/user-service/pkg/driver/v1/nats"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	eventBusKafka  = "kafka"
+	eventBusMemory = "memory"
+	eventBusFile   = "file"
+	eventBusNATS   = "nats"
+)
+
+// eventBus is the producer events are published to along with its lifecycle hooks.
+type eventBus struct {
+	producer service.Producer
+	// close flushes and releases the producer.
+	close func()
+	// checks are added to the readiness endpoint.
+	checks []health.Config
+}
+
+// newEventBus creates the producer for the configured event bus driver.
+// provisionTopics are created up front by drivers that require it.
+func newEventBus(cfg *Cfg, topics service.Topics, provisionTopics []string) (eventBus, error) {
+	switch cfg.EventBus.Driver {
+	case eventBusKafka:
+		return newKafkaEventBus(cfg, topics, provisionTopics)
+	case eventBusMemory:
+		// nothing reads the retained messages of the server's bus, it only needs to deliver them
+		return eventBus{producer: memory.NewBus(memory.WithRetention(0)), close: func() {}}, nil
+	case eventBusFile:
+		producer, err := file.NewProducer(cfg.EventBus.FilePath)
+		if err != nil {
+			return eventBus{}, err
+		}
+		return eventBus{producer: producer, close: func() {
+			if err := producer.Close(); err != nil {
+				log.WithError(err).Error("unable to close event file")
+			}
+		}}, nil
+	case eventBusNATS:
+		producer, err := nats.NewProducer(cfg.EventBus.NATS.URL)
+		if err != nil {
+			return eventBus{}, err
+		}
+		if err = producer.EnsureStream(cfg.EventBus.NATS.Stream, provisionTopics...); err != nil {
+			_ = producer.Close()
+			return eventBus{}, err
+		}
+		return eventBus{
+			producer: producer,
+			close: func() {
+				if err := producer.Close(); err != nil {
+					log.WithError(err).Error("unable to close nats producer")
+				}
+			},
+			checks: []health.Config{{
+				Name:    "nats",
+				Timeout: time.Second * 2,
+				Check:   producer.HealthCheck,
+			}},
+		}, nil
+	default:
+		return eventBus{}, fmt.Errorf("unknown event bus driver %q", cfg.EventBus.Driver)
+	}
+}
+
+func newKafkaEventBus(cfg *Cfg, topics service.Topics, provisionTopics []string) (eventBus, error) {
+	if cfg.Kafka.Provision.Enabled {
+		topicCfgs := make([]kafka.TopicConfig, 0, len(provisionTopics))
+		for _, topic := range provisionTopics {
+			topicCfgs = append(topicCfgs, kafka.TopicConfig{
+				Name:              topic,
+				Partitions:        cfg.Kafka.Provision.Partitions,
+				ReplicationFactor: cfg.Kafka.Provision.ReplicationFactor,
+				Retention:         cfg.Kafka.Provision.Retention,
+				Compact:           topic == topics.State,
+			})
+		}
+		if err := kafka.EnsureTopics(cfg.Kafka.Hosts, topicCfgs...); err != nil {
+			return eventBus{}, fmt.Errorf("unable to provision kafka topics: %w", err)
+		}
+	}
+
+	kafkaHealthCfg := kafka.HealthCheckConfig{Hosts: cfg.Kafka.Hosts}
+	if cfg.Kafka.HealthCheckTopics {
+		kafkaHealthCfg.Topics = topics.All()
+	}
+	healthCheck := kafka.NewHealthCheck(kafkaHealthCfg)
+	closeHealthCheck := func() {
+		if err := healthCheck.Close(); err != nil {
+			log.WithError(err).Error("unable to close kafka health check")
+		}
+	}
+	bus := eventBus{
+		close: closeHealthCheck,
+		checks: []health.Config{{
+			Name:      "kafka",
+			Timeout:   time.Second * 2,
+			SkipOnErr: false,
+			Check:     healthCheck.Check,
+		}},
+	}
+
+	metrics := kafka.NewProducerMetrics()
+	prometheus.MustRegister(metrics)
+
+	if !cfg.Kafka.Async.Enabled {
+		// the resilient producer wrapping the event bus owns retries
+		syncProducer, err := kafka.NewSyncProducer(kafka.ProducerConfig{
+			Metrics:        metrics,
+			DisableRetries: true,
+			Timeout:        cfg.Producer.Timeout,
+		}, cfg.Kafka.Hosts...)
+		if err != nil {
+			closeHealthCheck()
+			return eventBus{}, fmt.Errorf("unable to create kafka producer: %w", err)
+		}
+		bus.producer = syncProducer
+		return bus, nil
+	}
+
+	asyncProducer, err := kafka.NewAsyncProducer(kafka.AsyncProducerConfig{
+		BatchSize:   cfg.Kafka.Async.BatchSize,
+		BatchBytes:  cfg.Kafka.Async.BatchBytes,
+		Linger:      cfg.Kafka.Async.Linger,
+		MaxInFlight: cfg.Kafka.Async.MaxInFlight,
+		Metrics:     metrics,
+		OnError: func(report kafka.DeliveryReport) {
+			log.WithError(report.Err).WithField("topic_name", report.Topic).Error("unable to deliver message")
+		},
+	}, cfg.Kafka.Hosts...)
+	if err != nil {
+		closeHealthCheck()
+		return eventBus{}, fmt.Errorf("unable to create kafka async producer: %w", err)
+	}
+	bus.producer = asyncProducer
+	bus.close = func() {
+		defer closeHealthCheck()
+		flushCtx, flushCancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer flushCancel()
+		if err := asyncProducer.Close(flushCtx); err != nil {
+			log.WithError(err).Error("unable to flush kafka async producer")
+		}
+	}
+	return bus, nil
+}
@@ -2,9 +2,18 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"github.com/jacktantram/user-service/internal/command"
+	"github.com/jacktantram/user-service/internal/deadletter"
+	"github.com/jacktantram/user-service/internal/resilience"
 	"github.com/jacktantram/user-service/internal/service"
 	"github.com/jacktantram/user-service/internal/store"
+	"github.com/jacktantram/user-service/internal/transport/transportgateway"
 	"github.com/jacktantram/user-service/internal/transport/transportgrpc"
+	"github.com/jacktantram/user-service/internal/transport/transportweb"
+	"github.com/jacktantram/user-service/pkg/consumer"
 	"net"
 	"net/http"
 	"os"
@@ -12,15 +21,28 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/golang-jwt/jwt/v4"
 	grpcPrometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
 	"github.com/hellofresh/health-go/v5"
 	healthPostgres "github.com/hellofresh/health-go/v5/checks/postgres"
 	"github.com/jacktantram/user-service/pkg/driver/v1/config"
 	"github.com/jacktantram/user-service/pkg/driver/v1/kafka"
 	v1 "github.com/jacktantram/user-service/pkg/driver/v1/postgres"
+	"github.com/jacktantram/user-service/pkg/tracing"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const (
+	deadLetterStorePostgres = "postgres"
+	deadLetterStoreKafka    = "kafka"
+	deadLetterStoreNone     = "none"
 )
 
 // Cfg represents the services config
@@ -28,12 +50,216 @@ type Cfg struct {
 	DatabaseURI   string `envconfig:"DATABASE_URI"`
 	MigrationPath string `envconfig:"MIGRATION_PATH" default:"/migrations"`
 
+	// DeadLetter configures where events that could not be published are kept.
+	DeadLetter struct {
+		// Store is one of postgres, kafka or none.
+		Store string `envconfig:"DEAD_LETTER_STORE" default:"postgres"`
+		// Topic is the dead letter topic used by the kafka store.
+		Topic string `envconfig:"DEAD_LETTER_TOPIC" default:"user-deadletter_v1"`
+	}
+
+	// EventBus selects where events are published.
+	EventBus struct {
+		// Driver is one of kafka, memory, file or nats.
+		Driver string `envconfig:"EVENT_BUS_DRIVER" default:"kafka"`
+		// FilePath is the newline delimited JSON file written to by the file driver.
+		FilePath string `envconfig:"EVENT_BUS_FILE_PATH" default:"events.ndjson"`
+		NATS     struct {
+			URL string `envconfig:"NATS_URL" default:"nats://localhost:4222"`
+			// Stream is the JetStream stream capturing the topics, created if missing.
+			Stream string `envconfig:"NATS_STREAM" default:"USERS"`
+		}
+	}
+
+	// Producer configures the retries and circuit breaker wrapping the event bus producer.
+	Producer struct {
+		// Timeout bounds each call to a Kafka broker. Kafka writes aren't retried by the client, so a failing
+		// broker is seen by the circuit breaker after Timeout rather than after the client's own retries.
+		Timeout time.Duration `envconfig:"PRODUCER_TIMEOUT" default:"5s"`
+		Retry   struct {
+			MaxAttempts    int           `envconfig:"PRODUCER_RETRY_MAX_ATTEMPTS" default:"3"`
+			InitialBackoff time.Duration `envconfig:"PRODUCER_RETRY_INITIAL_BACKOFF" default:"50ms"`
+			MaxBackoff     time.Duration `envconfig:"PRODUCER_RETRY_MAX_BACKOFF" default:"1s"`
+		}
+		Breaker struct {
+			FailureThreshold int           `envconfig:"PRODUCER_BREAKER_FAILURE_THRESHOLD" default:"5"`
+			OpenTimeout      time.Duration `envconfig:"PRODUCER_BREAKER_OPEN_TIMEOUT" default:"30s"`
+		}
+	}
+
+	// Commands consumes user commands from Kafka and publishes their results.
+	Commands struct {
+		Enabled     bool   `envconfig:"COMMANDS_ENABLED"`
+		GroupID     string `envconfig:"COMMANDS_GROUP_ID" default:"user-service"`
+		Topic       string `envconfig:"KAFKA_TOPIC_USER_COMMAND_REQUESTED" default:"usercommand-requested_v1"`
+		ResultTopic string `envconfig:"KAFKA_TOPIC_USER_COMMAND_COMPLETED" default:"usercommand-completed_v1"`
+	}
+
+	// TLS serves grpc over TLS when a certificate is configured, with mutual TLS when a client CA is configured.
+	TLS struct {
+		CertFile     string `envconfig:"TLS_CERT_FILE"`
+		KeyFile      string `envconfig:"TLS_KEY_FILE"`
+		ClientCAFile string `envconfig:"TLS_CLIENT_CA_FILE"`
+		// RequireClientCert rejects clients without a certificate signed by the client CA. The REST gateway,
+		// Connect and gRPC-Web are then not served, as they are plaintext on the http server.
+		RequireClientCert bool `envconfig:"TLS_REQUIRE_CLIENT_CERT"`
+		// MinVersion is one of 1.2 or 1.3.
+		MinVersion string `envconfig:"TLS_MIN_VERSION" default:"1.2"`
+		// ReloadInterval is how often the certificate files are checked for rotation, zero disables reloading.
+		ReloadInterval time.Duration `envconfig:"TLS_RELOAD_INTERVAL" default:"1m"`
+	}
+
+	// Auth configures validation of the bearer JWTs calls must be authenticated with.
+	Auth struct {
+		Enabled bool `envconfig:"AUTH_ENABLED" default:"true"`
+		// JWKSFile is a local JSON Web Key Set, used instead of JWKSURL when set.
+		JWKSFile string `envconfig:"AUTH_JWKS_FILE"`
+		// JWKSURL is a remote JSON Web Key Set, refreshed every JWKSRefreshInterval.
+		JWKSURL             string        `envconfig:"AUTH_JWKS_URL"`
+		JWKSRefreshInterval time.Duration `envconfig:"AUTH_JWKS_REFRESH_INTERVAL" default:"1h"`
+		Issuer              string        `envconfig:"AUTH_ISSUER"`
+		Audience            string        `envconfig:"AUTH_AUDIENCE"`
+	}
+
+	// Authorization configures which authenticated callers may call each method, it is only read from the
+	// config file, i.e.
+	//
+	//	authorization:
+	//	  policy:
+	//	    methods:
+	//	      /rpc.user.v1.UserService/GetUser:
+	//	        - roles: [admin, service]
+	//	        - self: true
+	Authorization struct {
+		// Policy defaults to transportgrpc.DefaultPolicy when no methods are configured.
+		Policy transportgrpc.Policy `ignored:"true"`
+	}
+
+	// RateLimit throttles each caller, identified by their subject or IP address, to a token bucket per
+	// method. Per method limits are only read from the config file, i.e.
+	//
+	//	ratelimit:
+	//	  methods:
+	//	    /rpc.user.v1.UserService/ListUsers:
+	//	      rate: 5
+	//	      burst: 10
+	RateLimit struct {
+		Enabled bool `envconfig:"RATE_LIMIT_ENABLED" default:"true"`
+		// Rate is the calls per second allowed to methods without their own limit, zero disables it.
+		Rate float64 `envconfig:"RATE_LIMIT_RATE" default:"50"`
+		// Burst is the number of calls allowed at once, zero uses the rate.
+		Burst int `envconfig:"RATE_LIMIT_BURST" default:"100"`
+		// IdleTimeout is how long the bucket of a caller is kept after their last call.
+		IdleTimeout time.Duration                      `envconfig:"RATE_LIMIT_IDLE_TIMEOUT" default:"10m"`
+		Methods     map[string]transportgrpc.RateLimit `ignored:"true"`
+	}
+
+	// Idempotency replays the response of creates, updates and deletes retried with the same
+	// idempotency-key header.
+	Idempotency struct {
+		// TTL is how long a response is replayed for.
+		TTL time.Duration `envconfig:"IDEMPOTENCY_KEY_TTL" default:"24h"`
+		// PurgeInterval is how often expired keys are deleted, zero disables purging.
+		PurgeInterval time.Duration `envconfig:"IDEMPOTENCY_PURGE_INTERVAL" default:"1h"`
+	}
+
+	// Tracing exports the spans of gRPC calls, store statements and produced messages.
+	Tracing struct {
+		// Exporter is otlp, stdout or none, which exports nothing but passes on the trace context and sampling
+		// decision of callers.
+		Exporter string `envconfig:"TRACING_EXPORTER" default:"none"`
+		// Endpoint is the host:port of the OTLP gRPC collector.
+		Endpoint string `envconfig:"TRACING_OTLP_ENDPOINT" default:"localhost:4317"`
+		Insecure bool   `envconfig:"TRACING_OTLP_INSECURE"`
+		// SampleRatio is the fraction of traces started by the service that are exported.
+		SampleRatio float64 `envconfig:"TRACING_SAMPLE_RATIO" default:"1"`
+	}
+
+	GRPC struct {
+		// Reflection registers the server reflection service, i.e. for grpcurl.
+		Reflection bool `envconfig:"GRPC_REFLECTION_ENABLED"`
+		// HealthCheckInterval is how often the grpc.health.v1 serving status is refreshed from the readiness checks.
+		HealthCheckInterval time.Duration `envconfig:"GRPC_HEALTH_CHECK_INTERVAL" default:"5s"`
+	}
+
+	// CORS configures which browser origins may call the service over the Connect and gRPC-Web protocols.
+	CORS struct {
+		// AllowedOrigins is a comma separated list of origins, "*" allows any origin and none denies
+		// cross-origin requests.
+		AllowedOrigins   []string      `envconfig:"CORS_ALLOWED_ORIGINS"`
+		AllowedHeaders   []string      `envconfig:"CORS_ALLOWED_HEADERS"`
+		ExposedHeaders   []string      `envconfig:"CORS_EXPOSED_HEADERS"`
+		AllowCredentials bool          `envconfig:"CORS_ALLOW_CREDENTIALS"`
+		MaxAge           time.Duration `envconfig:"CORS_MAX_AGE" default:"10m"`
+	}
+
 	Kafka struct {
 		Hosts []string `envconfig:"KAFKA_HOSTS"`
+		// HealthCheckTopics additionally requires the service topics to exist for readiness.
+		HealthCheckTopics bool `envconfig:"KAFKA_HEALTH_CHECK_TOPICS"`
+
+		Topics struct {
+			// Prefix is prepended to every topic name, i.e. $prefix.$topic
+			Prefix    string `envconfig:"KAFKA_TOPIC_PREFIX"`
+			Created   string `envconfig:"KAFKA_TOPIC_USER_CREATED" default:"user-created_v1"`
+			Updated   string `envconfig:"KAFKA_TOPIC_USER_UPDATED" default:"user-updated_v1"`
+			UpdatedV2 string `envconfig:"KAFKA_TOPIC_USER_UPDATED_V2" default:"user-updated_v2"`
+			Deleted   string `envconfig:"KAFKA_TOPIC_USER_DELETED" default:"user-deleted_v1"`
+			State     string `envconfig:"KAFKA_TOPIC_USERS_STATE" default:"users-state_v1"`
+		}
+
+		// Provision creates any missing topics at startup.
+		Provision struct {
+			Enabled           bool          `envconfig:"KAFKA_PROVISION_TOPICS"`
+			Partitions        int32         `envconfig:"KAFKA_TOPIC_PARTITIONS" default:"3"`
+			ReplicationFactor int16         `envconfig:"KAFKA_TOPIC_REPLICATION_FACTOR" default:"1"`
+			Retention         time.Duration `envconfig:"KAFKA_TOPIC_RETENTION" default:"168h"`
+		}
+
+		// Async enables the batched asynchronous producer, so writes do not wait on broker acks.
+		Async struct {
+			Enabled     bool          `envconfig:"KAFKA_ASYNC_ENABLED"`
+			BatchSize   int           `envconfig:"KAFKA_ASYNC_BATCH_SIZE" default:"100"`
+			BatchBytes  int           `envconfig:"KAFKA_ASYNC_BATCH_BYTES"`
+			Linger      time.Duration `envconfig:"KAFKA_ASYNC_LINGER" default:"10ms"`
+			MaxInFlight int           `envconfig:"KAFKA_ASYNC_MAX_IN_FLIGHT" default:"1000"`
+		}
 	}
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "replay":
+			runReplay(os.Args[2:])
+			return
+		case "deadletter":
+			runDeadLetter(os.Args[2:])
+			return
+		}
+	}
+	serve()
+}
+
+// topics returns the configured topics, validating their names.
+func (c *Cfg) topics() (service.Topics, error) {
+	topics := service.Topics{
+		Created:   c.Kafka.Topics.Created,
+		Updated:   c.Kafka.Topics.Updated,
+		UpdatedV2: c.Kafka.Topics.UpdatedV2,
+		Deleted:   c.Kafka.Topics.Deleted,
+		State:     c.Kafka.Topics.State,
+	}.WithPrefix(c.Kafka.Topics.Prefix)
+	for _, topic := range topics.All() {
+		if err := kafka.ValidateTopicName(topic); err != nil {
+			return service.Topics{}, err
+		}
+	}
+	return topics, nil
+}
+
+// serve runs the gRPC and HTTP servers until interrupted.
+func serve() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
@@ -46,6 +272,17 @@ func main() {
 	if err := config.LoadConfig(cfg); err != nil {
 		log.WithError(err).Fatalf("unable to load config")
 	}
+	// tracing
+	tracerProvider, err := tracing.NewTracerProvider(ctx, tracing.Config{
+		ServiceName: "user-service",
+		Exporter:    cfg.Tracing.Exporter,
+		Endpoint:    cfg.Tracing.Endpoint,
+		Insecure:    cfg.Tracing.Insecure,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	})
+	if err != nil {
+		log.WithError(err).Fatal("unable to create tracer provider")
+	}
 	// database
 	client, err := v1.NewClient(cfg.DatabaseURI, "users")
 	if err != nil {
@@ -58,10 +295,85 @@ func main() {
 	}
 	userStore := store.NewStore(client)
 
-	// kafka
-	kafkaProducer, err := kafka.NewSyncProducer(kafka.ProducerConfig{}, cfg.Kafka.Hosts...)
+	// event bus
+	topics, err := cfg.topics()
+	if err != nil {
+		log.WithError(err).Fatal("invalid kafka topic name")
+	}
+	if cfg.EventBus.Driver == eventBusKafka && cfg.Kafka.Async.Enabled && cfg.DeadLetter.Store != deadLetterStoreNone {
+		// the async producer reports failures after the write has returned, so they can't be dead lettered
+		log.Fatal("KAFKA_ASYNC_ENABLED requires DEAD_LETTER_STORE=none")
+	}
+	provisionTopics := topics.All()
+	if cfg.DeadLetter.Store == deadLetterStoreKafka {
+		if err = kafka.ValidateTopicName(cfg.DeadLetter.Topic); err != nil {
+			log.WithError(err).Fatal("invalid dead letter topic name")
+		}
+		provisionTopics = append(provisionTopics, cfg.DeadLetter.Topic)
+	}
+	commandTopic, commandResultTopic := service.PrefixTopic(cfg.Kafka.Topics.Prefix, cfg.Commands.Topic),
+		service.PrefixTopic(cfg.Kafka.Topics.Prefix, cfg.Commands.ResultTopic)
+	if cfg.Commands.Enabled {
+		for _, topic := range []string{commandTopic, commandResultTopic} {
+			if err = kafka.ValidateTopicName(topic); err != nil {
+				log.WithError(err).Fatal("invalid command topic name")
+			}
+		}
+		provisionTopics = append(provisionTopics, commandTopic, commandResultTopic)
+	}
+	bus, err := newEventBus(cfg, topics, provisionTopics)
+	if err != nil {
+		log.WithError(err).Fatal("unable to create event bus")
+	}
+	defer bus.close()
+	resilientProducer := resilience.NewResilientProducer(bus.producer, resilience.BreakerConfig{
+		FailureThreshold: cfg.Producer.Breaker.FailureThreshold,
+		OpenTimeout:      cfg.Producer.Breaker.OpenTimeout,
+	}, resilience.RetryPolicy{
+		MaxAttempts:    cfg.Producer.Retry.MaxAttempts,
+		InitialBackoff: cfg.Producer.Retry.InitialBackoff,
+		MaxBackoff:     cfg.Producer.Retry.MaxBackoff,
+	})
+	prometheus.MustRegister(resilientProducer.Collector())
+	producer := resilientProducer
+
+	// dead letters
+	serviceOpts := []service.Option{service.WithTopics(topics)}
+	switch cfg.DeadLetter.Store {
+	case deadLetterStorePostgres:
+		prometheus.MustRegister(deadletter.NewSizeGauge(userStore))
+		serviceOpts = append(serviceOpts, service.WithDeadLetterStore(userStore))
+	case deadLetterStoreKafka:
+		// dead letters bypass the circuit breaker, they are needed most while it is open
+		topicStore := deadletter.NewTopicStore(bus.producer, cfg.DeadLetter.Topic)
+		prometheus.MustRegister(topicStore.Collector())
+		serviceOpts = append(serviceOpts, service.WithDeadLetterStore(topicStore))
+	case deadLetterStoreNone:
+	default:
+		log.Fatalf("unknown dead letter store %q", cfg.DeadLetter.Store)
+	}
+
+	// add some checks on instance creation
+	h, err := health.New(health.WithComponent(health.Component{
+		Name:    "user-service",
+		Version: "v1.0",
+	}), health.WithChecks(append([]health.Config{
+		{
+			Name:      "postgres",
+			Timeout:   time.Second * 2,
+			SkipOnErr: false,
+			Check: healthPostgres.New(healthPostgres.Config{
+				DSN: cfg.DatabaseURI,
+			}),
+		},
+		{
+			Name:      "producer-circuit-breaker",
+			SkipOnErr: false,
+			Check:     resilientProducer.HealthCheck,
+		},
+	}, bus.checks...)...))
 	if err != nil {
-		log.WithError(err).Fatal("unable to create kafka producer")
+		log.WithError(err).Fatal("unable to create healthchecks")
 	}
 
 	// grpc
@@ -69,17 +381,78 @@ func main() {
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
 	}
-	opts := []grpc.ServerOption{grpc.ChainStreamInterceptor(
-		grpcPrometheus.StreamServerInterceptor),
-		grpc.ChainUnaryInterceptor(
-			grpcPrometheus.UnaryServerInterceptor,
-		)}
+	streamInterceptors := []grpc.StreamServerInterceptor{otelgrpc.StreamServerInterceptor(),
+		transportgrpc.RequestIDStreamServerInterceptor(), grpcPrometheus.StreamServerInterceptor}
+	unaryInterceptors := []grpc.UnaryServerInterceptor{otelgrpc.UnaryServerInterceptor(),
+		transportgrpc.RequestIDUnaryServerInterceptor(), grpcPrometheus.UnaryServerInterceptor}
+	if cfg.Auth.Enabled {
+		authenticator, err := newAuthenticator(ctx, cfg)
+		if err != nil {
+			log.WithError(err).Fatal("unable to create authenticator")
+		}
+		policy := cfg.Authorization.Policy
+		if len(policy.Methods) == 0 {
+			policy = transportgrpc.DefaultPolicy()
+		}
+		authorizer := transportgrpc.NewAuthorizer(policy)
+		streamInterceptors = append(streamInterceptors, authenticator.StreamServerInterceptor(),
+			authorizer.StreamServerInterceptor())
+		unaryInterceptors = append(unaryInterceptors, authenticator.UnaryServerInterceptor(),
+			authorizer.UnaryServerInterceptor())
+	} else {
+		log.Warn("authentication is disabled, every call is allowed")
+	}
+	if cfg.RateLimit.Enabled {
+		rateLimiter := transportgrpc.NewRateLimiter(transportgrpc.RateLimitConfig{
+			Default:     transportgrpc.RateLimit{Rate: cfg.RateLimit.Rate, Burst: cfg.RateLimit.Burst},
+			Methods:     cfg.RateLimit.Methods,
+			IdleTimeout: cfg.RateLimit.IdleTimeout,
+		})
+		prometheus.MustRegister(rateLimiter.Collector())
+		streamInterceptors = append(streamInterceptors, rateLimiter.StreamServerInterceptor())
+		unaryInterceptors = append(unaryInterceptors, rateLimiter.UnaryServerInterceptor())
+	}
+	validator, err := transportgrpc.NewValidator()
+	if err != nil {
+		log.WithError(err).Fatal("unable to create request validator")
+	}
+	streamInterceptors = append(streamInterceptors, validator.StreamServerInterceptor())
+	unaryInterceptors = append(unaryInterceptors, validator.UnaryServerInterceptor())
+	idempotency := transportgrpc.NewIdempotency(userStore, transportgrpc.IdempotencyConfig{TTL: cfg.Idempotency.TTL})
+	go idempotency.PurgeExpired(ctx, cfg.Idempotency.PurgeInterval)
+	unaryInterceptors = append(unaryInterceptors, idempotency.UnaryServerInterceptor())
+	opts := []grpc.ServerOption{grpc.ChainStreamInterceptor(streamInterceptors...),
+		grpc.ChainUnaryInterceptor(unaryInterceptors...)}
+	grpcOpts := append([]grpc.ServerOption{}, opts...)
+	if cfg.TLS.CertFile != "" {
+		certReloader, err := newCertReloader(cfg)
+		if err != nil {
+			log.WithError(err).Fatal("unable to load tls certificates")
+		}
+		go certReloader.Watch(ctx, cfg.TLS.ReloadInterval)
+		grpcOpts = append(grpcOpts, grpc.Creds(credentials.NewTLS(certReloader.TLSConfig())))
+	} else {
+		log.Warn("tls is disabled, grpc is served in plaintext")
+	}
 	/** Turns on recording of handling time
 	of RPCs. Histogram metrics can be very expensive for Prometheus
 	 to retain and query. todo (look into al **/
 	grpcPrometheus.EnableHandlingTimeHistogram(grpcPrometheus.WithHistogramBuckets([]float64{0.1, 0.5, 0.7, 0.9, 0.95, 0.99}))
 
-	grpcServer, err := transportgrpc.NewServer(grpc.NewServer(opts...), service.NewService(userStore, kafkaProducer))
+	svc := service.NewService(userStore, producer, serviceOpts...)
+	serverOpts := []transportgrpc.Option{
+		// the grpc health service reflects the same checks as readiness
+		transportgrpc.WithHealthCheck(func(ctx context.Context) error {
+			if check := h.Measure(ctx); check.Status == health.StatusUnavailable {
+				return fmt.Errorf("readiness checks failed: %v", check.Failures)
+			}
+			return nil
+		}, cfg.GRPC.HealthCheckInterval),
+	}
+	if cfg.GRPC.Reflection {
+		serverOpts = append(serverOpts, transportgrpc.WithReflection())
+	}
+	grpcServer, err := transportgrpc.NewServer(grpc.NewServer(grpcOpts...), svc, serverOpts...)
 	if err != nil {
 		log.WithError(err).Fatal("unable to create new server")
 	}
@@ -93,24 +466,6 @@ func main() {
 
 	// http setup
 
-	// add some checks on instance creation
-	h, err := health.New(health.WithComponent(health.Component{
-		Name:    "user-service",
-		Version: "v1.0",
-	}), health.WithChecks(
-		health.Config{
-			Name:      "postgres",
-			Timeout:   time.Second * 2,
-			SkipOnErr: false,
-			Check: healthPostgres.New(healthPostgres.Config{
-				DSN: cfg.DatabaseURI,
-			}),
-		},
-	))
-	if err != nil {
-		log.WithError(err).Fatal("unable to create healthchecks")
-	}
-
 	httpServer := http.Server{Addr: ":8080"}
 	http.Handle("/health-check/readiness", h.Handler())
 	http.HandleFunc("/health-check/liveness", func(w http.ResponseWriter, r *http.Request) {
@@ -120,6 +475,57 @@ func main() {
 	// Register Prometheus metrics handler.
 	http.Handle("/metrics", promhttp.Handler())
 
+	// REST/JSON gateway, proxied to an in-memory grpc server with the same handlers and interceptors,
+	// so it doesn't need a client certificate when mutual TLS is optional. It serves the health check
+	// to browsers too, so watches the same readiness checks.
+	// Its callers are proxies, so it is given the addresses they forward to identify the caller by.
+	gatewayOpts := append([]grpc.ServerOption{
+		grpc.ChainStreamInterceptor(transportgrpc.ForwardedPeerStreamServerInterceptor()),
+		grpc.ChainUnaryInterceptor(transportgrpc.ForwardedPeerUnaryServerInterceptor()),
+	}, opts...)
+	gatewayServer, err := transportgrpc.NewServer(grpc.NewServer(gatewayOpts...), svc, serverOpts...)
+	if err != nil {
+		log.WithError(err).Fatal("unable to create gateway server")
+	}
+	gatewayLis := bufconn.Listen(1 << 20)
+	go func() {
+		if err := gatewayServer.Serve(gatewayLis); err != nil {
+			log.WithError(err).Fatal("unable to serve gateway grpc")
+		}
+	}()
+	http.Handle("/openapi/", http.StripPrefix("/openapi", transportgateway.NewOpenAPIHandler()))
+	// the in-memory server can't verify client certificates, so calls through it would bypass them
+	if cfg.TLS.RequireClientCert {
+		log.Warn("client certificates are required, the REST gateway, Connect and gRPC-Web are not served")
+	} else {
+		gatewayDialOpts := []grpc.DialOption{
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+				return gatewayLis.DialContext(ctx)
+			}),
+		}
+		gatewayHandler, err := transportgateway.NewHandler(ctx, "in-memory", gatewayDialOpts...)
+		if err != nil {
+			log.WithError(err).Fatal("unable to create gateway")
+		}
+		http.Handle("/v1/", gatewayHandler)
+
+		// Connect and gRPC-Web for browsers, forwarded to the same in-memory grpc server as the gateway.
+		webHandler, err := transportweb.NewHandler(ctx, "in-memory", transportweb.CORSConfig{
+			AllowedOrigins:   cfg.CORS.AllowedOrigins,
+			AllowedHeaders:   cfg.CORS.AllowedHeaders,
+			ExposedHeaders:   cfg.CORS.ExposedHeaders,
+			AllowCredentials: cfg.CORS.AllowCredentials,
+			MaxAge:           cfg.CORS.MaxAge,
+		}, gatewayDialOpts...)
+		if err != nil {
+			log.WithError(err).Fatal("unable to create web handler")
+		}
+		for _, pattern := range transportweb.Patterns() {
+			http.Handle(pattern, webHandler)
+		}
+	}
+
 	go func() {
 		log.Info("http server starting on port :8080")
 		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -127,6 +533,11 @@ func main() {
 		}
 	}()
 
+	healthCtx, stopHealth := context.WithCancel(ctx)
+	defer stopHealth()
+	go grpcServer.WatchHealth(healthCtx)
+	go gatewayServer.WatchHealth(healthCtx)
+
 	go func() {
 		log.Info("grpc server starting on port :5001")
 		if err := grpcServer.Serve(lis); err != nil {
@@ -134,14 +545,89 @@ func main() {
 		}
 	}()
 
+	// commands
+	consumerCtx, stopConsumer := context.WithCancel(ctx)
+	consumerDone := make(chan struct{})
+	go func() {
+		defer close(consumerDone)
+		if !cfg.Commands.Enabled {
+			return
+		}
+		handler, err := command.NewHandler(svc, userStore, producer, commandResultTopic)
+		if err != nil {
+			log.WithError(err).Fatal("unable to create command handler")
+		}
+		log.WithField("topic_name", commandTopic).Info("command consumer starting")
+		// the handler retries commands itself, blocking the partition until they are handled
+		commands := consumer.New(consumer.WithRetryPolicy(consumer.RetryPolicy{MaxAttempts: 1}))
+		commands.OnMessage(commandTopic, handler.HandleMessage)
+		if err := commands.RunKafka(consumerCtx, consumer.KafkaConfig{
+			Hosts:   cfg.Kafka.Hosts,
+			GroupID: cfg.Commands.GroupID,
+		}); err != nil {
+			log.WithError(err).Fatal("unable to consume commands")
+		}
+	}()
+
 	log.Print("Server Started")
 
 	<-done
 	log.Print("Server Stopping")
+	// stop load balancers routing new requests before draining
+	grpcServer.Shutdown()
+	gatewayServer.Shutdown()
+	stopConsumer()
+	<-consumerDone
 	if err = httpServer.Shutdown(ctx); err != nil {
 		log.Fatalf("Server Shutdown Failed:%+v", err)
 	}
 	grpcServer.GracefulStop()
+	gatewayServer.GracefulStop()
+	if err = tracing.Shutdown(tracerProvider, 5*time.Second); err != nil {
+		log.WithError(err).Error("unable to flush spans")
+	}
 	log.Print("Server Shutdown gracefully")
 
 }
+
+// newAuthenticator creates the authenticator validating tokens against the configured JWKS and,
+// with mutual TLS, accepting client certificates.
+func newAuthenticator(ctx context.Context, cfg *Cfg) (*transportgrpc.Authenticator, error) {
+	var (
+		keyfunc jwt.Keyfunc
+		err     error
+	)
+	switch {
+	case cfg.Auth.JWKSFile != "":
+		keyfunc, err = transportgrpc.JWKSFromFile(cfg.Auth.JWKSFile)
+	case cfg.Auth.JWKSURL != "":
+		keyfunc, err = transportgrpc.JWKSFromURL(ctx, cfg.Auth.JWKSURL, cfg.Auth.JWKSRefreshInterval)
+	case cfg.TLS.ClientCAFile == "":
+		err = errors.New("AUTH_JWKS_FILE, AUTH_JWKS_URL or TLS_CLIENT_CA_FILE is required when authentication is enabled")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return transportgrpc.NewAuthenticator(keyfunc, transportgrpc.AuthConfig{
+		Issuer:   cfg.Auth.Issuer,
+		Audience: cfg.Auth.Audience,
+		// callers with a verified client certificate are identified by it
+		ClientCertificates: cfg.TLS.ClientCAFile != "",
+	}), nil
+}
+
+// newCertReloader loads the configured grpc certificates.
+func newCertReloader(cfg *Cfg) (*transportgrpc.CertReloader, error) {
+	minVersions := map[string]uint16{"1.2": tls.VersionTLS12, "1.3": tls.VersionTLS13}
+	minVersion, ok := minVersions[cfg.TLS.MinVersion]
+	if !ok {
+		return nil, fmt.Errorf("unsupported minimum tls version %q", cfg.TLS.MinVersion)
+	}
+	return transportgrpc.NewCertReloader(transportgrpc.TLSConfig{
+		CertFile:          cfg.TLS.CertFile,
+		KeyFile:           cfg.TLS.KeyFile,
+		ClientCAFile:      cfg.TLS.ClientCAFile,
+		RequireClientCert: cfg.TLS.RequireClientCert,
+		MinVersion:        minVersion,
+	})
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/jacktantram/user-service/internal/replay"
+	"github.com/jacktantram/user-service/internal/store"
+	"github.com/jacktantram/user-service/pkg/driver/v1/config"
+	"github.com/jacktantram/user-service/pkg/driver/v1/kafka"
+	v1 "github.com/jacktantram/user-service/pkg/driver/v1/postgres"
+	log "github.com/sirupsen/logrus"
+)
+
+// runReplay republishes events for stored users, i.e.
+//
+//	app replay -mode=state -countries=GBR,DEU -updated-since=2023-01-01T00:00:00Z -rate=100 -dry-run
+func runReplay(args []string) {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	var (
+		mode         = flags.String("mode", string(replay.ModeCreated), "event to republish, created or state")
+		ids          = flags.String("ids", "", "comma separated user ids to replay")
+		countries    = flags.String("countries", "", "comma separated countries to replay")
+		updatedSince = flags.String("updated-since", "", "only replay users created or updated since this RFC3339 time")
+		rate         = flags.Int("rate", 100, "maximum events published per second, 0 is unlimited")
+		batchSize    = flags.Uint64("batch-size", 100, "number of users read from the database at a time")
+		dryRun       = flags.Bool("dry-run", false, "log the events that would be published without publishing")
+	)
+	if err := flags.Parse(args); err != nil {
+		log.WithError(err).Fatal("unable to parse replay flags")
+	}
+
+	filter := store.ScanFilter{IDs: splitList(*ids), Countries: splitList(*countries)}
+	if *updatedSince != "" {
+		since, err := time.Parse(time.RFC3339, *updatedSince)
+		if err != nil {
+			log.WithError(err).Fatal("updated-since must be an RFC3339 time")
+		}
+		filter.UpdatedSince = since
+	}
+
+	cfg := &Cfg{}
+	if err := config.LoadConfig(cfg); err != nil {
+		log.WithError(err).Fatalf("unable to load config")
+	}
+	topics, err := cfg.topics()
+	if err != nil {
+		log.WithError(err).Fatal("invalid kafka topic name")
+	}
+	topic := topics.Created
+	if replay.Mode(*mode) == replay.ModeState {
+		topic = topics.State
+	}
+
+	client, err := v1.NewClient(cfg.DatabaseURI, "users")
+	if err != nil {
+		log.WithError(err).Fatal("failed to setup postgres client")
+	}
+	defer client.DB.Close()
+
+	producer, err := kafka.NewSyncProducer(kafka.ProducerConfig{}, cfg.Kafka.Hosts...)
+	if err != nil {
+		log.WithError(err).Fatal("unable to create kafka producer")
+	}
+
+	replayer, err := replay.NewReplayer(store.NewStore(client), producer, replay.Config{
+		Mode:      replay.Mode(*mode),
+		Topic:     topic,
+		Filter:    filter,
+		BatchSize: *batchSize,
+		Rate:      *rate,
+		DryRun:    *dryRun,
+	})
+	if err != nil {
+		log.WithError(err).Fatal("unable to create replayer")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if _, err = replayer.Run(ctx); err != nil {
+		log.WithError(err).Fatal("replay failed")
+	}
+}
+
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
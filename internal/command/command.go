@@ -0,0 +1,270 @@
+package command
+
+//go:generate mockgen -source=command.go -destination=mocks/mock_command.go -package=mocks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bufbuild/protovalidate-go"
+	commandsV1 "github.com/jacktantram/user-service/build/go/commands/user/v1"
+	v1 "github.com/jacktantram/user-service/build/go/shared/user/v1"
+	"github.com/jacktantram/user-service/internal/domain"
+	"github.com/jacktantram/user-service/internal/service"
+	"github.com/jacktantram/user-service/pkg/consumer"
+	"github.com/jacktantram/user-service/pkg/logging"
+	uuid "github.com/kevinburke/go.uuid"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Reasons a command failed.
+const (
+	ReasonInvalidArgument    = "INVALID_ARGUMENT"
+	ReasonUserNotFound       = "USER_NOT_FOUND"
+	ReasonEmailAlreadyExists = "EMAIL_ALREADY_EXISTS"
+	ReasonEtagMismatch       = "ETAG_MISMATCH"
+)
+
+const (
+	defaultRetryBackoff = 100 * time.Millisecond
+	maxRetryBackoff     = 30 * time.Second
+)
+
+// errCommandFailed rolls back the transaction of a command that was rejected.
+var errCommandFailed = errors.New("command failed")
+
+// Service operations commands are executed through.
+type Service interface {
+	CreateUser(ctx context.Context, user *v1.User) error
+	UpdateUser(ctx context.Context, userToUpdate *v1.User, updateFields []v1.UpdateUserField, etag string) error
+	DeleteUser(ctx context.Context, id string, etag string) error
+	EraseUser(ctx context.Context, id string) error
+}
+
+// Store records the results of processed commands.
+type Store interface {
+	ExecInTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+	GetCommandResult(ctx context.Context, commandID string) (*commandsV1.UserCommandResult, error)
+	AddCommandResult(ctx context.Context, result *commandsV1.UserCommandResult) error
+}
+
+// Producer implementation for producing command results
+type Producer interface {
+	ProduceKeyedMessage(ctx context.Context, topic string, key string, msg proto.Message) (partition int32, offset int64, err error)
+}
+
+// Handler executes user commands and publishes their results.
+type Handler struct {
+	svc         Service
+	s           Store
+	p           Producer
+	resultTopic string
+	validator   *protovalidate.Validator
+}
+
+// NewHandler creates a new handler, validating users against the constraints of their proto message.
+func NewHandler(svc Service, store Store, p Producer, resultTopic string) (Handler, error) {
+	validator, err := protovalidate.New(protovalidate.WithMessages(&v1.User{}))
+	if err != nil {
+		return Handler{}, fmt.Errorf("unable to create validator: %w", err)
+	}
+	return Handler{svc: svc, s: store, p: p, resultTopic: resultTopic, validator: validator}, nil
+}
+
+// Handle executes a command and publishes its result keyed by command ID.
+// The command and its result are recorded in the same transaction, so a redelivered
+// command publishes the original result again instead of being applied twice. The user events of
+// the command are only published once the transaction has committed.
+// Rejected commands publish a failed result, an error is only returned if the command should be retried.
+func (h Handler) Handle(ctx context.Context, cmd *commandsV1.UserCommand) error {
+	if cmd.GetCommandId() == "" {
+		return h.publish(ctx, failed(cmd, ReasonInvalidArgument, errors.New("command id must be provided")))
+	}
+
+	var result *commandsV1.UserCommandResult
+	txCtx, publishEvents := service.DeferEvents(ctx)
+	err := h.s.ExecInTransaction(txCtx, func(ctx context.Context) error {
+		existing, err := h.s.GetCommandResult(ctx, cmd.CommandId)
+		if err == nil {
+			logging.FromContext(ctx).WithField("command_id", cmd.CommandId).Info("command has already been processed")
+			result = existing
+			return nil
+		}
+		if !errors.Is(err, domain.ErrNoCommandResult) {
+			return fmt.Errorf("unable to get command result: %w", err)
+		}
+		if result, err = h.execute(ctx, cmd); err != nil {
+			return err
+		}
+		if result.Status == commandsV1.UserCommandResult_STATUS_FAILED {
+			return errCommandFailed
+		}
+		return h.s.AddCommandResult(ctx, result)
+	})
+	if errors.Is(err, errCommandFailed) {
+		// the failure is recorded outside of the rolled back transaction
+		err = h.s.AddCommandResult(ctx, result)
+	}
+	if err != nil {
+		return err
+	}
+	publishEvents(ctx)
+	return h.publish(ctx, result)
+}
+
+// execute applies a command. Rejected commands return a failed result rather than an error.
+func (h Handler) execute(ctx context.Context, cmd *commandsV1.UserCommand) (*commandsV1.UserCommandResult, error) {
+	var (
+		user *v1.User
+		err  error
+	)
+	switch c := cmd.Command.(type) {
+	case *commandsV1.UserCommand_Create:
+		user = c.Create.GetUser()
+		if err = h.validateUser(user); err != nil {
+			return failed(cmd, ReasonInvalidArgument, err), nil
+		}
+		err = h.svc.CreateUser(ctx, user)
+	case *commandsV1.UserCommand_Update:
+		user = c.Update.GetUser()
+		if err = h.validateUpdate(c.Update); err != nil {
+			return failed(cmd, ReasonInvalidArgument, err), nil
+		}
+		err = h.svc.UpdateUser(ctx, user, c.Update.GetUpdateFields(), c.Update.GetEtag())
+	case *commandsV1.UserCommand_Delete:
+		if err = validateID(c.Delete.GetId()); err != nil {
+			return failed(cmd, ReasonInvalidArgument, err), nil
+		}
+		err = h.svc.DeleteUser(ctx, c.Delete.GetId(), c.Delete.GetEtag())
+	case *commandsV1.UserCommand_Erase:
+		if err = validateID(c.Erase.GetId()); err != nil {
+			return failed(cmd, ReasonInvalidArgument, err), nil
+		}
+		err = h.svc.EraseUser(ctx, c.Erase.GetId())
+	default:
+		return failed(cmd, ReasonInvalidArgument, errors.New("command must be provided")), nil
+	}
+
+	switch {
+	case errors.Is(err, domain.ErrNoUser):
+		return failed(cmd, ReasonUserNotFound, err), nil
+	case errors.Is(err, domain.ErrCreateUserEmailUnique):
+		return failed(cmd, ReasonEmailAlreadyExists, err), nil
+	case errors.Is(err, domain.ErrUserEtagMismatch):
+		return failed(cmd, ReasonEtagMismatch, err), nil
+	case err != nil:
+		return nil, err
+	}
+	result := &commandsV1.UserCommandResult{
+		CommandId:   cmd.CommandId,
+		Status:      commandsV1.UserCommandResult_STATUS_SUCCEEDED,
+		ProcessedAt: timestamppb.Now(),
+	}
+	if user != nil {
+		// results are stored and published, so they must not carry the password
+		result.User = domain.RedactUser(user)
+	}
+	return result, nil
+}
+
+// HandleMessage decodes and handles a command read from Kafka, it is registered with a consumer.Consumer.
+// Errors are retried with backoff until the context is done, blocking the partition so commands for a user
+// stay in order. Messages that can't be decoded are skipped.
+func (h Handler) HandleMessage(ctx context.Context, msg consumer.Message) error {
+	ctx = logging.WithLogger(ctx, logging.FromContext(ctx).
+		WithFields(log.Fields{"topic_name": msg.Topic, "partition": msg.Partition, "offset": msg.Offset}))
+	var cmd commandsV1.UserCommand
+	if err := proto.Unmarshal(msg.Value, &cmd); err != nil {
+		logging.FromContext(ctx).WithError(err).Error("unable to unmarshal command, skipping")
+		return nil
+	}
+	// the events of the command are correlated with the request that sent it, or otherwise the command
+	ctx = logging.WithRequestID(ctx, messageRequestID(msg, cmd.CommandId))
+	logger := logging.FromContext(ctx)
+
+	backoff := defaultRetryBackoff
+	for {
+		err := h.Handle(ctx, &cmd)
+		if err == nil {
+			return nil
+		}
+		logger.WithError(err).WithField("command_id", cmd.CommandId).Error("unable to handle command, retrying")
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if backoff *= 2; backoff > maxRetryBackoff {
+			backoff = maxRetryBackoff
+		}
+	}
+}
+
+func (h Handler) publish(ctx context.Context, result *commandsV1.UserCommandResult) error {
+	if _, _, err := h.p.ProduceKeyedMessage(ctx, h.resultTopic, result.CommandId, result); err != nil {
+		return fmt.Errorf("unable to produce command result: %w", err)
+	}
+	return nil
+}
+
+func (h Handler) validateUser(user *v1.User) error {
+	if user == nil {
+		return errors.New("user must be provided")
+	}
+	return h.validator.Validate(user)
+}
+
+func (h Handler) validateUpdate(update *commandsV1.UpdateUserCommand) error {
+	if err := h.validateUser(update.GetUser()); err != nil {
+		return err
+	}
+	if err := validateID(update.GetUser().GetId()); err != nil {
+		return err
+	}
+	fields := make(map[v1.UpdateUserField]struct{}, len(update.GetUpdateFields()))
+	for _, field := range update.GetUpdateFields() {
+		if field == v1.UpdateUserField_UPDATE_USER_FIELD_UNSPECIFIED {
+			return errors.New("update fields must not be the unspecified value")
+		}
+		if _, ok := fields[field]; ok {
+			return errors.New("should only input unique update fields")
+		}
+		fields[field] = struct{}{}
+	}
+	if len(fields) == 0 {
+		return errors.New("at least one update field must be provided")
+	}
+	return nil
+}
+
+func validateID(id string) error {
+	if id == "" {
+		return errors.New("user id must be provided")
+	}
+	if _, err := uuid.FromString(id); err != nil {
+		return errors.New("user id must be in the UUID format")
+	}
+	return nil
+}
+
+func failed(cmd *commandsV1.UserCommand, reason string, err error) *commandsV1.UserCommandResult {
+	return &commandsV1.UserCommandResult{
+		CommandId:   cmd.GetCommandId(),
+		Status:      commandsV1.UserCommandResult_STATUS_FAILED,
+		Reason:      reason,
+		Error:       err.Error(),
+		ProcessedAt: timestamppb.Now(),
+	}
+}
+
+// messageRequestID returns the x-request-id header of a message, or fallback if it has none.
+func messageRequestID(msg consumer.Message, fallback string) string {
+	if id := msg.Headers[logging.RequestIDHeader]; len(id) > 0 {
+		return string(id)
+	}
+	return fallback
+}
@@ -0,0 +1,360 @@
+package command_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	commandsV1 "github.com/jacktantram/user-service/build/go/commands/user/v1"
+	v1 "github.com/jacktantram/user-service/build/go/shared/user/v1"
+	"github.com/jacktantram/user-service/internal/command"
+	"github.com/jacktantram/user-service/internal/command/mocks"
+	"github.com/jacktantram/user-service/internal/domain"
+	"github.com/jacktantram/user-service/internal/service"
+	serviceMocks "github.com/jacktantram/user-service/internal/service/mocks"
+	"github.com/jacktantram/user-service/pkg/consumer"
+	"github.com/jacktantram/user-service/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	commandID   = "c6a4d2b4-5a6f-4c1e-9c43-3f0f6d2c1b7a"
+	userID      = "a8bdce5a-31dc-4647-98b5-ce9cb343138f"
+	resultTopic = "usercommand-completed_v1"
+)
+
+func newUser() *v1.User {
+	return &v1.User{
+		Id: userID, FirstName: "John", LastName: "Smith", Nickname: "js", Password: "password",
+		Email: "john@example.com", Country: "GBR",
+	}
+}
+
+type mocksSet struct {
+	svc      *mocks.MockService
+	store    *mocks.MockStore
+	producer *mocks.MockProducer
+}
+
+func inTransaction(m mocksSet) {
+	m.store.
+		EXPECT().
+		ExecInTransaction(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, fn func(ctx context.Context) error) error {
+			return fn(ctx)
+		})
+}
+
+func TestHandler_Handle(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name       string
+		cmd        *commandsV1.UserCommand
+		setup      func(m mocksSet)
+		wantStatus commandsV1.UserCommandResult_Status
+		wantReason string
+		wantErr    bool
+	}{
+		{
+			name: "should create a user and record the result without the password",
+			cmd: &commandsV1.UserCommand{CommandId: commandID,
+				Command: &commandsV1.UserCommand_Create{Create: &commandsV1.CreateUserCommand{User: newUser()}}},
+			setup: func(m mocksSet) {
+				inTransaction(m)
+				m.store.EXPECT().GetCommandResult(gomock.Any(), commandID).Return(nil, domain.ErrNoCommandResult)
+				m.svc.EXPECT().CreateUser(gomock.Any(), gomock.Any()).Return(nil)
+				m.store.EXPECT().AddCommandResult(gomock.Any(), gomock.Any()).Return(nil)
+			},
+			wantStatus: commandsV1.UserCommandResult_STATUS_SUCCEEDED,
+		},
+		{
+			name: "should update a user",
+			cmd: &commandsV1.UserCommand{CommandId: commandID,
+				Command: &commandsV1.UserCommand_Update{Update: &commandsV1.UpdateUserCommand{User: newUser(),
+					UpdateFields: []v1.UpdateUserField{v1.UpdateUserField_UPDATE_USER_FIELD_FIRST_NAME}}}},
+			setup: func(m mocksSet) {
+				inTransaction(m)
+				m.store.EXPECT().GetCommandResult(gomock.Any(), commandID).Return(nil, domain.ErrNoCommandResult)
+				m.svc.EXPECT().UpdateUser(gomock.Any(), gomock.Any(),
+					[]v1.UpdateUserField{v1.UpdateUserField_UPDATE_USER_FIELD_FIRST_NAME}, "").Return(nil)
+				m.store.EXPECT().AddCommandResult(gomock.Any(), gomock.Any()).Return(nil)
+			},
+			wantStatus: commandsV1.UserCommandResult_STATUS_SUCCEEDED,
+		},
+		{
+			name: "should erase a user",
+			cmd: &commandsV1.UserCommand{CommandId: commandID,
+				Command: &commandsV1.UserCommand_Erase{Erase: &commandsV1.EraseUserCommand{Id: userID}}},
+			setup: func(m mocksSet) {
+				inTransaction(m)
+				m.store.EXPECT().GetCommandResult(gomock.Any(), commandID).Return(nil, domain.ErrNoCommandResult)
+				m.svc.EXPECT().EraseUser(gomock.Any(), userID).Return(nil)
+				m.store.EXPECT().AddCommandResult(gomock.Any(), gomock.Any()).Return(nil)
+			},
+			wantStatus: commandsV1.UserCommandResult_STATUS_SUCCEEDED,
+		},
+		{
+			name: "should publish the recorded result of a redelivered command without applying it",
+			cmd: &commandsV1.UserCommand{CommandId: commandID,
+				Command: &commandsV1.UserCommand_Delete{Delete: &commandsV1.DeleteUserCommand{Id: userID}}},
+			setup: func(m mocksSet) {
+				inTransaction(m)
+				m.store.EXPECT().GetCommandResult(gomock.Any(), commandID).Return(&commandsV1.UserCommandResult{
+					CommandId: commandID, Status: commandsV1.UserCommandResult_STATUS_SUCCEEDED}, nil)
+				m.svc.EXPECT().DeleteUser(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+				m.store.EXPECT().AddCommandResult(gomock.Any(), gomock.Any()).Times(0)
+			},
+			wantStatus: commandsV1.UserCommandResult_STATUS_SUCCEEDED,
+		},
+		{
+			name: "should record a failed result for a user that does not exist",
+			cmd: &commandsV1.UserCommand{CommandId: commandID,
+				Command: &commandsV1.UserCommand_Delete{Delete: &commandsV1.DeleteUserCommand{Id: userID}}},
+			setup: func(m mocksSet) {
+				m.store.
+					EXPECT().
+					ExecInTransaction(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(ctx context.Context, fn func(ctx context.Context) error) error {
+						err := fn(ctx)
+						assert.Error(t, err, "transaction should be rolled back")
+						return err
+					})
+				m.store.EXPECT().GetCommandResult(gomock.Any(), commandID).Return(nil, domain.ErrNoCommandResult)
+				m.svc.EXPECT().DeleteUser(gomock.Any(), userID, "").Return(domain.ErrNoUser)
+				m.store.EXPECT().AddCommandResult(gomock.Any(), gomock.Any()).Return(nil)
+			},
+			wantStatus: commandsV1.UserCommandResult_STATUS_FAILED,
+			wantReason: command.ReasonUserNotFound,
+		},
+		{
+			name: "should fail a delete of a user that has changed since the etag",
+			cmd: &commandsV1.UserCommand{CommandId: commandID,
+				Command: &commandsV1.UserCommand_Delete{Delete: &commandsV1.DeleteUserCommand{Id: userID, Etag: "1"}}},
+			setup: func(m mocksSet) {
+				inTransaction(m)
+				m.store.EXPECT().GetCommandResult(gomock.Any(), commandID).Return(nil, domain.ErrNoCommandResult)
+				m.svc.EXPECT().DeleteUser(gomock.Any(), userID, "1").Return(domain.ErrUserEtagMismatch)
+				m.store.EXPECT().AddCommandResult(gomock.Any(), gomock.Any()).Return(nil)
+			},
+			wantStatus: commandsV1.UserCommandResult_STATUS_FAILED,
+			wantReason: command.ReasonEtagMismatch,
+		},
+		{
+			name: "should fail a create with an email that already exists",
+			cmd: &commandsV1.UserCommand{CommandId: commandID,
+				Command: &commandsV1.UserCommand_Create{Create: &commandsV1.CreateUserCommand{User: newUser()}}},
+			setup: func(m mocksSet) {
+				inTransaction(m)
+				m.store.EXPECT().GetCommandResult(gomock.Any(), commandID).Return(nil, domain.ErrNoCommandResult)
+				m.svc.EXPECT().CreateUser(gomock.Any(), gomock.Any()).Return(domain.ErrCreateUserEmailUnique)
+				m.store.EXPECT().AddCommandResult(gomock.Any(), gomock.Any()).Return(nil)
+			},
+			wantStatus: commandsV1.UserCommandResult_STATUS_FAILED,
+			wantReason: command.ReasonEmailAlreadyExists,
+		},
+		{
+			name: "should fail a create with an invalid user without calling the service",
+			cmd: &commandsV1.UserCommand{CommandId: commandID,
+				Command: &commandsV1.UserCommand_Create{Create: &commandsV1.CreateUserCommand{User: func() *v1.User {
+					u := newUser()
+					u.Email = "not-an-email"
+					return u
+				}()}}},
+			setup: func(m mocksSet) {
+				inTransaction(m)
+				m.store.EXPECT().GetCommandResult(gomock.Any(), commandID).Return(nil, domain.ErrNoCommandResult)
+				m.store.EXPECT().AddCommandResult(gomock.Any(), gomock.Any()).Return(nil)
+			},
+			wantStatus: commandsV1.UserCommandResult_STATUS_FAILED,
+			wantReason: command.ReasonInvalidArgument,
+		},
+		{
+			name: "should fail an invalid command without calling the service",
+			cmd: &commandsV1.UserCommand{CommandId: commandID,
+				Command: &commandsV1.UserCommand_Erase{Erase: &commandsV1.EraseUserCommand{Id: "not-a-uuid"}}},
+			setup: func(m mocksSet) {
+				inTransaction(m)
+				m.store.EXPECT().GetCommandResult(gomock.Any(), commandID).Return(nil, domain.ErrNoCommandResult)
+				m.store.EXPECT().AddCommandResult(gomock.Any(), gomock.Any()).Return(nil)
+			},
+			wantStatus: commandsV1.UserCommandResult_STATUS_FAILED,
+			wantReason: command.ReasonInvalidArgument,
+		},
+		{
+			name: "should fail a command without an id",
+			cmd: &commandsV1.UserCommand{
+				Command: &commandsV1.UserCommand_Delete{Delete: &commandsV1.DeleteUserCommand{Id: userID}}},
+			wantStatus: commandsV1.UserCommandResult_STATUS_FAILED,
+			wantReason: command.ReasonInvalidArgument,
+		},
+		{
+			name: "should return an error to retry if the service fails",
+			cmd: &commandsV1.UserCommand{CommandId: commandID,
+				Command: &commandsV1.UserCommand_Delete{Delete: &commandsV1.DeleteUserCommand{Id: userID}}},
+			setup: func(m mocksSet) {
+				inTransaction(m)
+				m.store.EXPECT().GetCommandResult(gomock.Any(), commandID).Return(nil, domain.ErrNoCommandResult)
+				m.svc.EXPECT().DeleteUser(gomock.Any(), userID, "").Return(errors.New("connection refused"))
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			m := mocksSet{
+				svc:      mocks.NewMockService(ctrl),
+				store:    mocks.NewMockStore(ctrl),
+				producer: mocks.NewMockProducer(ctrl),
+			}
+			if tt.setup != nil {
+				tt.setup(m)
+			}
+			var published *commandsV1.UserCommandResult
+			if !tt.wantErr {
+				m.producer.
+					EXPECT().
+					ProduceKeyedMessage(gomock.Any(), resultTopic, tt.cmd.CommandId, gomock.Any()).
+					DoAndReturn(func(ctx context.Context, topic string, key string, msg proto.Message) (int32, int64, error) {
+						published = msg.(*commandsV1.UserCommandResult)
+						return 0, 0, nil
+					})
+			}
+
+			h, err := command.NewHandler(m.svc, m.store, m.producer, resultTopic)
+			require.NoError(t, err)
+			err = h.Handle(context.Background(), tt.cmd)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.NotNil(t, published)
+			assert.Equal(t, tt.cmd.CommandId, published.CommandId)
+			assert.Equal(t, tt.wantStatus, published.Status)
+			assert.Equal(t, tt.wantReason, published.Reason)
+			assert.Empty(t, published.GetUser().GetPassword(), "results should not carry the password")
+		})
+	}
+}
+
+func TestHandler_Handle_Events(t *testing.T) {
+	t.Parallel()
+	cmd := &commandsV1.UserCommand{CommandId: commandID,
+		Command: &commandsV1.UserCommand_Erase{Erase: &commandsV1.EraseUserCommand{Id: userID}}}
+
+	t.Run("should publish the events of a command once its transaction has committed", func(t *testing.T) {
+		t.Parallel()
+		ctrl := gomock.NewController(t)
+		userStore, eventProducer := serviceMocks.NewMockUserStore(ctrl), serviceMocks.NewMockProducer(ctrl)
+		m := mocksSet{store: mocks.NewMockStore(ctrl), producer: mocks.NewMockProducer(ctrl)}
+
+		var committed bool
+		m.store.
+			EXPECT().
+			ExecInTransaction(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, fn func(ctx context.Context) error) error {
+				err := fn(ctx)
+				committed = true
+				return err
+			})
+		m.store.EXPECT().GetCommandResult(gomock.Any(), commandID).Return(nil, domain.ErrNoCommandResult)
+		userStore.EXPECT().DeleteUser(gomock.Any(), userID, "").Return(nil)
+		m.store.EXPECT().AddCommandResult(gomock.Any(), gomock.Any()).Return(nil)
+		eventProducer.EXPECT().ProduceMessage(gomock.Any(), "user-deleted_v1", gomock.Any()).
+			DoAndReturn(func(ctx context.Context, topic string, msg proto.Message) (int32, int64, error) {
+				assert.True(t, committed, "should publish after the transaction")
+				return 0, 0, nil
+			})
+		eventProducer.EXPECT().ProduceKeyedMessage(gomock.Any(), "users-state_v1", userID, nil).Return(int32(0), int64(0), nil)
+		m.producer.EXPECT().ProduceKeyedMessage(gomock.Any(), resultTopic, commandID, gomock.Any()).Return(int32(0), int64(0), nil)
+
+		h, err := command.NewHandler(service.NewService(userStore, eventProducer), m.store, m.producer, resultTopic)
+		require.NoError(t, err)
+		require.NoError(t, h.Handle(context.Background(), cmd))
+	})
+
+	t.Run("should not publish the events of a command whose transaction is rolled back", func(t *testing.T) {
+		t.Parallel()
+		ctrl := gomock.NewController(t)
+		userStore, eventProducer := serviceMocks.NewMockUserStore(ctrl), serviceMocks.NewMockProducer(ctrl)
+		m := mocksSet{store: mocks.NewMockStore(ctrl), producer: mocks.NewMockProducer(ctrl)}
+
+		inTransaction(m)
+		m.store.EXPECT().GetCommandResult(gomock.Any(), commandID).Return(nil, domain.ErrNoCommandResult)
+		userStore.EXPECT().DeleteUser(gomock.Any(), userID, "").Return(nil)
+		m.store.EXPECT().AddCommandResult(gomock.Any(), gomock.Any()).Return(errors.New("duplicate key"))
+
+		h, err := command.NewHandler(service.NewService(userStore, eventProducer), m.store, m.producer, resultTopic)
+		require.NoError(t, err)
+		assert.Error(t, h.Handle(context.Background(), cmd))
+	})
+}
+
+func TestHandler_HandleMessage(t *testing.T) {
+	t.Parallel()
+
+	t.Run("should skip messages that can't be decoded", func(t *testing.T) {
+		t.Parallel()
+		ctrl := gomock.NewController(t)
+		h, err := command.NewHandler(mocks.NewMockService(ctrl), mocks.NewMockStore(ctrl), mocks.NewMockProducer(ctrl), resultTopic)
+		require.NoError(t, err)
+		assert.NoError(t, h.HandleMessage(context.Background(), consumer.Message{Value: []byte("not a proto")}))
+	})
+
+	t.Run("should retry until the command is handled", func(t *testing.T) {
+		t.Parallel()
+		ctrl := gomock.NewController(t)
+		m := mocksSet{
+			svc:      mocks.NewMockService(ctrl),
+			store:    mocks.NewMockStore(ctrl),
+			producer: mocks.NewMockProducer(ctrl),
+		}
+		m.store.
+			EXPECT().
+			ExecInTransaction(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, fn func(ctx context.Context) error) error {
+				return fn(ctx)
+			}).Times(2)
+		gomock.InOrder(
+			m.store.EXPECT().GetCommandResult(gomock.Any(), commandID).Return(nil, errors.New("connection refused")),
+			m.store.EXPECT().GetCommandResult(gomock.Any(), commandID).Return(&commandsV1.UserCommandResult{CommandId: commandID}, nil),
+		)
+		m.producer.EXPECT().ProduceKeyedMessage(gomock.Any(), resultTopic, commandID, gomock.Any()).Return(int32(0), int64(0), nil)
+
+		value, err := proto.Marshal(&commandsV1.UserCommand{CommandId: commandID})
+		require.NoError(t, err)
+		h, err := command.NewHandler(m.svc, m.store, m.producer, resultTopic)
+		require.NoError(t, err)
+		assert.NoError(t, h.HandleMessage(context.Background(), consumer.Message{Value: value}))
+	})
+
+	t.Run("should correlate the command with the request id header", func(t *testing.T) {
+		t.Parallel()
+		ctrl := gomock.NewController(t)
+		m := mocksSet{
+			svc:      mocks.NewMockService(ctrl),
+			store:    mocks.NewMockStore(ctrl),
+			producer: mocks.NewMockProducer(ctrl),
+		}
+		inTransaction(m)
+		m.store.EXPECT().GetCommandResult(gomock.Any(), commandID).Return(&commandsV1.UserCommandResult{CommandId: commandID}, nil)
+		m.producer.EXPECT().ProduceKeyedMessage(gomock.Any(), resultTopic, commandID, gomock.Any()).
+			DoAndReturn(func(ctx context.Context, topic string, key string, msg proto.Message) (int32, int64, error) {
+				assert.Equal(t, "a-request-id", logging.RequestIDFromContext(ctx))
+				return 0, 0, nil
+			})
+
+		value, err := proto.Marshal(&commandsV1.UserCommand{CommandId: commandID})
+		require.NoError(t, err)
+		h, err := command.NewHandler(m.svc, m.store, m.producer, resultTopic)
+		require.NoError(t, err)
+		assert.NoError(t, h.HandleMessage(context.Background(), consumer.Message{Value: value,
+			Headers: map[string][]byte{logging.RequestIDHeader: []byte("a-request-id")}}))
+	})
+}
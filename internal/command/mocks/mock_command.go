@@ -0,0 +1,199 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: command.go
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	v1 "github.com/jacktantram/user-service/build/go/commands/user/v1"
+	v10 "github.com/jacktantram/user-service/build/go/shared/user/v1"
+	proto "google.golang.org/protobuf/proto"
+)
+
+// MockService is a mock of Service interface.
+type MockService struct {
+	ctrl     *gomock.Controller
+	recorder *MockServiceMockRecorder
+}
+
+// MockServiceMockRecorder is the mock recorder for MockService.
+type MockServiceMockRecorder struct {
+	mock *MockService
+}
+
+// NewMockService creates a new mock instance.
+func NewMockService(ctrl *gomock.Controller) *MockService {
+	mock := &MockService{ctrl: ctrl}
+	mock.recorder = &MockServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockService) EXPECT() *MockServiceMockRecorder {
+	return m.recorder
+}
+
+// CreateUser mocks base method.
+func (m *MockService) CreateUser(ctx context.Context, user *v10.User) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateUser", ctx, user)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateUser indicates an expected call of CreateUser.
+func (mr *MockServiceMockRecorder) CreateUser(ctx, user interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockService)(nil).CreateUser), ctx, user)
+}
+
+// DeleteUser mocks base method.
+func (m *MockService) DeleteUser(ctx context.Context, id, etag string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteUser", ctx, id, etag)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteUser indicates an expected call of DeleteUser.
+func (mr *MockServiceMockRecorder) DeleteUser(ctx, id, etag interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUser", reflect.TypeOf((*MockService)(nil).DeleteUser), ctx, id, etag)
+}
+
+// EraseUser mocks base method.
+func (m *MockService) EraseUser(ctx context.Context, id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EraseUser", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// EraseUser indicates an expected call of EraseUser.
+func (mr *MockServiceMockRecorder) EraseUser(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EraseUser", reflect.TypeOf((*MockService)(nil).EraseUser), ctx, id)
+}
+
+// UpdateUser mocks base method.
+func (m *MockService) UpdateUser(ctx context.Context, userToUpdate *v10.User, updateFields []v10.UpdateUserField, etag string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUser", ctx, userToUpdate, updateFields, etag)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateUser indicates an expected call of UpdateUser.
+func (mr *MockServiceMockRecorder) UpdateUser(ctx, userToUpdate, updateFields, etag interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUser", reflect.TypeOf((*MockService)(nil).UpdateUser), ctx, userToUpdate, updateFields, etag)
+}
+
+// MockStore is a mock of Store interface.
+type MockStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockStoreMockRecorder
+}
+
+// MockStoreMockRecorder is the mock recorder for MockStore.
+type MockStoreMockRecorder struct {
+	mock *MockStore
+}
+
+// NewMockStore creates a new mock instance.
+func NewMockStore(ctrl *gomock.Controller) *MockStore {
+	mock := &MockStore{ctrl: ctrl}
+	mock.recorder = &MockStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockStore) EXPECT() *MockStoreMockRecorder {
+	return m.recorder
+}
+
+// AddCommandResult mocks base method.
+func (m *MockStore) AddCommandResult(ctx context.Context, result *v1.UserCommandResult) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddCommandResult", ctx, result)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddCommandResult indicates an expected call of AddCommandResult.
+func (mr *MockStoreMockRecorder) AddCommandResult(ctx, result interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddCommandResult", reflect.TypeOf((*MockStore)(nil).AddCommandResult), ctx, result)
+}
+
+// ExecInTransaction mocks base method.
+func (m *MockStore) ExecInTransaction(ctx context.Context, fn func(context.Context) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExecInTransaction", ctx, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ExecInTransaction indicates an expected call of ExecInTransaction.
+func (mr *MockStoreMockRecorder) ExecInTransaction(ctx, fn interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExecInTransaction", reflect.TypeOf((*MockStore)(nil).ExecInTransaction), ctx, fn)
+}
+
+// GetCommandResult mocks base method.
+func (m *MockStore) GetCommandResult(ctx context.Context, commandID string) (*v1.UserCommandResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCommandResult", ctx, commandID)
+	ret0, _ := ret[0].(*v1.UserCommandResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCommandResult indicates an expected call of GetCommandResult.
+func (mr *MockStoreMockRecorder) GetCommandResult(ctx, commandID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCommandResult", reflect.TypeOf((*MockStore)(nil).GetCommandResult), ctx, commandID)
+}
+
+// MockProducer is a mock of Producer interface.
+type MockProducer struct {
+	ctrl     *gomock.Controller
+	recorder *MockProducerMockRecorder
+}
+
+// MockProducerMockRecorder is the mock recorder for MockProducer.
+type MockProducerMockRecorder struct {
+	mock *MockProducer
+}
+
+// NewMockProducer creates a new mock instance.
+func NewMockProducer(ctrl *gomock.Controller) *MockProducer {
+	mock := &MockProducer{ctrl: ctrl}
+	mock.recorder = &MockProducerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockProducer) EXPECT() *MockProducerMockRecorder {
+	return m.recorder
+}
+
+// ProduceKeyedMessage mocks base method.
+func (m *MockProducer) ProduceKeyedMessage(ctx context.Context, topic, key string, msg proto.Message) (int32, int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ProduceKeyedMessage", ctx, topic, key, msg)
+	ret0, _ := ret[0].(int32)
+	ret1, _ := ret[1].(int64)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// ProduceKeyedMessage indicates an expected call of ProduceKeyedMessage.
+func (mr *MockProducerMockRecorder) ProduceKeyedMessage(ctx, topic, key, msg interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProduceKeyedMessage", reflect.TypeOf((*MockProducer)(nil).ProduceKeyedMessage), ctx, topic, key, msg)
+}
@@ -0,0 +1,164 @@
+package deadletter
+
+//go:generate mockgen -source=deadletter.go -destination=mocks/mock_deadletter.go -package=mocks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	v1 "github.com/jacktantram/user-service/build/go/shared/user/v1"
+	"github.com/jacktantram/user-service/internal/domain"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/proto"
+
+	// registers the event types so dead letters can be rebuilt
+	_ "github.com/jacktantram/user-service/build/go/events/user/v1"
+	_ "github.com/jacktantram/user-service/build/go/events/user/v2"
+)
+
+const (
+	countTimeout = 2 * time.Second
+)
+
+// Store CRUD operations for dead letters.
+type Store interface {
+	GetDeadLetter(ctx context.Context, id string) (*domain.DeadLetter, error)
+	ListDeadLetters(ctx context.Context, offset uint64, limit uint64) ([]*domain.DeadLetter, error)
+	RecordDeadLetterAttempt(ctx context.Context, id string, publishErr string) error
+	DeleteDeadLetter(ctx context.Context, id string) error
+	PurgeDeadLetters(ctx context.Context) (int64, error)
+	CountDeadLetters(ctx context.Context) (int64, error)
+}
+
+// UserStore gets the current state of users, so state snapshots are rebuilt rather than republished stale.
+type UserStore interface {
+	GetUser(ctx context.Context, id string) (*v1.User, error)
+}
+
+// Producer implementation for producing events
+type Producer interface {
+	ProduceMessage(ctx context.Context, topic string, msg proto.Message) (partition int32, offset int64, err error)
+	ProduceKeyedMessage(ctx context.Context, topic string, key string, msg proto.Message) (partition int32, offset int64, err error)
+}
+
+// Manager inspects, retries and purges dead letters.
+type Manager struct {
+	s Store
+	u UserStore
+	p Producer
+}
+
+// NewManager creates a new manager
+func NewManager(store Store, users UserStore, p Producer) Manager {
+	return Manager{s: store, u: users, p: p}
+}
+
+// List lists a page of dead letters, oldest first.
+func (m Manager) List(ctx context.Context, offset uint64, limit uint64) ([]*domain.DeadLetter, error) {
+	return m.s.ListDeadLetters(ctx, offset, limit)
+}
+
+// Retry attempts to publish a dead letter again. On success the dead letter is removed,
+// otherwise its attempt count is incremented. State snapshots are rebuilt from the current user, or published as
+// a tombstone if the user has since been deleted, so a retry never overwrites a newer snapshot.
+func (m Manager) Retry(ctx context.Context, id string) error {
+	deadLetter, err := m.s.GetDeadLetter(ctx, id)
+	if err != nil {
+		return err
+	}
+	if publishErr := m.publish(ctx, deadLetter); publishErr != nil {
+		if err = m.s.RecordDeadLetterAttempt(ctx, id, publishErr.Error()); err != nil {
+			return fmt.Errorf("unable to record dead letter attempt: %w", err)
+		}
+		return fmt.Errorf("unable to publish dead letter: %w", publishErr)
+	}
+	return m.s.DeleteDeadLetter(ctx, id)
+}
+
+// RetryAll retries up to limit dead letters, returning the number that were published and that failed.
+func (m Manager) RetryAll(ctx context.Context, limit uint64) (published int, failed int, err error) {
+	deadLetters, err := m.s.ListDeadLetters(ctx, 0, limit)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, deadLetter := range deadLetters {
+		if err = m.Retry(ctx, deadLetter.ID.String()); err != nil {
+			log.WithError(err).WithFields(log.Fields{
+				"dead_letter_id": deadLetter.ID.String(), "topic_name": deadLetter.Topic,
+			}).Error("unable to retry dead letter")
+			failed++
+			continue
+		}
+		published++
+	}
+	return published, failed, nil
+}
+
+// Purge deletes a single dead letter.
+func (m Manager) Purge(ctx context.Context, id string) error {
+	return m.s.DeleteDeadLetter(ctx, id)
+}
+
+// PurgeAll deletes every dead letter, returning the number deleted.
+func (m Manager) PurgeAll(ctx context.Context) (int64, error) {
+	return m.s.PurgeDeadLetters(ctx)
+}
+
+func (m Manager) publish(ctx context.Context, deadLetter *domain.DeadLetter) error {
+	msg, err := deadLetter.Message()
+	if err != nil {
+		return err
+	}
+	if isStateSnapshot(deadLetter) {
+		if msg, err = m.currentState(ctx, deadLetter.Key); err != nil {
+			return err
+		}
+	}
+	if deadLetter.Key != "" {
+		_, _, err = m.p.ProduceKeyedMessage(ctx, deadLetter.Topic, deadLetter.Key, msg)
+		return err
+	}
+	_, _, err = m.p.ProduceMessage(ctx, deadLetter.Topic, msg)
+	return err
+}
+
+// currentState returns the snapshot of a user as it is now, nil if the user no longer exists.
+func (m Manager) currentState(ctx context.Context, id string) (proto.Message, error) {
+	user, err := m.u.GetUser(ctx, id)
+	if errors.Is(err, domain.ErrNoUser) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to get current state of user: %w", err)
+	}
+	return domain.RedactUser(user), nil
+}
+
+// isStateSnapshot reports whether a dead letter is a user snapshot or tombstone keyed by user ID.
+func isStateSnapshot(deadLetter *domain.DeadLetter) bool {
+	if deadLetter.Key == "" {
+		return false
+	}
+	return deadLetter.MessageType == "" ||
+		deadLetter.MessageType == string((&v1.User{}).ProtoReflect().Descriptor().FullName())
+}
+
+// NewSizeGauge creates a gauge reporting the number of dead letters held in the store.
+func NewSizeGauge(store Store) prometheus.GaugeFunc {
+	return prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "user_service_dead_letter_events",
+		Help: "Number of events that could not be published and are held in the dead letter store.",
+	}, func() float64 {
+		ctx, cancel := context.WithTimeout(context.Background(), countTimeout)
+		defer cancel()
+		count, err := store.CountDeadLetters(ctx)
+		if err != nil {
+			log.WithError(err).Error("unable to count dead letters")
+			return 0
+		}
+		return float64(count)
+	})
+}
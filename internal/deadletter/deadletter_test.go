@@ -0,0 +1,182 @@
+package deadletter_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	eventsV1 "github.com/jacktantram/user-service/build/go/events/user/v1"
+	v1 "github.com/jacktantram/user-service/build/go/shared/user/v1"
+	"github.com/jacktantram/user-service/internal/deadletter"
+	"github.com/jacktantram/user-service/internal/deadletter/mocks"
+	"github.com/jacktantram/user-service/internal/domain"
+	uuid "github.com/kevinburke/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func newDeadLetter(t *testing.T, topic string, key string, msg proto.Message) *domain.DeadLetter {
+	t.Helper()
+	d, err := domain.NewDeadLetter(topic, key, msg, errors.New("broker down"))
+	require.NoError(t, err)
+	d.ID = uuid.NewV4()
+	return d
+}
+
+func TestManager_Retry(t *testing.T) {
+	t.Parallel()
+	event := &eventsV1.UserCreatedEvent{User: &v1.User{Id: "a8bdce5a-31dc-4647-98b5-ce9cb343138f"}}
+	tests := []struct {
+		name       string
+		deadLetter func(t *testing.T) *domain.DeadLetter
+		setup      func(mockStore *mocks.MockStore, mockUsers *mocks.MockUserStore, mockProducer *mocks.MockProducer, d *domain.DeadLetter)
+		wantErr    bool
+	}{
+		{
+			name: "should publish the event and delete the dead letter",
+			deadLetter: func(t *testing.T) *domain.DeadLetter {
+				return newDeadLetter(t, "user-created_v1", "", event)
+			},
+			setup: func(mockStore *mocks.MockStore, mockUsers *mocks.MockUserStore, mockProducer *mocks.MockProducer,
+				d *domain.DeadLetter) {
+				mockStore.EXPECT().GetDeadLetter(gomock.Any(), d.ID.String()).Return(d, nil)
+				mockProducer.
+					EXPECT().
+					ProduceMessage(gomock.Any(), "user-created_v1", gomock.Any()).
+					DoAndReturn(func(ctx context.Context, topic string, msg proto.Message) (int32, int64, error) {
+						assert.True(t, proto.Equal(event, msg))
+						return 0, 0, nil
+					})
+				mockStore.EXPECT().DeleteDeadLetter(gomock.Any(), d.ID.String()).Return(nil)
+			},
+		},
+		{
+			name: "should publish keyed tombstones",
+			deadLetter: func(t *testing.T) *domain.DeadLetter {
+				return newDeadLetter(t, "users-state_v1", "a-user-id", nil)
+			},
+			setup: func(mockStore *mocks.MockStore, mockUsers *mocks.MockUserStore, mockProducer *mocks.MockProducer,
+				d *domain.DeadLetter) {
+				mockStore.EXPECT().GetDeadLetter(gomock.Any(), d.ID.String()).Return(d, nil)
+				mockUsers.EXPECT().GetUser(gomock.Any(), "a-user-id").Return(nil, domain.ErrNoUser)
+				mockProducer.
+					EXPECT().
+					ProduceKeyedMessage(gomock.Any(), "users-state_v1", "a-user-id", nil).
+					Return(int32(0), int64(0), nil)
+				mockStore.EXPECT().DeleteDeadLetter(gomock.Any(), d.ID.String()).Return(nil)
+			},
+		},
+		{
+			name: "should publish the current state of the user rather than a stale snapshot",
+			deadLetter: func(t *testing.T) *domain.DeadLetter {
+				return newDeadLetter(t, "users-state_v1", "a-user-id", &v1.User{Id: "a-user-id", FirstName: "John"})
+			},
+			setup: func(mockStore *mocks.MockStore, mockUsers *mocks.MockUserStore, mockProducer *mocks.MockProducer,
+				d *domain.DeadLetter) {
+				mockStore.EXPECT().GetDeadLetter(gomock.Any(), d.ID.String()).Return(d, nil)
+				mockUsers.
+					EXPECT().
+					GetUser(gomock.Any(), "a-user-id").
+					Return(&v1.User{Id: "a-user-id", FirstName: "Johnny", Password: "a-password"}, nil)
+				mockProducer.
+					EXPECT().
+					ProduceKeyedMessage(gomock.Any(), "users-state_v1", "a-user-id", gomock.Any()).
+					DoAndReturn(func(ctx context.Context, topic string, key string, msg proto.Message) (int32, int64, error) {
+						assert.True(t, proto.Equal(&v1.User{Id: "a-user-id", FirstName: "Johnny"}, msg))
+						return 0, 0, nil
+					})
+				mockStore.EXPECT().DeleteDeadLetter(gomock.Any(), d.ID.String()).Return(nil)
+			},
+		},
+		{
+			name: "should publish a tombstone for a snapshot of a user that has since been deleted",
+			deadLetter: func(t *testing.T) *domain.DeadLetter {
+				return newDeadLetter(t, "users-state_v1", "a-user-id", &v1.User{Id: "a-user-id", FirstName: "John"})
+			},
+			setup: func(mockStore *mocks.MockStore, mockUsers *mocks.MockUserStore, mockProducer *mocks.MockProducer,
+				d *domain.DeadLetter) {
+				mockStore.EXPECT().GetDeadLetter(gomock.Any(), d.ID.String()).Return(d, nil)
+				mockUsers.EXPECT().GetUser(gomock.Any(), "a-user-id").Return(nil, domain.ErrNoUser)
+				mockProducer.
+					EXPECT().
+					ProduceKeyedMessage(gomock.Any(), "users-state_v1", "a-user-id", nil).
+					Return(int32(0), int64(0), nil)
+				mockStore.EXPECT().DeleteDeadLetter(gomock.Any(), d.ID.String()).Return(nil)
+			},
+		},
+		{
+			name: "should record the attempt if the current state of the user can't be read",
+			deadLetter: func(t *testing.T) *domain.DeadLetter {
+				return newDeadLetter(t, "users-state_v1", "a-user-id", &v1.User{Id: "a-user-id"})
+			},
+			setup: func(mockStore *mocks.MockStore, mockUsers *mocks.MockUserStore, mockProducer *mocks.MockProducer,
+				d *domain.DeadLetter) {
+				mockStore.EXPECT().GetDeadLetter(gomock.Any(), d.ID.String()).Return(d, nil)
+				mockUsers.EXPECT().GetUser(gomock.Any(), "a-user-id").Return(nil, errors.New("connection refused"))
+				mockStore.EXPECT().RecordDeadLetterAttempt(gomock.Any(), d.ID.String(), gomock.Any()).Return(nil)
+				mockStore.EXPECT().DeleteDeadLetter(gomock.Any(), gomock.Any()).Times(0)
+			},
+			wantErr: true,
+		},
+		{
+			name: "should record the attempt if publishing fails",
+			deadLetter: func(t *testing.T) *domain.DeadLetter {
+				return newDeadLetter(t, "user-created_v1", "", event)
+			},
+			setup: func(mockStore *mocks.MockStore, mockUsers *mocks.MockUserStore, mockProducer *mocks.MockProducer,
+				d *domain.DeadLetter) {
+				mockStore.EXPECT().GetDeadLetter(gomock.Any(), d.ID.String()).Return(d, nil)
+				mockProducer.
+					EXPECT().
+					ProduceMessage(gomock.Any(), "user-created_v1", gomock.Any()).
+					Return(int32(0), int64(0), errors.New("still down"))
+				mockStore.EXPECT().RecordDeadLetterAttempt(gomock.Any(), d.ID.String(), "still down").Return(nil)
+				mockStore.EXPECT().DeleteDeadLetter(gomock.Any(), gomock.Any()).Times(0)
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			mockStore := mocks.NewMockStore(ctrl)
+			mockUsers := mocks.NewMockUserStore(ctrl)
+			mockProducer := mocks.NewMockProducer(ctrl)
+			d := tt.deadLetter(t)
+			tt.setup(mockStore, mockUsers, mockProducer, d)
+
+			err := deadletter.NewManager(mockStore, mockUsers, mockProducer).Retry(context.Background(), d.ID.String())
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestManager_RetryAll(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	mockStore := mocks.NewMockStore(ctrl)
+	mockProducer := mocks.NewMockProducer(ctrl)
+	event := &eventsV1.UserCreatedEvent{User: &v1.User{Id: "a8bdce5a-31dc-4647-98b5-ce9cb343138f"}}
+	ok, failing := newDeadLetter(t, "user-created_v1", "", event), newDeadLetter(t, "user-deleted_v1", "", event)
+
+	mockStore.EXPECT().ListDeadLetters(gomock.Any(), uint64(0), uint64(10)).Return([]*domain.DeadLetter{ok, failing}, nil)
+	mockStore.EXPECT().GetDeadLetter(gomock.Any(), ok.ID.String()).Return(ok, nil)
+	mockStore.EXPECT().GetDeadLetter(gomock.Any(), failing.ID.String()).Return(failing, nil)
+	mockProducer.EXPECT().ProduceMessage(gomock.Any(), "user-created_v1", gomock.Any()).Return(int32(0), int64(0), nil)
+	mockProducer.EXPECT().ProduceMessage(gomock.Any(), "user-deleted_v1", gomock.Any()).Return(int32(0), int64(0), errors.New("still down"))
+	mockStore.EXPECT().DeleteDeadLetter(gomock.Any(), ok.ID.String()).Return(nil)
+	mockStore.EXPECT().RecordDeadLetterAttempt(gomock.Any(), failing.ID.String(), "still down").Return(nil)
+
+	published, failed, err := deadletter.NewManager(mockStore, mocks.NewMockUserStore(ctrl), mockProducer).RetryAll(context.Background(), 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, published)
+	assert.Equal(t, 1, failed)
+}
@@ -0,0 +1,42 @@
+package deadletter
+
+import (
+	"context"
+
+	"github.com/jacktantram/user-service/internal/domain"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// TopicStore captures dead letters by publishing them to a dead letter topic.
+type TopicStore struct {
+	p     Producer
+	topic string
+	size  prometheus.Gauge
+}
+
+// NewTopicStore creates a new topic store. The gauge tracks the number of events
+// dead-lettered since the service started, as the size of a topic can't be queried.
+func NewTopicStore(p Producer, topic string) TopicStore {
+	return TopicStore{
+		p:     p,
+		topic: topic,
+		size: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "user_service_dead_letter_events",
+			Help: "Number of events that could not be published and were sent to the dead letter topic since startup.",
+		}),
+	}
+}
+
+// AddDeadLetter publishes a dead letter keyed by its original topic.
+func (t TopicStore) AddDeadLetter(ctx context.Context, deadLetter *domain.DeadLetter) error {
+	if _, _, err := t.p.ProduceKeyedMessage(ctx, t.topic, deadLetter.Topic, deadLetter.ToProto()); err != nil {
+		return err
+	}
+	t.size.Inc()
+	return nil
+}
+
+// Collector returns the gauge tracking the number of dead letters.
+func (t TopicStore) Collector() prometheus.Collector {
+	return t.size
+}
@@ -0,0 +1,82 @@
+package domain
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	deadLetterV1 "github.com/jacktantram/user-service/build/go/events/deadletter/v1"
+	uuid "github.com/kevinburke/go.uuid"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// DeadLetter defines an event that could not be published.
+type DeadLetter struct {
+	ID    uuid.UUID `db:"id"`
+	Topic string    `db:"topic"`
+	// Key of the event, empty if the event was not keyed.
+	Key string `db:"message_key"`
+	// MessageType is the full proto name of the event, empty for tombstones.
+	MessageType string       `db:"message_type"`
+	Payload     []byte       `db:"payload"`
+	Error       string       `db:"error"`
+	Attempts    int32        `db:"attempts"`
+	CreatedAt   time.Time    `db:"created_at"`
+	UpdatedAt   sql.NullTime `db:"updated_at"`
+}
+
+// NewDeadLetter creates a dead letter for an event that failed to publish.
+// A nil message is treated as a tombstone.
+func NewDeadLetter(topic string, key string, msg proto.Message, publishErr error) (*DeadLetter, error) {
+	d := &DeadLetter{
+		Topic:     topic,
+		Key:       key,
+		Attempts:  1,
+		CreatedAt: time.Now(),
+	}
+	if publishErr != nil {
+		d.Error = publishErr.Error()
+	}
+	if msg == nil {
+		return d, nil
+	}
+	payload, err := proto.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal dead letter payload: %w", err)
+	}
+	d.MessageType = string(msg.ProtoReflect().Descriptor().FullName())
+	d.Payload = payload
+	return d, nil
+}
+
+// Message rebuilds the original event. A nil message is returned for tombstones.
+func (d *DeadLetter) Message() (proto.Message, error) {
+	if d.MessageType == "" {
+		return nil, nil
+	}
+	msgType, err := protoregistry.GlobalTypes.FindMessageByName(protoreflect.FullName(d.MessageType))
+	if err != nil {
+		return nil, fmt.Errorf("unknown dead letter message type %s: %w", d.MessageType, err)
+	}
+	msg := msgType.New().Interface()
+	if err = proto.Unmarshal(d.Payload, msg); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal dead letter payload: %w", err)
+	}
+	return msg, nil
+}
+
+// ToProto converts a dead letter into a proto dead letter.
+func (d *DeadLetter) ToProto() *deadLetterV1.DeadLetter {
+	return &deadLetterV1.DeadLetter{
+		Topic:       d.Topic,
+		Key:         d.Key,
+		MessageType: d.MessageType,
+		Payload:     d.Payload,
+		Error:       d.Error,
+		Attempts:    d.Attempts,
+		CreatedAt:   timestamppb.New(d.CreatedAt),
+	}
+}
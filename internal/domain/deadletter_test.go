@@ -0,0 +1,45 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+
+	eventsV1 "github.com/jacktantram/user-service/build/go/events/user/v1"
+	v1 "github.com/jacktantram/user-service/build/go/shared/user/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestNewDeadLetter(t *testing.T) {
+	t.Parallel()
+	t.Run("should capture the event and rebuild it", func(t *testing.T) {
+		event := &eventsV1.UserCreatedEvent{User: &v1.User{Id: "a8bdce5a-31dc-4647-98b5-ce9cb343138f", FirstName: "John"}}
+
+		d, err := NewDeadLetter("user-created_v1", "", event, errors.New("broker down"))
+		require.NoError(t, err)
+		assert.Equal(t, "user-created_v1", d.Topic)
+		assert.Equal(t, "events.user.v1.UserCreatedEvent", d.MessageType)
+		assert.Equal(t, "broker down", d.Error)
+		assert.Equal(t, int32(1), d.Attempts)
+
+		msg, err := d.Message()
+		require.NoError(t, err)
+		assert.True(t, proto.Equal(event, msg))
+	})
+	t.Run("should capture tombstones", func(t *testing.T) {
+		d, err := NewDeadLetter("users-state_v1", "a-user-id", nil, errors.New("broker down"))
+		require.NoError(t, err)
+		assert.Empty(t, d.MessageType)
+		assert.Nil(t, d.Payload)
+
+		msg, err := d.Message()
+		require.NoError(t, err)
+		assert.Nil(t, msg)
+	})
+	t.Run("should error rebuilding an unknown message type", func(t *testing.T) {
+		d := &DeadLetter{MessageType: "events.user.v1.Unknown"}
+		_, err := d.Message()
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,77 @@
+package domain
+
+import (
+	eventsV2 "github.com/jacktantram/user-service/build/go/events/user/v2"
+	v1 "github.com/jacktantram/user-service/build/go/shared/user/v1"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+var (
+	// fields that are managed by the service and not considered a change to the user.
+	diffIgnoredFields = map[protoreflect.Name]struct{}{
+		"id":         {},
+		"created_at": {},
+		"updated_at": {},
+		"etag":       {},
+	}
+	// fields whose values must not be exposed in a change.
+	diffSensitiveFields = map[protoreflect.Name]struct{}{
+		"password": {},
+	}
+)
+
+// DiffUsers returns the fields that differ between two users along with their old and new values.
+// Values of sensitive fields such as password are omitted.
+func DiffUsers(before, after *v1.User) []*eventsV2.FieldChange {
+	beforeMsg, afterMsg := before.ProtoReflect(), after.ProtoReflect()
+
+	changes := make([]*eventsV2.FieldChange, 0)
+	fields := beforeMsg.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		if _, ok := diffIgnoredFields[field.Name()]; ok {
+			continue
+		}
+		oldValue, newValue := beforeMsg.Get(field), afterMsg.Get(field)
+		if valuesEqual(field, oldValue, newValue) {
+			continue
+		}
+
+		change := &eventsV2.FieldChange{Field: string(field.Name())}
+		if _, ok := diffSensitiveFields[field.Name()]; !ok {
+			change.OldValue = valueString(field, oldValue)
+			change.NewValue = valueString(field, newValue)
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// RedactUser returns a copy of user with sensitive fields such as password cleared, so it can be published.
+func RedactUser(user *v1.User) *v1.User {
+	redacted := proto.Clone(user).(*v1.User)
+	msg := redacted.ProtoReflect()
+	fields := msg.Descriptor().Fields()
+	for name := range diffSensitiveFields {
+		if field := fields.ByName(name); field != nil {
+			msg.Clear(field)
+		}
+	}
+	return redacted
+}
+
+func valuesEqual(field protoreflect.FieldDescriptor, a, b protoreflect.Value) bool {
+	if field.Kind() == protoreflect.MessageKind {
+		return proto.Equal(a.Message().Interface(), b.Message().Interface())
+	}
+	return a.Interface() == b.Interface()
+}
+
+func valueString(field protoreflect.FieldDescriptor, value protoreflect.Value) string {
+	if field.Kind() == protoreflect.MessageKind {
+		return prototext.MarshalOptions{}.Format(value.Message().Interface())
+	}
+	return value.String()
+}
@@ -0,0 +1,74 @@
+package domain
+
+import (
+	"testing"
+
+	eventsV2 "github.com/jacktantram/user-service/build/go/events/user/v2"
+	v1 "github.com/jacktantram/user-service/build/go/shared/user/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestDiffUsers(t *testing.T) {
+	t.Parallel()
+	before := &v1.User{
+		Id:        "a8bdce5a-31dc-4647-98b5-ce9cb343138f",
+		FirstName: "John",
+		LastName:  "Gopher",
+		Nickname:  "Goopher",
+		Password:  "a-password",
+		Email:     "jon@gopher.com",
+		Country:   "GBR",
+		CreatedAt: timestamppb.Now(),
+	}
+
+	t.Run("should return no changes for equal users", func(t *testing.T) {
+		assert.Empty(t, DiffUsers(before, before))
+	})
+	t.Run("should ignore service managed fields", func(t *testing.T) {
+		after := &v1.User{
+			Id:        before.Id,
+			FirstName: before.FirstName,
+			LastName:  before.LastName,
+			Nickname:  before.Nickname,
+			Password:  before.Password,
+			Email:     before.Email,
+			Country:   before.Country,
+			CreatedAt: before.CreatedAt,
+			UpdatedAt: timestamppb.Now(),
+		}
+		assert.Empty(t, DiffUsers(before, after))
+	})
+	t.Run("should return old and new values of changed fields", func(t *testing.T) {
+		after := &v1.User{
+			Id:        before.Id,
+			FirstName: "Johnny",
+			LastName:  before.LastName,
+			Nickname:  before.Nickname,
+			Password:  before.Password,
+			Email:     "johnny@gopher.com",
+			Country:   before.Country,
+			CreatedAt: before.CreatedAt,
+		}
+		changes := DiffUsers(before, after)
+		require.Len(t, changes, 2)
+		assert.Equal(t, []*eventsV2.FieldChange{
+			{Field: "first_name", OldValue: "John", NewValue: "Johnny"},
+			{Field: "email", OldValue: "jon@gopher.com", NewValue: "johnny@gopher.com"},
+		}, changes)
+	})
+	t.Run("should omit values of sensitive fields", func(t *testing.T) {
+		after := &v1.User{
+			Id:        before.Id,
+			FirstName: before.FirstName,
+			LastName:  before.LastName,
+			Nickname:  before.Nickname,
+			Password:  "another-password",
+			Email:     before.Email,
+			Country:   before.Country,
+			CreatedAt: before.CreatedAt,
+		}
+		assert.Equal(t, []*eventsV2.FieldChange{{Field: "password"}}, DiffUsers(before, after))
+	})
+}
@@ -0,0 +1,18 @@
+package domain
+
+import "time"
+
+// IdempotencyKey records a request made with an idempotency key and its response, so a retry of the
+// request returns the original response instead of being applied again.
+type IdempotencyKey struct {
+	// Caller is the subject of the principal that made the request, keys are scoped to their caller.
+	Caller string `db:"caller"`
+	Method string `db:"method"`
+	Key    string `db:"idempotency_key"`
+	// Fingerprint is a hash of the request, so the key can't be reused for a different request.
+	Fingerprint []byte `db:"fingerprint"`
+	// Response is the marshalled response, nil while the request is in progress.
+	Response  []byte    `db:"response"`
+	ExpiresAt time.Time `db:"expires_at"`
+	CreatedAt time.Time `db:"created_at"`
+}
@@ -3,6 +3,7 @@ package domain
 import (
 	"database/sql"
 	"errors"
+	"strconv"
 	"time"
 
 	v1 "github.com/jacktantram/user-service/build/go/shared/user/v1"
@@ -15,20 +16,35 @@ var (
 
 	ErrCreateUserEmailUnique = errors.New("email already exists")
 	ErrUserInvalidArgument   = errors.New("invalid request params for modifying/creating user")
+	ErrUserEtagMismatch      = errors.New("user has changed since the etag was read")
+
+	ErrNoDeadLetter = errors.New("dead letter does not exist")
+
+	ErrNoCommandResult = errors.New("command has not been processed")
+
+	ErrNoIdempotencyKey     = errors.New("idempotency key does not exist")
+	ErrIdempotencyKeyExists = errors.New("idempotency key already exists")
 )
 
 // User defines a user
 type User struct {
 	ID        uuid.UUID `db:"id"`
-	FirstName string    `db:"first_name" validate:"required"`
-	LastName  string    `db:"last_name" validate:"required"`
-	Nickname  string    `db:"nickname" validate:"required"`
-	Password  string    `db:"password" validate:"required"`
-	Email     string    `db:"email" validate:"required,email"`
+	FirstName string    `db:"first_name"`
+	LastName  string    `db:"last_name"`
+	Nickname  string    `db:"nickname"`
+	Password  string    `db:"password"`
+	Email     string    `db:"email"`
 	// ISO 3166-1 alpha-3
-	Country   string       `db:"country" validate:"required,len=3"`
+	Country   string       `db:"country"`
 	CreatedAt time.Time    `db:"created_at"`
 	UpdatedAt sql.NullTime `db:"updated_at"`
+	// Version is incremented on every write, it is exposed as the etag of the user.
+	Version int64 `db:"version"`
+}
+
+// UserEtag returns the etag of a version of a user.
+func UserEtag(version int64) string {
+	return strconv.FormatInt(version, 10)
 }
 
 // FromProto converts a proto user into a user.
@@ -46,6 +62,8 @@ func (u *User) FromProto(pbUser *v1.User) {
 		Country:   pbUser.Country,
 		CreatedAt: pbUser.GetCreatedAt().AsTime(),
 	}
+	// an etag that isn't a version leaves it unset
+	u.Version, _ = strconv.ParseInt(pbUser.Etag, 10, 64)
 	if pbUser.UpdatedAt != nil {
 		u.UpdatedAt = sql.NullTime{Time: pbUser.UpdatedAt.AsTime(), Valid: true}
 	}
@@ -63,6 +81,9 @@ func (u *User) ToProto() *v1.User {
 		Country:   u.Country,
 		CreatedAt: timestamppb.New(u.CreatedAt),
 	}
+	if u.Version > 0 {
+		pbUser.Etag = UserEtag(u.Version)
+	}
 	if u.UpdatedAt.Valid {
 		pbUser.UpdatedAt = timestamppb.New(u.UpdatedAt.Time)
 	}
@@ -32,6 +32,7 @@ func TestUser_FromProto(t *testing.T) {
 			Country:   "DEU",
 			CreatedAt: timestamppb.Now(),
 			UpdatedAt: timestamppb.Now(),
+			Etag:      "3",
 		}
 		u := &User{}
 		u.FromProto(pbUser)
@@ -46,6 +47,7 @@ func TestUser_FromProto(t *testing.T) {
 			Country:   "DEU",
 			CreatedAt: pbUser.CreatedAt.AsTime(),
 			UpdatedAt: sql.NullTime{Valid: true, Time: pbUser.UpdatedAt.AsTime()},
+			Version:   3,
 		}, u)
 	})
 	t.Run("creating a user without updated at", func(t *testing.T) {
@@ -94,6 +96,7 @@ func TestUser_ToProto(t *testing.T) {
 			Country:   "DEU",
 			CreatedAt: time.Now(),
 			UpdatedAt: sql.NullTime{Time: time.Now(), Valid: true},
+			Version:   3,
 		}
 		pbUser := u.ToProto()
 
@@ -107,6 +110,7 @@ func TestUser_ToProto(t *testing.T) {
 			Country:   "DEU",
 			CreatedAt: timestamppb.New(u.CreatedAt),
 			UpdatedAt: timestamppb.New(u.UpdatedAt.Time),
+			Etag:      "3",
 		}, pbUser)
 	})
 	t.Run("creating a user without updated at", func(t *testing.T) {
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS dead_letter_events
+(
+    id  UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    topic VARCHAR NOT NULL,
+    message_key VARCHAR NOT NULL DEFAULT '',
+    message_type VARCHAR NOT NULL DEFAULT '',
+    payload BYTEA,
+    error TEXT NOT NULL,
+    attempts INT NOT NULL DEFAULT 1,
+    created_at  timestamptz default now(),
+    updated_at  timestamptz
+);
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS user_commands
+(
+    command_id VARCHAR PRIMARY KEY,
+    result BYTEA NOT NULL,
+    created_at  timestamptz default now()
+);
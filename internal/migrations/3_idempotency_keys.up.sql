@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS idempotency_keys
+(
+    caller VARCHAR NOT NULL,
+    method VARCHAR NOT NULL,
+    idempotency_key VARCHAR NOT NULL,
+    fingerprint BYTEA NOT NULL,
+    response BYTEA,
+    expires_at timestamptz NOT NULL,
+    created_at  timestamptz default now(),
+    PRIMARY KEY (caller, method, idempotency_key)
+);
+CREATE INDEX IF NOT EXISTS idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
@@ -0,0 +1,107 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: replay.go
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	v1 "github.com/jacktantram/user-service/build/go/shared/user/v1"
+	store "github.com/jacktantram/user-service/internal/store"
+	proto "google.golang.org/protobuf/proto"
+)
+
+// MockUserScanner is a mock of UserScanner interface.
+type MockUserScanner struct {
+	ctrl     *gomock.Controller
+	recorder *MockUserScannerMockRecorder
+}
+
+// MockUserScannerMockRecorder is the mock recorder for MockUserScanner.
+type MockUserScannerMockRecorder struct {
+	mock *MockUserScanner
+}
+
+// NewMockUserScanner creates a new mock instance.
+func NewMockUserScanner(ctrl *gomock.Controller) *MockUserScanner {
+	mock := &MockUserScanner{ctrl: ctrl}
+	mock.recorder = &MockUserScannerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockUserScanner) EXPECT() *MockUserScannerMockRecorder {
+	return m.recorder
+}
+
+// ScanUsers mocks base method.
+func (m *MockUserScanner) ScanUsers(ctx context.Context, filter store.ScanFilter, batchSize uint64, fn func(*v1.User) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ScanUsers", ctx, filter, batchSize, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ScanUsers indicates an expected call of ScanUsers.
+func (mr *MockUserScannerMockRecorder) ScanUsers(ctx, filter, batchSize, fn interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ScanUsers", reflect.TypeOf((*MockUserScanner)(nil).ScanUsers), ctx, filter, batchSize, fn)
+}
+
+// MockProducer is a mock of Producer interface.
+type MockProducer struct {
+	ctrl     *gomock.Controller
+	recorder *MockProducerMockRecorder
+}
+
+// MockProducerMockRecorder is the mock recorder for MockProducer.
+type MockProducerMockRecorder struct {
+	mock *MockProducer
+}
+
+// NewMockProducer creates a new mock instance.
+func NewMockProducer(ctrl *gomock.Controller) *MockProducer {
+	mock := &MockProducer{ctrl: ctrl}
+	mock.recorder = &MockProducerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockProducer) EXPECT() *MockProducerMockRecorder {
+	return m.recorder
+}
+
+// ProduceKeyedMessage mocks base method.
+func (m *MockProducer) ProduceKeyedMessage(ctx context.Context, topic, key string, msg proto.Message) (int32, int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ProduceKeyedMessage", ctx, topic, key, msg)
+	ret0, _ := ret[0].(int32)
+	ret1, _ := ret[1].(int64)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// ProduceKeyedMessage indicates an expected call of ProduceKeyedMessage.
+func (mr *MockProducerMockRecorder) ProduceKeyedMessage(ctx, topic, key, msg interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProduceKeyedMessage", reflect.TypeOf((*MockProducer)(nil).ProduceKeyedMessage), ctx, topic, key, msg)
+}
+
+// ProduceMessage mocks base method.
+func (m *MockProducer) ProduceMessage(ctx context.Context, topic string, msg proto.Message) (int32, int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ProduceMessage", ctx, topic, msg)
+	ret0, _ := ret[0].(int32)
+	ret1, _ := ret[1].(int64)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// ProduceMessage indicates an expected call of ProduceMessage.
+func (mr *MockProducerMockRecorder) ProduceMessage(ctx, topic, msg interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProduceMessage", reflect.TypeOf((*MockProducer)(nil).ProduceMessage), ctx, topic, msg)
+}
@@ -0,0 +1,134 @@
+package replay
+
+//go:generate mockgen -source=replay.go -destination=mocks/mock_replay.go -package=mocks
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	eventsV1 "github.com/jacktantram/user-service/build/go/events/user/v1"
+	v1 "github.com/jacktantram/user-service/build/go/shared/user/v1"
+	"github.com/jacktantram/user-service/internal/domain"
+	"github.com/jacktantram/user-service/internal/store"
+	uuid "github.com/kevinburke/go.uuid"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	defaultProgressInterval = 1000
+)
+
+// Mode determines which event is republished for each user.
+type Mode string
+
+const (
+	// ModeCreated republishes a UserCreatedEvent for each user, each with a new event ID.
+	ModeCreated Mode = "created"
+	// ModeState republishes the user snapshot keyed by user ID, without the password.
+	ModeState Mode = "state"
+)
+
+// UserScanner iterates over stored users.
+type UserScanner interface {
+	ScanUsers(ctx context.Context, filter store.ScanFilter, batchSize uint64, fn func(user *v1.User) error) error
+}
+
+// Producer implementation for producing events
+type Producer interface {
+	ProduceMessage(ctx context.Context, topic string, msg proto.Message) (partition int32, offset int64, err error)
+	ProduceKeyedMessage(ctx context.Context, topic string, key string, msg proto.Message) (partition int32, offset int64, err error)
+}
+
+// Config configures a replay.
+type Config struct {
+	Mode Mode
+	// Topic to republish events to.
+	Topic     string
+	Filter    store.ScanFilter
+	BatchSize uint64
+	// Rate is the maximum number of events published per second. Zero is unlimited.
+	Rate int
+	// DryRun logs the events that would be published without publishing them.
+	DryRun bool
+	// ProgressInterval is the number of events between progress log lines.
+	ProgressInterval int
+	// EventID generates the IDs stamped on republished events, defaults to a random UUID.
+	EventID func() string
+}
+
+// Replayer republishes events for stored users.
+type Replayer struct {
+	scanner  UserScanner
+	producer Producer
+	cfg      Config
+}
+
+// NewReplayer creates a new replayer
+func NewReplayer(scanner UserScanner, producer Producer, cfg Config) (Replayer, error) {
+	if cfg.Mode != ModeCreated && cfg.Mode != ModeState {
+		return Replayer{}, fmt.Errorf("unknown replay mode %q", cfg.Mode)
+	}
+	if cfg.Topic == "" {
+		return Replayer{}, fmt.Errorf("topic must be provided")
+	}
+	if cfg.ProgressInterval <= 0 {
+		cfg.ProgressInterval = defaultProgressInterval
+	}
+	if cfg.EventID == nil {
+		cfg.EventID = func() string {
+			return uuid.NewV4().String()
+		}
+	}
+	return Replayer{scanner: scanner, producer: producer, cfg: cfg}, nil
+}
+
+// Run republishes an event for every user matching the filter, returning the number of events published.
+// It stops at the first publishing error.
+func (r Replayer) Run(ctx context.Context) (int, error) {
+	var throttle <-chan time.Time
+	if r.cfg.Rate > 0 {
+		ticker := time.NewTicker(time.Second / time.Duration(r.cfg.Rate))
+		defer ticker.Stop()
+		throttle = ticker.C
+	}
+
+	logger := log.WithFields(log.Fields{"mode": r.cfg.Mode, "topic_name": r.cfg.Topic, "dry_run": r.cfg.DryRun})
+	start := time.Now()
+	count := 0
+	err := r.scanner.ScanUsers(ctx, r.cfg.Filter, r.cfg.BatchSize, func(user *v1.User) error {
+		if throttle != nil {
+			select {
+			case <-throttle:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if err := r.publish(ctx, user); err != nil {
+			return fmt.Errorf("unable to replay user %s: %w", user.Id, err)
+		}
+		count++
+		if count%r.cfg.ProgressInterval == 0 {
+			logger.WithFields(log.Fields{"replayed": count, "elapsed": time.Since(start).String()}).Info("replay in progress")
+		}
+		return nil
+	})
+	logger.WithFields(log.Fields{"replayed": count, "elapsed": time.Since(start).String()}).Info("replay finished")
+	return count, err
+}
+
+func (r Replayer) publish(ctx context.Context, user *v1.User) error {
+	if r.cfg.DryRun {
+		log.WithFields(log.Fields{"user_id": user.Id, "topic_name": r.cfg.Topic}).Info("dry run, skipping publish")
+		return nil
+	}
+	var err error
+	switch r.cfg.Mode {
+	case ModeCreated:
+		_, _, err = r.producer.ProduceMessage(ctx, r.cfg.Topic, &eventsV1.UserCreatedEvent{User: user, EventId: r.cfg.EventID()})
+	case ModeState:
+		_, _, err = r.producer.ProduceKeyedMessage(ctx, r.cfg.Topic, user.Id, domain.RedactUser(user))
+	}
+	return err
+}
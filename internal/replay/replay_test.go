@@ -0,0 +1,231 @@
+package replay_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	eventsV1 "github.com/jacktantram/user-service/build/go/events/user/v1"
+	v1 "github.com/jacktantram/user-service/build/go/shared/user/v1"
+	"github.com/jacktantram/user-service/internal/replay"
+	"github.com/jacktantram/user-service/internal/replay/mocks"
+	"github.com/jacktantram/user-service/internal/store"
+	uuid "github.com/kevinburke/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+const eventID = "0b0f5c8e-5d7b-4a39-9a1c-0e6f1b2d3c4a"
+
+func scanUsers(users ...*v1.User) func(ctx context.Context, filter store.ScanFilter, batchSize uint64, fn func(user *v1.User) error) error {
+	return func(ctx context.Context, filter store.ScanFilter, batchSize uint64, fn func(user *v1.User) error) error {
+		for _, user := range users {
+			if err := fn(user); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// protoEq matches a proto message equal to want.
+func protoEq(want proto.Message) gomock.Matcher {
+	return protoMatcher{want: want}
+}
+
+type protoMatcher struct {
+	want proto.Message
+}
+
+func (m protoMatcher) Matches(x interface{}) bool {
+	got, ok := x.(proto.Message)
+	return ok && proto.Equal(m.want, got)
+}
+
+func (m protoMatcher) String() string {
+	return fmt.Sprintf("is equal to %v", m.want)
+}
+
+func TestNewReplayer(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	tests := []struct {
+		name    string
+		cfg     replay.Config
+		wantErr bool
+	}{
+		{"valid created replay", replay.Config{Mode: replay.ModeCreated, Topic: "user-created_v1"}, false},
+		{"valid state replay", replay.Config{Mode: replay.ModeState, Topic: "users-state_v1"}, false},
+		{"unknown mode", replay.Config{Mode: "deleted", Topic: "user-deleted_v1"}, true},
+		{"missing topic", replay.Config{Mode: replay.ModeCreated}, true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := replay.NewReplayer(mocks.NewMockUserScanner(ctrl), mocks.NewMockProducer(ctrl), tt.cfg)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestReplayer_Run(t *testing.T) {
+	t.Parallel()
+	var (
+		user1 = &v1.User{Id: "a8bdce5a-31dc-4647-98b5-ce9cb343138f", Country: "GBR"}
+		user2 = &v1.User{Id: "b8bdce5a-31dc-4647-98b5-ce9cb343138f", Country: "GBR"}
+	)
+	tests := []struct {
+		name      string
+		cfg       replay.Config
+		setup     func(mockScanner *mocks.MockUserScanner, mockProducer *mocks.MockProducer)
+		wantCount int
+		wantErr   string
+	}{
+		{
+			name: "should republish created events for every scanned user",
+			cfg: replay.Config{
+				Mode:    replay.ModeCreated,
+				Topic:   "user-created_v1",
+				Filter:  store.ScanFilter{Countries: []string{"GBR"}},
+				EventID: func() string { return eventID },
+			},
+			setup: func(mockScanner *mocks.MockUserScanner, mockProducer *mocks.MockProducer) {
+				mockScanner.
+					EXPECT().
+					ScanUsers(gomock.Any(), store.ScanFilter{Countries: []string{"GBR"}}, gomock.Any(), gomock.Any()).
+					DoAndReturn(scanUsers(user1, user2))
+				mockProducer.
+					EXPECT().
+					ProduceMessage(gomock.Any(), "user-created_v1", gomock.Eq(&eventsV1.UserCreatedEvent{User: user1, EventId: eventID})).
+					Return(int32(0), int64(0), nil)
+				mockProducer.
+					EXPECT().
+					ProduceMessage(gomock.Any(), "user-created_v1", gomock.Eq(&eventsV1.UserCreatedEvent{User: user2, EventId: eventID})).
+					Return(int32(0), int64(0), nil)
+			},
+			wantCount: 2,
+		},
+		{
+			name: "should republish keyed state snapshots",
+			cfg:  replay.Config{Mode: replay.ModeState, Topic: "users-state_v1", Rate: 1000},
+			setup: func(mockScanner *mocks.MockUserScanner, mockProducer *mocks.MockProducer) {
+				mockScanner.
+					EXPECT().
+					ScanUsers(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+					DoAndReturn(scanUsers(user1))
+				mockProducer.
+					EXPECT().
+					ProduceKeyedMessage(gomock.Any(), "users-state_v1", user1.Id, protoEq(user1)).
+					Return(int32(0), int64(0), nil)
+			},
+			wantCount: 1,
+		},
+		{
+			name: "should omit the password from state snapshots",
+			cfg:  replay.Config{Mode: replay.ModeState, Topic: "users-state_v1"},
+			setup: func(mockScanner *mocks.MockUserScanner, mockProducer *mocks.MockProducer) {
+				mockScanner.
+					EXPECT().
+					ScanUsers(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+					DoAndReturn(scanUsers(&v1.User{Id: user1.Id, Country: "GBR", Password: "a-password"}))
+				mockProducer.
+					EXPECT().
+					ProduceKeyedMessage(gomock.Any(), "users-state_v1", user1.Id, protoEq(user1)).
+					Return(int32(0), int64(0), nil)
+			},
+			wantCount: 1,
+		},
+		{
+			name: "should not publish on a dry run",
+			cfg:  replay.Config{Mode: replay.ModeCreated, Topic: "user-created_v1", DryRun: true},
+			setup: func(mockScanner *mocks.MockUserScanner, mockProducer *mocks.MockProducer) {
+				mockScanner.
+					EXPECT().
+					ScanUsers(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+					DoAndReturn(scanUsers(user1, user2))
+				mockProducer.
+					EXPECT().
+					ProduceMessage(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			wantCount: 2,
+		},
+		{
+			name: "should stop at the first publishing error",
+			cfg:  replay.Config{Mode: replay.ModeCreated, Topic: "user-created_v1"},
+			setup: func(mockScanner *mocks.MockUserScanner, mockProducer *mocks.MockProducer) {
+				mockScanner.
+					EXPECT().
+					ScanUsers(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+					DoAndReturn(scanUsers(user1, user2))
+				mockProducer.
+					EXPECT().
+					ProduceMessage(gomock.Any(), gomock.Any(), gomock.Any()).
+					Return(int32(0), int64(0), errors.New("broker down"))
+			},
+			wantCount: 0,
+			wantErr:   "broker down",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+			mockScanner := mocks.NewMockUserScanner(ctrl)
+			mockProducer := mocks.NewMockProducer(ctrl)
+			if tt.setup != nil {
+				tt.setup(mockScanner, mockProducer)
+			}
+			r, err := replay.NewReplayer(mockScanner, mockProducer, tt.cfg)
+			require.NoError(t, err)
+
+			count, err := r.Run(context.Background())
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantCount, count)
+		})
+	}
+}
+
+func TestReplayer_Run_EventIDs(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	mockScanner := mocks.NewMockUserScanner(ctrl)
+	mockProducer := mocks.NewMockProducer(ctrl)
+	mockScanner.
+		EXPECT().
+		ScanUsers(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(scanUsers(&v1.User{Id: "a8bdce5a-31dc-4647-98b5-ce9cb343138f"}, &v1.User{Id: "b8bdce5a-31dc-4647-98b5-ce9cb343138f"}))
+	var eventIDs []string
+	mockProducer.
+		EXPECT().
+		ProduceMessage(gomock.Any(), "user-created_v1", gomock.Any()).
+		DoAndReturn(func(ctx context.Context, topic string, msg proto.Message) (int32, int64, error) {
+			eventIDs = append(eventIDs, msg.(*eventsV1.UserCreatedEvent).EventId)
+			return 0, 0, nil
+		}).Times(2)
+
+	r, err := replay.NewReplayer(mockScanner, mockProducer, replay.Config{Mode: replay.ModeCreated, Topic: "user-created_v1"})
+	require.NoError(t, err)
+	_, err = r.Run(context.Background())
+	require.NoError(t, err)
+
+	require.Len(t, eventIDs, 2)
+	for _, id := range eventIDs {
+		_, err = uuid.FromString(id)
+		assert.NoError(t, err, "should stamp a UUID event ID")
+	}
+	assert.NotEqual(t, eventIDs[0], eventIDs[1], "should stamp a new event ID on each event")
+}
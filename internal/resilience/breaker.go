@@ -0,0 +1,133 @@
+package resilience
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrCircuitOpen is returned while the circuit breaker is rejecting calls.
+	ErrCircuitOpen = errors.New("circuit breaker is open")
+)
+
+const (
+	defaultFailureThreshold = 5
+	defaultOpenTimeout      = 30 * time.Second
+)
+
+// State is the state of a circuit breaker.
+type State int
+
+const (
+	// StateClosed allows every call.
+	StateClosed State = iota
+	// StateHalfOpen allows a single trial call to check whether the dependency has recovered.
+	StateHalfOpen
+	// StateOpen rejects every call until the open timeout has elapsed.
+	StateOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateHalfOpen:
+		return "half-open"
+	case StateOpen:
+		return "open"
+	default:
+		return "unknown"
+	}
+}
+
+// BreakerConfig configures a circuit breaker.
+type BreakerConfig struct {
+	// FailureThreshold is the number of consecutive failures that opens the breaker.
+	FailureThreshold int
+	// OpenTimeout is how long the breaker stays open before allowing a trial call.
+	OpenTimeout time.Duration
+}
+
+func (c *BreakerConfig) setDefaults() {
+	if c.FailureThreshold <= 0 {
+		c.FailureThreshold = defaultFailureThreshold
+	}
+	if c.OpenTimeout <= 0 {
+		c.OpenTimeout = defaultOpenTimeout
+	}
+}
+
+// Breaker is a consecutive failure circuit breaker.
+type Breaker struct {
+	cfg BreakerConfig
+	now func() time.Time
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	// trial is set while the half-open trial call is in flight.
+	trial bool
+}
+
+// NewBreaker creates a new closed circuit breaker
+func NewBreaker(cfg BreakerConfig) *Breaker {
+	cfg.setDefaults()
+	return &Breaker{cfg: cfg, now: time.Now}
+}
+
+// Allow reports whether a call may be made, returning ErrCircuitOpen if not.
+// Every allowed call must be followed by Success or Failure.
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.currentState() {
+	case StateOpen:
+		return ErrCircuitOpen
+	case StateHalfOpen:
+		if b.trial {
+			return ErrCircuitOpen
+		}
+		b.state = StateHalfOpen
+		b.trial = true
+	}
+	return nil
+}
+
+// Success records a successful call, closing the breaker.
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.state = StateClosed
+	b.failures = 0
+	b.trial = false
+}
+
+// Failure records a failed call, opening the breaker once the failure threshold is reached
+// or if the half-open trial call failed.
+func (b *Breaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.state == StateHalfOpen || b.failures >= b.cfg.FailureThreshold {
+		b.state = StateOpen
+		b.openedAt = b.now()
+	}
+	b.trial = false
+}
+
+// State returns the current state of the breaker.
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.currentState()
+}
+
+// currentState moves an open breaker to half-open once the open timeout has elapsed.
+func (b *Breaker) currentState() State {
+	if b.state == StateOpen && b.now().Sub(b.openedAt) >= b.cfg.OpenTimeout {
+		return StateHalfOpen
+	}
+	return b.state
+}
@@ -0,0 +1,36 @@
+package resilience
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBreaker(t *testing.T) {
+	t.Parallel()
+	now := time.Now()
+	b := NewBreaker(BreakerConfig{FailureThreshold: 2, OpenTimeout: time.Minute})
+	b.now = func() time.Time { return now }
+
+	assert.NoError(t, b.Allow())
+	b.Failure()
+	assert.Equal(t, StateClosed, b.State(), "should stay closed below the failure threshold")
+	assert.NoError(t, b.Allow())
+	b.Failure()
+	assert.Equal(t, StateOpen, b.State())
+	assert.ErrorIs(t, b.Allow(), ErrCircuitOpen)
+
+	now = now.Add(time.Minute)
+	assert.Equal(t, StateHalfOpen, b.State())
+	assert.NoError(t, b.Allow(), "should allow a trial call once the open timeout elapses")
+	assert.ErrorIs(t, b.Allow(), ErrCircuitOpen, "should only allow a single trial call")
+	b.Failure()
+	assert.Equal(t, StateOpen, b.State(), "should reopen when the trial call fails")
+
+	now = now.Add(time.Minute)
+	assert.NoError(t, b.Allow())
+	b.Success()
+	assert.Equal(t, StateClosed, b.State(), "should close when the trial call succeeds")
+	assert.NoError(t, b.Allow())
+}
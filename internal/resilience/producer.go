@@ -0,0 +1,144 @@
+package resilience
+
+//go:generate mockgen -source=producer.go -destination=mocks/mock_producer.go -package=mocks
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	defaultMaxAttempts    = 3
+	defaultInitialBackoff = 50 * time.Millisecond
+	defaultMaxBackoff     = time.Second
+)
+
+// Producer implementation for producing events
+type Producer interface {
+	ProduceMessage(ctx context.Context, topic string, msg proto.Message) (partition int32, offset int64, err error)
+	ProduceKeyedMessage(ctx context.Context, topic string, key string, msg proto.Message) (partition int32, offset int64, err error)
+}
+
+// RetryPolicy configures how failed produce calls are retried.
+type RetryPolicy struct {
+	// MaxAttempts is the number of produce calls made before giving up.
+	MaxAttempts int
+	// InitialBackoff is the upper bound of the wait before the first retry, doubling on every subsequent retry.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the upper bound of the wait between retries.
+	MaxBackoff time.Duration
+}
+
+func (r *RetryPolicy) setDefaults() {
+	if r.MaxAttempts <= 0 {
+		r.MaxAttempts = defaultMaxAttempts
+	}
+	if r.InitialBackoff <= 0 {
+		r.InitialBackoff = defaultInitialBackoff
+	}
+	if r.MaxBackoff <= 0 {
+		r.MaxBackoff = defaultMaxBackoff
+	}
+}
+
+// backoff returns a random wait between zero and the exponential backoff for the attempt (full jitter),
+// so retries from concurrent requests are spread out.
+func (r RetryPolicy) backoff(attempt int) time.Duration {
+	backoff := r.InitialBackoff
+	for i := 1; i < attempt && backoff < r.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > r.MaxBackoff {
+		backoff = r.MaxBackoff
+	}
+	return time.Duration(rand.Int63n(int64(backoff) + 1))
+}
+
+// ResilientProducer decorates a producer with bounded retries and a circuit breaker,
+// so an outage fails produce calls quickly rather than every call waiting out its retries.
+type ResilientProducer struct {
+	p       Producer
+	breaker *Breaker
+	retry   RetryPolicy
+	sleep   func(ctx context.Context, d time.Duration) error
+}
+
+// NewResilientProducer creates a new resilient producer
+func NewResilientProducer(p Producer, breaker BreakerConfig, retry RetryPolicy) *ResilientProducer {
+	retry.setDefaults()
+	return &ResilientProducer{p: p, breaker: NewBreaker(breaker), retry: retry, sleep: sleep}
+}
+
+// ProduceMessage produces a message, retrying on failure. ErrCircuitOpen is returned without
+// calling the producer while the circuit breaker is open.
+func (r *ResilientProducer) ProduceMessage(ctx context.Context, topic string, msg proto.Message) (partition int32, offset int64, err error) {
+	return r.do(ctx, func() (int32, int64, error) {
+		return r.p.ProduceMessage(ctx, topic, msg)
+	})
+}
+
+// ProduceKeyedMessage produces a keyed message, retrying on failure. ErrCircuitOpen is returned without
+// calling the producer while the circuit breaker is open.
+func (r *ResilientProducer) ProduceKeyedMessage(ctx context.Context, topic string, key string, msg proto.Message) (partition int32, offset int64, err error) {
+	return r.do(ctx, func() (int32, int64, error) {
+		return r.p.ProduceKeyedMessage(ctx, topic, key, msg)
+	})
+}
+
+func (r *ResilientProducer) do(ctx context.Context, fn func() (int32, int64, error)) (partition int32, offset int64, err error) {
+	for attempt := 1; ; attempt++ {
+		if err = r.breaker.Allow(); err != nil {
+			return 0, 0, err
+		}
+		if partition, offset, err = fn(); err == nil {
+			r.breaker.Success()
+			return partition, offset, nil
+		}
+		r.breaker.Failure()
+		if attempt >= r.retry.MaxAttempts {
+			return 0, 0, err
+		}
+		if sleepErr := r.sleep(ctx, r.retry.backoff(attempt)); sleepErr != nil {
+			return 0, 0, err
+		}
+	}
+}
+
+// State returns the state of the circuit breaker.
+func (r *ResilientProducer) State() State {
+	return r.breaker.State()
+}
+
+// HealthCheck fails while the circuit breaker is open. A half-open breaker is healthy so
+// traffic can reach the producer and close the breaker again.
+func (r *ResilientProducer) HealthCheck(context.Context) error {
+	if r.breaker.State() == StateOpen {
+		return ErrCircuitOpen
+	}
+	return nil
+}
+
+// Collector returns a gauge reporting the state of the circuit breaker, 0 closed, 1 half-open and 2 open.
+func (r *ResilientProducer) Collector() prometheus.Collector {
+	return prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "user_service_producer_circuit_breaker_state",
+		Help: "State of the producer circuit breaker, 0 closed, 1 half-open and 2 open.",
+	}, func() float64 {
+		return float64(r.breaker.State())
+	})
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,81 @@
+package resilience_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	eventsV1 "github.com/jacktantram/user-service/build/go/events/user/v1"
+	"github.com/jacktantram/user-service/internal/resilience"
+	"github.com/jacktantram/user-service/internal/resilience/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var fastRetry = resilience.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Nanosecond, MaxBackoff: time.Nanosecond}
+
+func TestResilientProducer_ProduceMessage(t *testing.T) {
+	t.Parallel()
+	event := &eventsV1.UserCreatedEvent{}
+
+	t.Run("should retry failed produce calls", func(t *testing.T) {
+		t.Parallel()
+		mockProducer := mocks.NewMockProducer(gomock.NewController(t))
+		gomock.InOrder(
+			mockProducer.EXPECT().ProduceMessage(gomock.Any(), "user-created_v1", event).
+				Return(int32(0), int64(0), errors.New("broker down")),
+			mockProducer.EXPECT().ProduceMessage(gomock.Any(), "user-created_v1", event).
+				Return(int32(1), int64(2), nil),
+		)
+		p := resilience.NewResilientProducer(mockProducer, resilience.BreakerConfig{}, fastRetry)
+
+		partition, offset, err := p.ProduceMessage(context.Background(), "user-created_v1", event)
+		require.NoError(t, err)
+		assert.Equal(t, int32(1), partition)
+		assert.Equal(t, int64(2), offset)
+		assert.Equal(t, resilience.StateClosed, p.State())
+	})
+
+	t.Run("should give up after the max attempts", func(t *testing.T) {
+		t.Parallel()
+		mockProducer := mocks.NewMockProducer(gomock.NewController(t))
+		mockProducer.EXPECT().ProduceKeyedMessage(gomock.Any(), "users-state_v1", "a-user-id", nil).
+			Return(int32(0), int64(0), errors.New("broker down")).Times(3)
+		p := resilience.NewResilientProducer(mockProducer, resilience.BreakerConfig{FailureThreshold: 10}, fastRetry)
+
+		_, _, err := p.ProduceKeyedMessage(context.Background(), "users-state_v1", "a-user-id", nil)
+		assert.EqualError(t, err, "broker down")
+	})
+
+	t.Run("should fail fast once the circuit breaker opens", func(t *testing.T) {
+		t.Parallel()
+		mockProducer := mocks.NewMockProducer(gomock.NewController(t))
+		mockProducer.EXPECT().ProduceMessage(gomock.Any(), gomock.Any(), gomock.Any()).
+			Return(int32(0), int64(0), errors.New("broker down")).Times(2)
+		p := resilience.NewResilientProducer(mockProducer,
+			resilience.BreakerConfig{FailureThreshold: 2, OpenTimeout: time.Hour}, fastRetry)
+
+		_, _, err := p.ProduceMessage(context.Background(), "user-created_v1", event)
+		assert.ErrorIs(t, err, resilience.ErrCircuitOpen)
+		_, _, err = p.ProduceMessage(context.Background(), "user-created_v1", event)
+		assert.ErrorIs(t, err, resilience.ErrCircuitOpen)
+		assert.Equal(t, resilience.StateOpen, p.State())
+		assert.ErrorIs(t, p.HealthCheck(context.Background()), resilience.ErrCircuitOpen)
+	})
+
+	t.Run("should stop retrying when the context is done", func(t *testing.T) {
+		t.Parallel()
+		mockProducer := mocks.NewMockProducer(gomock.NewController(t))
+		mockProducer.EXPECT().ProduceMessage(gomock.Any(), gomock.Any(), gomock.Any()).
+			Return(int32(0), int64(0), errors.New("broker down")).Times(1)
+		p := resilience.NewResilientProducer(mockProducer, resilience.BreakerConfig{},
+			resilience.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Hour, MaxBackoff: time.Hour})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, _, err := p.ProduceMessage(ctx, "user-created_v1", event)
+		assert.EqualError(t, err, "broker down")
+	})
+}
@@ -11,6 +11,7 @@ import (
 	gomock "github.com/golang/mock/gomock"
 	v1 "github.com/jacktantram/user-service/build/go/rpc/user/v1"
 	v10 "github.com/jacktantram/user-service/build/go/shared/user/v1"
+	domain "github.com/jacktantram/user-service/internal/domain"
 	proto "google.golang.org/protobuf/proto"
 )
 
@@ -52,17 +53,31 @@ func (mr *MockUserStoreMockRecorder) CreateUser(ctx, user interface{}) *gomock.C
 }
 
 // DeleteUser mocks base method.
-func (m *MockUserStore) DeleteUser(ctx context.Context, id string) error {
+func (m *MockUserStore) DeleteUser(ctx context.Context, id, etag string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteUser", ctx, id)
+	ret := m.ctrl.Call(m, "DeleteUser", ctx, id, etag)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // DeleteUser indicates an expected call of DeleteUser.
-func (mr *MockUserStoreMockRecorder) DeleteUser(ctx, id interface{}) *gomock.Call {
+func (mr *MockUserStoreMockRecorder) DeleteUser(ctx, id, etag interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUser", reflect.TypeOf((*MockUserStore)(nil).DeleteUser), ctx, id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUser", reflect.TypeOf((*MockUserStore)(nil).DeleteUser), ctx, id, etag)
+}
+
+// ExecInTransaction mocks base method.
+func (m *MockUserStore) ExecInTransaction(ctx context.Context, fn func(context.Context) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExecInTransaction", ctx, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ExecInTransaction indicates an expected call of ExecInTransaction.
+func (mr *MockUserStoreMockRecorder) ExecInTransaction(ctx, fn interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExecInTransaction", reflect.TypeOf((*MockUserStore)(nil).ExecInTransaction), ctx, fn)
 }
 
 // GetUser mocks base method.
@@ -80,6 +95,21 @@ func (mr *MockUserStoreMockRecorder) GetUser(ctx, id interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockUserStore)(nil).GetUser), ctx, id)
 }
 
+// GetUserForUpdate mocks base method.
+func (m *MockUserStore) GetUserForUpdate(ctx context.Context, id string) (*v10.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserForUpdate", ctx, id)
+	ret0, _ := ret[0].(*v10.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserForUpdate indicates an expected call of GetUserForUpdate.
+func (mr *MockUserStoreMockRecorder) GetUserForUpdate(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserForUpdate", reflect.TypeOf((*MockUserStore)(nil).GetUserForUpdate), ctx, id)
+}
+
 // ListUsers mocks base method.
 func (m *MockUserStore) ListUsers(ctx context.Context, filters *v1.SelectUserFilters, offset, limit uint64) ([]*v10.User, error) {
 	m.ctrl.T.Helper()
@@ -96,17 +126,17 @@ func (mr *MockUserStoreMockRecorder) ListUsers(ctx, filters, offset, limit inter
 }
 
 // UpdateUser mocks base method.
-func (m *MockUserStore) UpdateUser(ctx context.Context, userToUpdate *v10.User, updateFields []v10.UpdateUserField) error {
+func (m *MockUserStore) UpdateUser(ctx context.Context, userToUpdate *v10.User, updateFields []v10.UpdateUserField, etag string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateUser", ctx, userToUpdate, updateFields)
+	ret := m.ctrl.Call(m, "UpdateUser", ctx, userToUpdate, updateFields, etag)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // UpdateUser indicates an expected call of UpdateUser.
-func (mr *MockUserStoreMockRecorder) UpdateUser(ctx, userToUpdate, updateFields interface{}) *gomock.Call {
+func (mr *MockUserStoreMockRecorder) UpdateUser(ctx, userToUpdate, updateFields, etag interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUser", reflect.TypeOf((*MockUserStore)(nil).UpdateUser), ctx, userToUpdate, updateFields)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUser", reflect.TypeOf((*MockUserStore)(nil).UpdateUser), ctx, userToUpdate, updateFields, etag)
 }
 
 // MockProducer is a mock of Producer interface.
@@ -132,6 +162,22 @@ func (m *MockProducer) EXPECT() *MockProducerMockRecorder {
 	return m.recorder
 }
 
+// ProduceKeyedMessage mocks base method.
+func (m *MockProducer) ProduceKeyedMessage(ctx context.Context, topic, key string, msg proto.Message) (int32, int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ProduceKeyedMessage", ctx, topic, key, msg)
+	ret0, _ := ret[0].(int32)
+	ret1, _ := ret[1].(int64)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// ProduceKeyedMessage indicates an expected call of ProduceKeyedMessage.
+func (mr *MockProducerMockRecorder) ProduceKeyedMessage(ctx, topic, key, msg interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProduceKeyedMessage", reflect.TypeOf((*MockProducer)(nil).ProduceKeyedMessage), ctx, topic, key, msg)
+}
+
 // ProduceMessage mocks base method.
 func (m *MockProducer) ProduceMessage(ctx context.Context, topic string, msg proto.Message) (int32, int64, error) {
 	m.ctrl.T.Helper()
@@ -147,3 +193,40 @@ func (mr *MockProducerMockRecorder) ProduceMessage(ctx, topic, msg interface{})
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProduceMessage", reflect.TypeOf((*MockProducer)(nil).ProduceMessage), ctx, topic, msg)
 }
+
+// MockDeadLetterStore is a mock of DeadLetterStore interface.
+type MockDeadLetterStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockDeadLetterStoreMockRecorder
+}
+
+// MockDeadLetterStoreMockRecorder is the mock recorder for MockDeadLetterStore.
+type MockDeadLetterStoreMockRecorder struct {
+	mock *MockDeadLetterStore
+}
+
+// NewMockDeadLetterStore creates a new mock instance.
+func NewMockDeadLetterStore(ctrl *gomock.Controller) *MockDeadLetterStore {
+	mock := &MockDeadLetterStore{ctrl: ctrl}
+	mock.recorder = &MockDeadLetterStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockDeadLetterStore) EXPECT() *MockDeadLetterStoreMockRecorder {
+	return m.recorder
+}
+
+// AddDeadLetter mocks base method.
+func (m *MockDeadLetterStore) AddDeadLetter(ctx context.Context, deadLetter *domain.DeadLetter) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddDeadLetter", ctx, deadLetter)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddDeadLetter indicates an expected call of AddDeadLetter.
+func (mr *MockDeadLetterStoreMockRecorder) AddDeadLetter(ctx, deadLetter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddDeadLetter", reflect.TypeOf((*MockDeadLetterStore)(nil).AddDeadLetter), ctx, deadLetter)
+}
@@ -5,47 +5,138 @@ package service
 import (
 	"context"
 	eventsV1 "github.com/jacktantram/user-service/build/go/events/user/v1"
+	eventsV2 "github.com/jacktantram/user-service/build/go/events/user/v2"
 	userServiceV1 "github.com/jacktantram/user-service/build/go/rpc/user/v1"
 	v1 "github.com/jacktantram/user-service/build/go/shared/user/v1"
+	"github.com/jacktantram/user-service/internal/domain"
+	"github.com/jacktantram/user-service/pkg/logging"
+	uuid "github.com/kevinburke/go.uuid"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"google.golang.org/protobuf/proto"
+	"sync"
 )
 
 const (
-	// could be moved to a config
-	userCreatedTopic = "user-created_v1"
-	userUpdatedTopic = "user-updated_v1"
-	userDeletedTopic = "user-deleted_v1"
-
 	defaultLimitSize = 100
 )
 
+// Topics defines the topics that user events are produced to.
+type Topics struct {
+	Created string
+	Updated string
+	// UpdatedV2 carries the before and after state of an update along with the changed fields.
+	UpdatedV2 string
+	Deleted   string
+	// State is a log-compacted topic holding the latest snapshot of every user, keyed by user ID.
+	State string
+}
+
+// DefaultTopics returns the default topic names.
+func DefaultTopics() Topics {
+	return Topics{
+		Created:   "user-created_v1",
+		Updated:   "user-updated_v1",
+		UpdatedV2: "user-updated_v2",
+		Deleted:   "user-deleted_v1",
+		State:     "users-state_v1",
+	}
+}
+
+// WithPrefix returns the topics with the prefix prepended, i.e. $prefix.$topic
+func (t Topics) WithPrefix(prefix string) Topics {
+	return Topics{
+		Created:   PrefixTopic(prefix, t.Created),
+		Updated:   PrefixTopic(prefix, t.Updated),
+		UpdatedV2: PrefixTopic(prefix, t.UpdatedV2),
+		Deleted:   PrefixTopic(prefix, t.Deleted),
+		State:     PrefixTopic(prefix, t.State),
+	}
+}
+
+// PrefixTopic prepends the prefix to a topic name, i.e. $prefix.$topic. An empty prefix returns the topic unchanged.
+func PrefixTopic(prefix string, topic string) string {
+	if prefix == "" {
+		return topic
+	}
+	return prefix + "." + topic
+}
+
+// All returns every topic name.
+func (t Topics) All() []string {
+	return []string{t.Created, t.Updated, t.UpdatedV2, t.Deleted, t.State}
+}
+
 // UserStore CRUD operations for a user.
 type UserStore interface {
+	ExecInTransaction(ctx context.Context, fn func(ctx context.Context) error) error
 	GetUser(ctx context.Context, id string) (*v1.User, error)
+	GetUserForUpdate(ctx context.Context, id string) (*v1.User, error)
 	ListUsers(ctx context.Context, filters *userServiceV1.SelectUserFilters, offset uint64, limit uint64) ([]*v1.User, error)
 	CreateUser(ctx context.Context, user *v1.User) error
-	UpdateUser(ctx context.Context, userToUpdate *v1.User, updateFields []v1.UpdateUserField) error
-	DeleteUser(ctx context.Context, id string) error
+	// UpdateUser and DeleteUser only write a user still at the version of etag when it is set, otherwise they
+	// return domain.ErrUserEtagMismatch.
+	UpdateUser(ctx context.Context, userToUpdate *v1.User, updateFields []v1.UpdateUserField, etag string) error
+	DeleteUser(ctx context.Context, id string, etag string) error
 }
 
 // Producer implementation for producing events
 type Producer interface {
 	ProduceMessage(ctx context.Context, topic string, msg proto.Message) (partition int32, offset int64, err error)
+	// ProduceKeyedMessage produces a message partitioned by key. A nil message produces a tombstone.
+	ProduceKeyedMessage(ctx context.Context, topic string, key string, msg proto.Message) (partition int32, offset int64, err error)
+}
+
+// DeadLetterStore captures events that could not be produced.
+type DeadLetterStore interface {
+	AddDeadLetter(ctx context.Context, deadLetter *domain.DeadLetter) error
 }
 
 // Service defines the service struct.
 type Service struct {
-	u UserStore
-	p Producer
+	u       UserStore
+	p       Producer
+	dl      DeadLetterStore
+	topics  Topics
+	eventID func() string
+}
+
+// Option allows functional options to be passed into service
+type Option func(s *Service)
+
+// WithTopics allows the caller to override the default topics
+func WithTopics(topics Topics) Option {
+	return func(s *Service) {
+		s.topics = topics
+	}
+}
+
+// WithDeadLetterStore captures events that could not be produced in the dead letter store
+func WithDeadLetterStore(dl DeadLetterStore) Option {
+	return func(s *Service) {
+		s.dl = dl
+	}
+}
+
+// WithEventIDGenerator overrides how the IDs stamped on events are generated, defaults to a random UUID
+func WithEventIDGenerator(fn func() string) Option {
+	return func(s *Service) {
+		s.eventID = fn
+	}
 }
 
 // NewService creates a new service
-func NewService(store UserStore, p Producer) Service {
+func NewService(store UserStore, p Producer, opts ...Option) Service {
 	s := &Service{
-		u: store,
-		p: p,
+		u:      store,
+		p:      p,
+		topics: DefaultTopics(),
+		eventID: func() string {
+			return uuid.NewV4().String()
+		},
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
 	return *s
 }
@@ -56,7 +147,8 @@ func (s Service) CreateUser(ctx context.Context, user *v1.User) error {
 		return err
 	}
 
-	s.produceMessage(ctx, userCreatedTopic, user.Id, &eventsV1.UserCreatedEvent{User: user})
+	s.produceMessage(ctx, s.topics.Created, user.Id, &eventsV1.UserCreatedEvent{User: user, EventId: s.eventID()})
+	s.produceState(ctx, user.Id, user)
 	return nil
 }
 
@@ -74,40 +166,159 @@ func (s Service) ListUsers(ctx context.Context, filters *userServiceV1.SelectUse
 }
 
 // UpdateUser attempts to update a user.
-func (s Service) UpdateUser(ctx context.Context, userToUpdate *v1.User, updateFields []v1.UpdateUserField) error {
-	if err := s.u.UpdateUser(ctx, userToUpdate, updateFields); err != nil {
+// The user is read before and after the update within the same transaction so the
+// published events carry the full state of the user. When etag is set the update is only
+// applied if the user has not changed since, otherwise domain.ErrUserEtagMismatch is returned.
+func (s Service) UpdateUser(ctx context.Context, userToUpdate *v1.User, updateFields []v1.UpdateUserField, etag string) error {
+	var before, after *v1.User
+	if err := s.u.ExecInTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		if before, err = s.u.GetUserForUpdate(ctx, userToUpdate.Id); err != nil {
+			return err
+		}
+		// the etag is checked by the update itself, so concurrent writers can't both pass it
+		if err = s.u.UpdateUser(ctx, userToUpdate, updateFields, etag); err != nil {
+			return err
+		}
+		after, err = s.u.GetUser(ctx, userToUpdate.Id)
+		return err
+	}); err != nil {
 		return err
 	}
 
 	// don't want to break flow due to publishing error
-	s.produceMessage(ctx, userUpdatedTopic, userToUpdate.Id, &eventsV1.UserUpdatedEvent{User: userToUpdate,
-		UpdateFields: updateFields})
+	s.produceMessage(ctx, s.topics.Updated, after.Id, &eventsV1.UserUpdatedEvent{User: after,
+		UpdateFields: updateFields, EventId: s.eventID()})
+	s.produceMessage(ctx, s.topics.UpdatedV2, after.Id, &eventsV2.UserUpdatedEvent{Before: domain.RedactUser(before),
+		After: domain.RedactUser(after), ChangedFields: domain.DiffUsers(before, after), EventId: s.eventID()})
+	s.produceState(ctx, after.Id, after)
 	return nil
 }
 
-// DeleteUser attempts to delete a user.
-func (s Service) DeleteUser(ctx context.Context, id string) error {
-	u, err := s.u.GetUser(ctx, id)
-	if err != nil {
-		return errors.Wrap(err, "unable to get user when trying to delete")
+// DeleteUser attempts to delete a user. When etag is set the user is only deleted if it
+// has not changed since, otherwise domain.ErrUserEtagMismatch is returned.
+func (s Service) DeleteUser(ctx context.Context, id string, etag string) error {
+	var u *v1.User
+	if err := s.u.ExecInTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		if u, err = s.u.GetUserForUpdate(ctx, id); err != nil {
+			return errors.Wrap(err, "unable to get user when trying to delete")
+		}
+		return s.u.DeleteUser(ctx, id, etag)
+	}); err != nil {
+		return err
 	}
-	if err = s.u.DeleteUser(ctx, id); err != nil {
+
+	s.produceMessage(ctx, s.topics.Deleted, id, &eventsV1.UserDeletedEvent{User: u, EventId: s.eventID()})
+	s.produceState(ctx, id, nil)
+	return nil
+
+}
+
+// EraseUser deletes a user for an erasure request. Unlike DeleteUser the published
+// deleted event only carries the user ID so no personal data is retained in the event log.
+func (s Service) EraseUser(ctx context.Context, id string) error {
+	if err := s.u.DeleteUser(ctx, id, ""); err != nil {
 		return err
 	}
 
-	s.produceMessage(ctx, userDeletedTopic, id, &eventsV1.UserDeletedEvent{User: u})
+	s.produceMessage(ctx, s.topics.Deleted, id, &eventsV1.UserDeletedEvent{User: &v1.User{Id: id}, EventId: s.eventID()})
+	s.produceState(ctx, id, nil)
 	return nil
+}
+
+type deferredEventsKey struct{}
 
+// deferredEvents holds back the events of operations until their caller's transaction has committed.
+type deferredEvents struct {
+	mu      sync.Mutex
+	publish []func(ctx context.Context)
 }
 
-// todo - If logger was passed around in context
-// userId could potentially be omitted.
+// DeferEvents returns a context in which the events of service operations are held back rather than
+// published, along with a function publishing them. Operations run within a caller's transaction
+// should defer their events so that changes which are later rolled back are never published, calling
+// publish once the transaction has committed.
+func DeferEvents(ctx context.Context) (context.Context, func(ctx context.Context)) {
+	d := &deferredEvents{}
+	return context.WithValue(ctx, deferredEventsKey{}, d), func(ctx context.Context) {
+		d.mu.Lock()
+		publish := d.publish
+		d.publish = nil
+		d.mu.Unlock()
+		for _, fn := range publish {
+			fn(ctx)
+		}
+	}
+}
+
+// deferEvent holds back fn if the events of ctx are deferred, reporting whether it was.
+func deferEvent(ctx context.Context, fn func(ctx context.Context)) bool {
+	d, ok := ctx.Value(deferredEventsKey{}).(*deferredEvents)
+	if !ok {
+		return false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.publish = append(d.publish, fn)
+	return true
+}
+
+// produceMessage publishes an event, capturing it as a dead letter if it could not be produced.
 func (s Service) produceMessage(ctx context.Context, topicName string, userId string, message proto.Message) {
+	if deferEvent(ctx, func(ctx context.Context) { s.publishMessage(ctx, topicName, userId, message) }) {
+		return
+	}
+	s.publishMessage(ctx, topicName, userId, message)
+}
+
+func (s Service) publishMessage(ctx context.Context, topicName string, userId string, message proto.Message) {
 	_, _, err := s.p.ProduceMessage(ctx, topicName, message)
 	if err != nil {
-		log.WithError(err).
+		logging.FromContext(ctx).WithError(err).
 			WithFields(log.Fields{
 				"user_id": userId, "topic_name": topicName,
 			}).Error("unable to produce message")
+		s.deadLetter(ctx, topicName, "", message, err)
+	}
+}
+
+// produceState publishes the latest snapshot of a user keyed by user ID, with sensitive fields such as the
+// password cleared. A nil user publishes a tombstone so the user is removed on compaction.
+func (s Service) produceState(ctx context.Context, userId string, user *v1.User) {
+	if deferEvent(ctx, func(ctx context.Context) { s.publishState(ctx, userId, user) }) {
+		return
+	}
+	s.publishState(ctx, userId, user)
+}
+
+func (s Service) publishState(ctx context.Context, userId string, user *v1.User) {
+	var msg proto.Message
+	if user != nil {
+		msg = domain.RedactUser(user)
+	}
+	_, _, err := s.p.ProduceKeyedMessage(ctx, s.topics.State, userId, msg)
+	if err != nil {
+		logging.FromContext(ctx).WithError(err).
+			WithFields(log.Fields{
+				"user_id": userId, "topic_name": s.topics.State,
+			}).Error("unable to produce state message")
+		s.deadLetter(ctx, s.topics.State, userId, msg, err)
+	}
+}
+
+// deadLetter captures an event that could not be produced so that it can be retried later.
+func (s Service) deadLetter(ctx context.Context, topicName string, key string, message proto.Message, produceErr error) {
+	if s.dl == nil {
+		return
+	}
+	logger := logging.FromContext(ctx).WithFields(log.Fields{"topic_name": topicName})
+	deadLetter, err := domain.NewDeadLetter(topicName, key, message, produceErr)
+	if err != nil {
+		logger.WithError(err).Error("unable to create dead letter")
+		return
+	}
+	if err = s.dl.AddDeadLetter(ctx, deadLetter); err != nil {
+		logger.WithError(err).Error("unable to store dead letter, event is lost")
 	}
 }
@@ -2,20 +2,28 @@ package service_test
 
 import (
 	"context"
+	"fmt"
 	"github.com/golang/mock/gomock"
 	eventsV1 "github.com/jacktantram/user-service/build/go/events/user/v1"
+	eventsV2 "github.com/jacktantram/user-service/build/go/events/user/v2"
 	userServiceV1 "github.com/jacktantram/user-service/build/go/rpc/user/v1"
 	v1 "github.com/jacktantram/user-service/build/go/shared/user/v1"
+	"github.com/jacktantram/user-service/internal/domain"
 	"github.com/jacktantram/user-service/internal/service"
 	"github.com/jacktantram/user-service/internal/service/mocks"
 	uuid "github.com/kevinburke/go.uuid"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/timestamppb"
 	"testing"
 )
 
+const eventID = "0b0f5c8e-5d7b-4a39-9a1c-0e6f1b2d3c4a"
+
+var withEventID = service.WithEventIDGenerator(func() string { return eventID })
+
 func TestNewService(t *testing.T) {
 	t.Parallel()
 	ctrl := gomock.NewController(t)
@@ -206,7 +214,32 @@ func TestServiceCreateUser_Success(t *testing.T) {
 				mockProducer.
 					EXPECT().
 					ProduceMessage(gomock.Any(), "user-created_v1",
-						gomock.Eq(&eventsV1.UserCreatedEvent{User: args.user})).
+						gomock.Eq(&eventsV1.UserCreatedEvent{User: args.user, EventId: eventID})).
+					Return(int32(0), int64(0), nil)
+				mockProducer.
+					EXPECT().
+					ProduceKeyedMessage(gomock.Any(), "users-state_v1", args.user.Id, gomock.Eq(args.user)).
+					Return(int32(0), int64(0), nil)
+			},
+		},
+		{
+			name: "should omit the password from the state snapshot",
+			args: args{
+				user: &v1.User{Id: "a8bdce5a-31dc-4647-98b5-ce9cb343138f", FirstName: "John", Password: "A-password"},
+			},
+			setup: func(mockStore *mocks.MockUserStore, mockProducer *mocks.MockProducer, args args) {
+				mockStore.
+					EXPECT().
+					CreateUser(gomock.Any(), args.user).
+					Return(nil)
+				mockProducer.
+					EXPECT().
+					ProduceMessage(gomock.Any(), "user-created_v1", gomock.Any()).
+					Return(int32(0), int64(0), nil)
+				mockProducer.
+					EXPECT().
+					ProduceKeyedMessage(gomock.Any(), "users-state_v1", args.user.Id,
+						protoEq(&v1.User{Id: args.user.Id, FirstName: "John"})).
 					Return(int32(0), int64(0), nil)
 			},
 		}}
@@ -220,7 +253,7 @@ func TestServiceCreateUser_Success(t *testing.T) {
 			if tt.setup != nil {
 				tt.setup(mockUserStore, mockProducer, tt.args)
 			}
-			s := service.NewService(mockUserStore, mockProducer)
+			s := service.NewService(mockUserStore, mockProducer, withEventID)
 			require.NoError(t, s.CreateUser(context.Background(), tt.args.user))
 		})
 	}
@@ -277,30 +310,154 @@ func TestServiceUpdateUser(t *testing.T) {
 	type args struct {
 		user           *v1.User
 		fieldsToUpdate []v1.UpdateUserField
+		etag           string
 	}
 	tests := []struct {
-		name  string
-		setup func(mockService *mocks.MockUserStore, mockProducer *mocks.MockProducer, args args)
-		args  args
+		name    string
+		setup   func(mockService *mocks.MockUserStore, mockProducer *mocks.MockProducer, args args)
+		args    args
+		wantErr error
 	}{
 		{
-			name: "should be able to update a user and publish a updated event",
+			name: "should be able to update a user and publish updated events and state snapshot",
 			args: args{
-				user:           &v1.User{Id: "a8bdce5a-31dc-4647-98b5-ce9cb343138f"},
+				user:           &v1.User{Id: "a8bdce5a-31dc-4647-98b5-ce9cb343138f", FirstName: "Johnny"},
 				fieldsToUpdate: []v1.UpdateUserField{v1.UpdateUserField_UPDATE_USER_FIELD_FIRST_NAME},
+				etag:           "1",
 			},
 			setup: func(mockStore *mocks.MockUserStore, mockProducer *mocks.MockProducer, args args) {
+				before := &v1.User{Id: args.user.Id, FirstName: "John", LastName: "Gopher", Etag: "1"}
+				after := &v1.User{Id: args.user.Id, FirstName: "Johnny", LastName: "Gopher", UpdatedAt: timestamppb.Now(),
+					Etag: "2"}
 				mockStore.
 					EXPECT().
-					UpdateUser(gomock.Any(), args.user, args.fieldsToUpdate).
+					ExecInTransaction(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(ctx context.Context, fn func(ctx context.Context) error) error {
+						return fn(ctx)
+					})
+				mockStore.
+					EXPECT().
+					GetUserForUpdate(gomock.Any(), args.user.Id).
+					Return(before, nil)
+				mockStore.
+					EXPECT().
+					UpdateUser(gomock.Any(), args.user, args.fieldsToUpdate, args.etag).
 					Return(nil)
+				mockStore.
+					EXPECT().
+					GetUser(gomock.Any(), args.user.Id).
+					Return(after, nil)
 				mockProducer.
 					EXPECT().
 					ProduceMessage(gomock.Any(), "user-updated_v1",
-						gomock.Eq(&eventsV1.UserUpdatedEvent{User: args.user, UpdateFields: args.fieldsToUpdate})).
+						gomock.Eq(&eventsV1.UserUpdatedEvent{User: after, UpdateFields: args.fieldsToUpdate, EventId: eventID})).
+					Return(int32(0), int64(0), nil)
+				mockProducer.
+					EXPECT().
+					ProduceMessage(gomock.Any(), "user-updated_v2",
+						gomock.Eq(&eventsV2.UserUpdatedEvent{Before: before, After: after,
+							ChangedFields: []*eventsV2.FieldChange{{Field: "first_name", OldValue: "John", NewValue: "Johnny"}},
+							EventId:       eventID})).
+					Return(int32(0), int64(0), nil)
+				mockProducer.
+					EXPECT().
+					ProduceKeyedMessage(gomock.Any(), "users-state_v1", args.user.Id, gomock.Eq(after)).
 					Return(int32(0), int64(0), nil)
 			},
-		}}
+		},
+		{
+			name: "should omit the password from the users of the updated v2 event and state snapshot",
+			args: args{
+				user:           &v1.User{Id: "a8bdce5a-31dc-4647-98b5-ce9cb343138f", FirstName: "Johnny"},
+				fieldsToUpdate: []v1.UpdateUserField{v1.UpdateUserField_UPDATE_USER_FIELD_FIRST_NAME},
+			},
+			setup: func(mockStore *mocks.MockUserStore, mockProducer *mocks.MockProducer, args args) {
+				before := &v1.User{Id: args.user.Id, FirstName: "John", Password: "a-password", Etag: "1"}
+				after := &v1.User{Id: args.user.Id, FirstName: "Johnny", Password: "a-password", Etag: "2"}
+				inTransaction(mockStore)
+				mockStore.
+					EXPECT().
+					GetUserForUpdate(gomock.Any(), args.user.Id).
+					Return(before, nil)
+				mockStore.
+					EXPECT().
+					UpdateUser(gomock.Any(), args.user, args.fieldsToUpdate, args.etag).
+					Return(nil)
+				mockStore.
+					EXPECT().
+					GetUser(gomock.Any(), args.user.Id).
+					Return(after, nil)
+				mockProducer.
+					EXPECT().
+					ProduceMessage(gomock.Any(), "user-updated_v1", gomock.Any()).
+					Return(int32(0), int64(0), nil)
+				mockProducer.
+					EXPECT().
+					ProduceMessage(gomock.Any(), "user-updated_v2",
+						protoEq(&eventsV2.UserUpdatedEvent{
+							Before:        &v1.User{Id: args.user.Id, FirstName: "John", Etag: "1"},
+							After:         &v1.User{Id: args.user.Id, FirstName: "Johnny", Etag: "2"},
+							ChangedFields: []*eventsV2.FieldChange{{Field: "first_name", OldValue: "John", NewValue: "Johnny"}},
+							EventId:       eventID})).
+					Return(int32(0), int64(0), nil)
+				mockProducer.
+					EXPECT().
+					ProduceKeyedMessage(gomock.Any(), "users-state_v1", args.user.Id,
+						protoEq(&v1.User{Id: args.user.Id, FirstName: "Johnny", Etag: "2"})).
+					Return(int32(0), int64(0), nil)
+			},
+		},
+		{
+			name: "should not publish events if unable to update the user",
+			args: args{
+				user:           &v1.User{Id: "a8bdce5a-31dc-4647-98b5-ce9cb343138f", FirstName: "Johnny"},
+				fieldsToUpdate: []v1.UpdateUserField{v1.UpdateUserField_UPDATE_USER_FIELD_FIRST_NAME},
+			},
+			setup: func(mockStore *mocks.MockUserStore, mockProducer *mocks.MockProducer, args args) {
+				mockStore.
+					EXPECT().
+					ExecInTransaction(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(ctx context.Context, fn func(ctx context.Context) error) error {
+						return fn(ctx)
+					})
+				mockStore.
+					EXPECT().
+					GetUserForUpdate(gomock.Any(), args.user.Id).
+					Return(&v1.User{Id: args.user.Id}, nil)
+				mockStore.
+					EXPECT().
+					UpdateUser(gomock.Any(), args.user, args.fieldsToUpdate, args.etag).
+					Return(errors.New("update error"))
+				mockProducer.
+					EXPECT().
+					ProduceMessage(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			wantErr: errors.New("update error"),
+		},
+		{
+			name: "should not update the user if it has changed since the etag",
+			args: args{
+				user:           &v1.User{Id: "a8bdce5a-31dc-4647-98b5-ce9cb343138f", FirstName: "Johnny"},
+				fieldsToUpdate: []v1.UpdateUserField{v1.UpdateUserField_UPDATE_USER_FIELD_FIRST_NAME},
+				etag:           "1",
+			},
+			setup: func(mockStore *mocks.MockUserStore, mockProducer *mocks.MockProducer, args args) {
+				inTransaction(mockStore)
+				mockStore.
+					EXPECT().
+					GetUserForUpdate(gomock.Any(), args.user.Id).
+					Return(&v1.User{Id: args.user.Id, Etag: "2"}, nil)
+				mockStore.
+					EXPECT().
+					UpdateUser(gomock.Any(), args.user, args.fieldsToUpdate, args.etag).
+					Return(domain.ErrUserEtagMismatch)
+				mockProducer.
+					EXPECT().
+					ProduceMessage(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			wantErr: domain.ErrUserEtagMismatch,
+		},
+	}
 	for _, tt := range tests {
 		tt := tt
 		t.Run(tt.name, func(t *testing.T) {
@@ -311,16 +468,51 @@ func TestServiceUpdateUser(t *testing.T) {
 			if tt.setup != nil {
 				tt.setup(mockUserStore, mockProducer, tt.args)
 			}
-			s := service.NewService(mockUserStore, mockProducer)
-			require.NoError(t, s.UpdateUser(context.Background(), tt.args.user, tt.args.fieldsToUpdate))
+			s := service.NewService(mockUserStore, mockProducer, withEventID)
+			err := s.UpdateUser(context.Background(), tt.args.user, tt.args.fieldsToUpdate, tt.args.etag)
+			if tt.wantErr != nil {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr.Error())
+				return
+			}
+			require.NoError(t, err)
 		})
 	}
 }
 
+// inTransaction expects fn to be run in a transaction.
+func inTransaction(mockStore *mocks.MockUserStore) {
+	mockStore.
+		EXPECT().
+		ExecInTransaction(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, fn func(ctx context.Context) error) error {
+			return fn(ctx)
+		})
+}
+
+// protoEq matches a proto message equal to want.
+func protoEq(want proto.Message) gomock.Matcher {
+	return protoMatcher{want: want}
+}
+
+type protoMatcher struct {
+	want proto.Message
+}
+
+func (m protoMatcher) Matches(x interface{}) bool {
+	got, ok := x.(proto.Message)
+	return ok && proto.Equal(m.want, got)
+}
+
+func (m protoMatcher) String() string {
+	return fmt.Sprintf("is equal to %v", m.want)
+}
+
 func TestService_DeleteUser_Success(t *testing.T) {
 	t.Parallel()
 	type args struct {
-		Id string
+		Id   string
+		etag string
 	}
 	tests := []struct {
 		name  string
@@ -328,25 +520,31 @@ func TestService_DeleteUser_Success(t *testing.T) {
 		args  args
 	}{
 		{
-			name: "should be able to delete a user and publish a deleted event",
+			name: "should be able to delete a user and publish a deleted event and tombstone",
 			args: args{
-				Id: uuid.FromStringOrNil("a8bdce5a-31dc-4647-98b5-ce9cb343138f").String(),
+				Id:   uuid.FromStringOrNil("a8bdce5a-31dc-4647-98b5-ce9cb343138f").String(),
+				etag: "1",
 			},
 			setup: func(mockStore *mocks.MockUserStore, mockProducer *mocks.MockProducer, args args) {
-				existingUser := &v1.User{Id: "a8bdce5a-31dc-4647-98b5-ce9cb343138f"}
+				existingUser := &v1.User{Id: "a8bdce5a-31dc-4647-98b5-ce9cb343138f", Etag: "1"}
+				inTransaction(mockStore)
 				mockStore.
 					EXPECT().
-					GetUser(gomock.Any(), gomock.Eq("a8bdce5a-31dc-4647-98b5-ce9cb343138f")).
+					GetUserForUpdate(gomock.Any(), gomock.Eq("a8bdce5a-31dc-4647-98b5-ce9cb343138f")).
 					Return(existingUser, nil)
 
 				mockStore.
 					EXPECT().
-					DeleteUser(gomock.Any(), gomock.Eq("a8bdce5a-31dc-4647-98b5-ce9cb343138f")).
+					DeleteUser(gomock.Any(), gomock.Eq("a8bdce5a-31dc-4647-98b5-ce9cb343138f"), args.etag).
 					Return(nil)
 				mockProducer.
 					EXPECT().
 					ProduceMessage(gomock.Any(), "user-deleted_v1",
-						gomock.Eq(&eventsV1.UserDeletedEvent{User: existingUser})).
+						gomock.Eq(&eventsV1.UserDeletedEvent{User: existingUser, EventId: eventID})).
+					Return(int32(0), int64(0), nil)
+				mockProducer.
+					EXPECT().
+					ProduceKeyedMessage(gomock.Any(), "users-state_v1", args.Id, nil).
 					Return(int32(0), int64(0), nil)
 			},
 		}}
@@ -360,8 +558,8 @@ func TestService_DeleteUser_Success(t *testing.T) {
 			if tt.setup != nil {
 				tt.setup(mockUserStore, mockProducer, tt.args)
 			}
-			s := service.NewService(mockUserStore, mockProducer)
-			require.NoError(t, s.DeleteUser(context.Background(), tt.args.Id))
+			s := service.NewService(mockUserStore, mockProducer, withEventID)
+			require.NoError(t, s.DeleteUser(context.Background(), tt.args.Id, tt.args.etag))
 		})
 	}
 }
@@ -369,7 +567,8 @@ func TestService_DeleteUser_Success(t *testing.T) {
 func TestServiceDeleteUser_Error(t *testing.T) {
 	t.Parallel()
 	type args struct {
-		id string
+		id   string
+		etag string
 	}
 	tests := []struct {
 		name    string
@@ -383,13 +582,14 @@ func TestServiceDeleteUser_Error(t *testing.T) {
 				id: "a8bdce5a-31dc-4647-98b5-ce9cb343138f",
 			},
 			setup: func(mockStore *mocks.MockUserStore, mockProducer *mocks.MockProducer, args args) {
+				inTransaction(mockStore)
 				mockStore.
 					EXPECT().
-					GetUser(gomock.Any(), gomock.Any()).
+					GetUserForUpdate(gomock.Any(), gomock.Any()).
 					Return(nil, errors.New("get error"))
 				mockStore.
 					EXPECT().
-					DeleteUser(gomock.Any(), gomock.Any()).
+					DeleteUser(gomock.Any(), gomock.Any(), gomock.Any()).
 					Times(0)
 				mockProducer.
 					EXPECT().
@@ -404,13 +604,14 @@ func TestServiceDeleteUser_Error(t *testing.T) {
 				id: "a8bdce5a-31dc-4647-98b5-ce9cb343138f",
 			},
 			setup: func(mockStore *mocks.MockUserStore, mockProducer *mocks.MockProducer, args args) {
+				inTransaction(mockStore)
 				mockStore.
 					EXPECT().
-					GetUser(gomock.Any(), gomock.Any()).
+					GetUserForUpdate(gomock.Any(), gomock.Any()).
 					Return(&v1.User{}, nil)
 				mockStore.
 					EXPECT().
-					DeleteUser(gomock.Any(), gomock.Any()).
+					DeleteUser(gomock.Any(), gomock.Any(), gomock.Any()).
 					Return(errors.New("deleting error"))
 				mockProducer.
 					EXPECT().
@@ -418,6 +619,28 @@ func TestServiceDeleteUser_Error(t *testing.T) {
 
 			},
 			wantErr: errors.New("deleting error"),
+		},
+		{
+			name: "should return error and not delete if the user has changed since the etag",
+			args: args{
+				id:   "a8bdce5a-31dc-4647-98b5-ce9cb343138f",
+				etag: "1",
+			},
+			setup: func(mockStore *mocks.MockUserStore, mockProducer *mocks.MockProducer, args args) {
+				inTransaction(mockStore)
+				mockStore.
+					EXPECT().
+					GetUserForUpdate(gomock.Any(), args.id).
+					Return(&v1.User{Id: args.id, Etag: "2"}, nil)
+				mockStore.
+					EXPECT().
+					DeleteUser(gomock.Any(), args.id, args.etag).
+					Return(domain.ErrUserEtagMismatch)
+				mockProducer.
+					EXPECT().
+					ProduceMessage(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			wantErr: domain.ErrUserEtagMismatch,
 		}}
 	for _, tt := range tests {
 		tt := tt
@@ -430,9 +653,110 @@ func TestServiceDeleteUser_Error(t *testing.T) {
 				tt.setup(mockUserStore, mockProducer, tt.args)
 			}
 			s := service.NewService(mockUserStore, mockProducer)
-			err := s.DeleteUser(context.Background(), tt.args.id)
+			err := s.DeleteUser(context.Background(), tt.args.id, tt.args.etag)
 			require.Error(t, err)
 			assert.Contains(t, err.Error(), tt.wantErr.Error())
 		})
 	}
 }
+
+func TestService_EraseUser(t *testing.T) {
+	t.Parallel()
+	const id = "a8bdce5a-31dc-4647-98b5-ce9cb343138f"
+
+	t.Run("should delete the user and publish a deleted event without personal data", func(t *testing.T) {
+		t.Parallel()
+		ctrl := gomock.NewController(t)
+		mockUserStore := mocks.NewMockUserStore(ctrl)
+		mockProducer := mocks.NewMockProducer(ctrl)
+		mockUserStore.
+			EXPECT().
+			DeleteUser(gomock.Any(), id, "").
+			Return(nil)
+		mockProducer.
+			EXPECT().
+			ProduceMessage(gomock.Any(), "user-deleted_v1",
+				gomock.Eq(&eventsV1.UserDeletedEvent{User: &v1.User{Id: id}, EventId: eventID})).
+			Return(int32(0), int64(0), nil)
+		mockProducer.
+			EXPECT().
+			ProduceKeyedMessage(gomock.Any(), "users-state_v1", id, nil).
+			Return(int32(0), int64(0), nil)
+
+		s := service.NewService(mockUserStore, mockProducer, withEventID)
+		require.NoError(t, s.EraseUser(context.Background(), id))
+	})
+
+	t.Run("should not publish events if unable to delete the user", func(t *testing.T) {
+		t.Parallel()
+		ctrl := gomock.NewController(t)
+		mockUserStore := mocks.NewMockUserStore(ctrl)
+		mockProducer := mocks.NewMockProducer(ctrl)
+		mockUserStore.
+			EXPECT().
+			DeleteUser(gomock.Any(), id, "").
+			Return(domain.ErrNoUser)
+
+		s := service.NewService(mockUserStore, mockProducer)
+		assert.ErrorIs(t, s.EraseUser(context.Background(), id), domain.ErrNoUser)
+	})
+}
+
+func TestTopics_WithPrefix(t *testing.T) {
+	t.Parallel()
+	t.Run("should prefix every topic", func(t *testing.T) {
+		assert.Equal(t, service.Topics{
+			Created:   "staging.user-created_v1",
+			Updated:   "staging.user-updated_v1",
+			UpdatedV2: "staging.user-updated_v2",
+			Deleted:   "staging.user-deleted_v1",
+			State:     "staging.users-state_v1",
+		}, service.DefaultTopics().WithPrefix("staging"))
+	})
+	t.Run("should leave topics untouched without a prefix", func(t *testing.T) {
+		assert.Equal(t, service.DefaultTopics(), service.DefaultTopics().WithPrefix(""))
+	})
+}
+
+func TestPrefixTopic(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, "staging.usercommand-requested_v1", service.PrefixTopic("staging", "usercommand-requested_v1"))
+	assert.Equal(t, "usercommand-requested_v1", service.PrefixTopic("", "usercommand-requested_v1"))
+}
+
+func TestService_DeadLetter(t *testing.T) {
+	t.Parallel()
+	t.Run("should dead letter events that could not be produced", func(t *testing.T) {
+		t.Parallel()
+		ctrl := gomock.NewController(t)
+		mockUserStore := mocks.NewMockUserStore(ctrl)
+		mockProducer := mocks.NewMockProducer(ctrl)
+		mockDeadLetterStore := mocks.NewMockDeadLetterStore(ctrl)
+		user := &v1.User{Id: "a8bdce5a-31dc-4647-98b5-ce9cb343138f"}
+
+		mockUserStore.
+			EXPECT().
+			CreateUser(gomock.Any(), user).
+			Return(nil)
+		mockProducer.
+			EXPECT().
+			ProduceMessage(gomock.Any(), "user-created_v1", gomock.Any()).
+			Return(int32(0), int64(0), errors.New("broker down"))
+		mockProducer.
+			EXPECT().
+			ProduceKeyedMessage(gomock.Any(), "users-state_v1", user.Id, gomock.Any()).
+			Return(int32(0), int64(0), nil)
+		mockDeadLetterStore.
+			EXPECT().
+			AddDeadLetter(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, deadLetter *domain.DeadLetter) error {
+				assert.Equal(t, "user-created_v1", deadLetter.Topic)
+				assert.Equal(t, "events.user.v1.UserCreatedEvent", deadLetter.MessageType)
+				assert.Equal(t, "broker down", deadLetter.Error)
+				return nil
+			})
+
+		s := service.NewService(mockUserStore, mockProducer, service.WithDeadLetterStore(mockDeadLetterStore))
+		require.NoError(t, s.CreateUser(context.Background(), user))
+	})
+}
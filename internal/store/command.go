@@ -0,0 +1,38 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	commandsV1 "github.com/jacktantram/user-service/build/go/commands/user/v1"
+	"github.com/jacktantram/user-service/internal/domain"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+)
+
+// GetCommandResult fetches the result of a processed command.
+func (r Store) GetCommandResult(ctx context.Context, commandID string) (*commandsV1.UserCommandResult, error) {
+	var payload []byte
+	if err := r.connFromContext(ctx, "GetCommandResult").QueryRowxContext(ctx, "SELECT result FROM user_commands WHERE command_id=$1", commandID).Scan(&payload); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrNoCommandResult
+		}
+		return nil, err
+	}
+	var result commandsV1.UserCommandResult
+	if err := proto.Unmarshal(payload, &result); err != nil {
+		return nil, errors.Wrap(err, "unable to unmarshal command result")
+	}
+	return &result, nil
+}
+
+// AddCommandResult records the result of a processed command so it is not applied again.
+func (r Store) AddCommandResult(ctx context.Context, result *commandsV1.UserCommandResult) error {
+	payload, err := proto.Marshal(result)
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal command result")
+	}
+	_, err = r.connFromContext(ctx, "AddCommandResult").ExecContext(ctx,
+		"INSERT INTO user_commands (command_id, result) VALUES($1, $2)", result.CommandId, payload)
+	return err
+}
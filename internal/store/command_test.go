@@ -0,0 +1,97 @@
+//go:build integration
+// +build integration
+
+package store_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	commandsV1 "github.com/jacktantram/user-service/build/go/commands/user/v1"
+	v1 "github.com/jacktantram/user-service/build/go/shared/user/v1"
+	"github.com/jacktantram/user-service/internal/command"
+	"github.com/jacktantram/user-service/internal/domain"
+	"github.com/jacktantram/user-service/internal/service"
+	"github.com/jacktantram/user-service/internal/store"
+	"github.com/jacktantram/user-service/pkg/driver/v1/memory"
+	uuid "github.com/kevinburke/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestStore_CommandResults(t *testing.T) {
+	t.Run("should add and get a command result", func(t *testing.T) {
+		result := &commandsV1.UserCommandResult{
+			CommandId: uuid.NewV4().String(),
+			Status:    commandsV1.UserCommandResult_STATUS_SUCCEEDED,
+			User:      &v1.User{FirstName: "John"},
+		}
+		require.NoError(t, testStore.AddCommandResult(context.Background(), result))
+
+		got, err := testStore.GetCommandResult(context.Background(), result.CommandId)
+		require.NoError(t, err)
+		assert.True(t, proto.Equal(result, got))
+	})
+
+	t.Run("should not add a result twice", func(t *testing.T) {
+		result := &commandsV1.UserCommandResult{CommandId: uuid.NewV4().String()}
+		require.NoError(t, testStore.AddCommandResult(context.Background(), result))
+		assert.Error(t, testStore.AddCommandResult(context.Background(), result))
+	})
+
+	t.Run("should error if the command has not been processed", func(t *testing.T) {
+		_, err := testStore.GetCommandResult(context.Background(), uuid.NewV4().String())
+		assert.ErrorIs(t, err, domain.ErrNoCommandResult)
+	})
+}
+
+// failingResultStore fails to record command results, rolling back the transaction of the command.
+type failingResultStore struct {
+	store.Store
+}
+
+func (failingResultStore) AddCommandResult(context.Context, *commandsV1.UserCommandResult) error {
+	return errors.New("unable to add command result")
+}
+
+func TestStore_HandleCommand(t *testing.T) {
+	const resultTopic = "user-command-results_v1"
+	createCommand := func() *commandsV1.UserCommand {
+		return &commandsV1.UserCommand{
+			CommandId: uuid.NewV4().String(),
+			Command:   &commandsV1.UserCommand_Create{Create: &commandsV1.CreateUserCommand{User: newTestUser()}},
+		}
+	}
+
+	t.Run("should record the command and its result", func(t *testing.T) {
+		bus := memory.NewBus()
+		h, err := command.NewHandler(service.NewService(testStore, bus), testStore, bus, resultTopic)
+		require.NoError(t, err)
+		cmd := createCommand()
+		require.NoError(t, h.Handle(context.Background(), cmd))
+
+		result, err := testStore.GetCommandResult(context.Background(), cmd.CommandId)
+		require.NoError(t, err)
+		assert.Equal(t, commandsV1.UserCommandResult_STATUS_SUCCEEDED, result.Status)
+		_, err = testStore.GetUser(context.Background(), result.GetUser().GetId())
+		assert.NoError(t, err)
+	})
+
+	t.Run("should roll back the command if its result can't be recorded", func(t *testing.T) {
+		bus := memory.NewBus()
+		s := failingResultStore{Store: testStore}
+		h, err := command.NewHandler(service.NewService(s, bus), s, bus, resultTopic)
+		require.NoError(t, err)
+		cmd := createCommand()
+		require.Error(t, h.Handle(context.Background(), cmd))
+
+		id := cmd.GetCreate().GetUser().GetId()
+		require.NotEmpty(t, id)
+		_, err = testStore.GetUser(context.Background(), id)
+		assert.ErrorIs(t, err, domain.ErrNoUser)
+		assert.Empty(t, bus.Messages(service.DefaultTopics().Created), "should not publish the events of the command")
+		assert.Empty(t, bus.Messages(resultTopic))
+	})
+}
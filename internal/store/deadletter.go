@@ -0,0 +1,114 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jacktantram/user-service/internal/domain"
+	uuid "github.com/kevinburke/go.uuid"
+	"github.com/pkg/errors"
+)
+
+func (r Store) AddDeadLetter(ctx context.Context, deadLetter *domain.DeadLetter) error {
+	rows, err := r.connFromContext(ctx, "AddDeadLetter").NamedQueryContext(ctx, `
+		INSERT INTO dead_letter_events (topic, message_key, message_type, payload, error, attempts)
+		VALUES(:topic,:message_key,:message_type,:payload,:error,:attempts)
+		RETURNING id, created_at;
+		`, deadLetter)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return errors.New("row unaffected")
+	}
+	var (
+		id        uuid.UUID
+		createdAt time.Time
+	)
+	if err = rows.Scan(&id, &createdAt); err != nil {
+		return errors.Wrap(err, "unable to scan row")
+	}
+	deadLetter.ID = id
+	deadLetter.CreatedAt = createdAt
+	return nil
+}
+
+func (r Store) GetDeadLetter(ctx context.Context, id string) (*domain.DeadLetter, error) {
+	var d domain.DeadLetter
+	if err := r.connFromContext(ctx, "GetDeadLetter").QueryRowxContext(ctx, "SELECT * FROM dead_letter_events WHERE id=$1", uuid.FromStringOrNil(id)).StructScan(&d); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrNoDeadLetter
+		}
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (r Store) ListDeadLetters(ctx context.Context, offset uint64, limit uint64) ([]*domain.DeadLetter, error) {
+	rows, err := r.connFromContext(ctx, "ListDeadLetters").QueryxContext(ctx, "SELECT * FROM dead_letter_events ORDER BY created_at LIMIT $1 OFFSET $2", limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	deadLetters := make([]*domain.DeadLetter, 0)
+	for rows.Next() {
+		var d domain.DeadLetter
+		if err = rows.StructScan(&d); err != nil {
+			return nil, err
+		}
+		deadLetters = append(deadLetters, &d)
+	}
+	return deadLetters, nil
+}
+
+// RecordDeadLetterAttempt increments the attempts of a dead letter after a failed retry.
+func (r Store) RecordDeadLetterAttempt(ctx context.Context, id string, publishErr string) error {
+	row, err := r.connFromContext(ctx, "RecordDeadLetterAttempt").ExecContext(ctx,
+		"UPDATE dead_letter_events SET attempts=attempts+1, error=$2, updated_at=now() WHERE id=$1",
+		uuid.FromStringOrNil(id), publishErr)
+	if err != nil {
+		return err
+	}
+	affected, err := row.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return domain.ErrNoDeadLetter
+	}
+	return nil
+}
+
+func (r Store) DeleteDeadLetter(ctx context.Context, id string) error {
+	row, err := r.connFromContext(ctx, "DeleteDeadLetter").ExecContext(ctx, "DELETE FROM dead_letter_events WHERE id=$1", uuid.FromStringOrNil(id))
+	if err != nil {
+		return err
+	}
+	affected, err := row.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return domain.ErrNoDeadLetter
+	}
+	return nil
+}
+
+// PurgeDeadLetters deletes every dead letter, returning the number deleted.
+func (r Store) PurgeDeadLetters(ctx context.Context) (int64, error) {
+	row, err := r.connFromContext(ctx, "PurgeDeadLetters").ExecContext(ctx, "DELETE FROM dead_letter_events")
+	if err != nil {
+		return 0, err
+	}
+	return row.RowsAffected()
+}
+
+func (r Store) CountDeadLetters(ctx context.Context) (int64, error) {
+	var count int64
+	if err := r.connFromContext(ctx, "CountDeadLetters").QueryRowxContext(ctx, "SELECT COUNT(*) FROM dead_letter_events").Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
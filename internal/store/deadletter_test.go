@@ -0,0 +1,75 @@
+//go:build integration
+// +build integration
+
+package store_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	eventsV1 "github.com/jacktantram/user-service/build/go/events/user/v1"
+	v1 "github.com/jacktantram/user-service/build/go/shared/user/v1"
+	"github.com/jacktantram/user-service/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestDeadLetter(t *testing.T) *domain.DeadLetter {
+	t.Helper()
+	d, err := domain.NewDeadLetter("user-created_v1", "",
+		&eventsV1.UserCreatedEvent{User: &v1.User{FirstName: "John"}}, errors.New("broker down"))
+	require.NoError(t, err)
+	require.NoError(t, testStore.AddDeadLetter(context.Background(), d))
+	return d
+}
+
+func TestStore_DeadLetters(t *testing.T) {
+	t.Run("should add and get a dead letter", func(t *testing.T) {
+		d := newTestDeadLetter(t)
+		assert.NotEmpty(t, d.ID)
+
+		got, err := testStore.GetDeadLetter(context.Background(), d.ID.String())
+		require.NoError(t, err)
+		assert.Equal(t, d.Topic, got.Topic)
+		assert.Equal(t, d.MessageType, got.MessageType)
+		assert.Equal(t, d.Payload, got.Payload)
+		assert.Equal(t, int32(1), got.Attempts)
+	})
+
+	t.Run("should record a failed attempt", func(t *testing.T) {
+		d := newTestDeadLetter(t)
+		require.NoError(t, testStore.RecordDeadLetterAttempt(context.Background(), d.ID.String(), "still down"))
+
+		got, err := testStore.GetDeadLetter(context.Background(), d.ID.String())
+		require.NoError(t, err)
+		assert.Equal(t, int32(2), got.Attempts)
+		assert.Equal(t, "still down", got.Error)
+		assert.True(t, got.UpdatedAt.Valid)
+	})
+
+	t.Run("should delete a dead letter", func(t *testing.T) {
+		d := newTestDeadLetter(t)
+		require.NoError(t, testStore.DeleteDeadLetter(context.Background(), d.ID.String()))
+
+		_, err := testStore.GetDeadLetter(context.Background(), d.ID.String())
+		assert.ErrorIs(t, err, domain.ErrNoDeadLetter)
+	})
+
+	t.Run("should list, count and purge dead letters", func(t *testing.T) {
+		newTestDeadLetter(t)
+		newTestDeadLetter(t)
+
+		count, err := testStore.CountDeadLetters(context.Background())
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, count, int64(2))
+
+		deadLetters, err := testStore.ListDeadLetters(context.Background(), 0, 100)
+		require.NoError(t, err)
+		assert.Len(t, deadLetters, int(count))
+
+		purged, err := testStore.PurgeDeadLetters(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, count, purged)
+	})
+}
@@ -0,0 +1,83 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jacktantram/user-service/internal/domain"
+	"github.com/pkg/errors"
+)
+
+// AddIdempotencyKey records a request in progress with an idempotency key. It returns
+// domain.ErrIdempotencyKeyExists if the key has been used and not expired.
+func (r Store) AddIdempotencyKey(ctx context.Context, key *domain.IdempotencyKey) error {
+	// an expired key is replaced, it may not have been purged yet
+	res, err := r.connFromContext(ctx, "AddIdempotencyKey").NamedExecContext(ctx, `
+		INSERT INTO idempotency_keys (caller, method, idempotency_key, fingerprint, expires_at)
+		VALUES(:caller,:method,:idempotency_key,:fingerprint,:expires_at)
+		ON CONFLICT (caller, method, idempotency_key) DO UPDATE
+		SET fingerprint=EXCLUDED.fingerprint, response=NULL, expires_at=EXCLUDED.expires_at, created_at=now()
+		WHERE idempotency_keys.expires_at <= now();
+		`, key)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return domain.ErrIdempotencyKeyExists
+	}
+	return nil
+}
+
+// GetIdempotencyKey fetches an idempotency key that has not expired.
+func (r Store) GetIdempotencyKey(ctx context.Context, caller string, method string, key string) (*domain.IdempotencyKey, error) {
+	var k domain.IdempotencyKey
+	if err := r.connFromContext(ctx, "GetIdempotencyKey").QueryRowxContext(ctx, `
+		SELECT * FROM idempotency_keys WHERE caller=$1 AND method=$2 AND idempotency_key=$3 AND expires_at > now()
+		`, caller, method, key).StructScan(&k); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrNoIdempotencyKey
+		}
+		return nil, err
+	}
+	return &k, nil
+}
+
+// CompleteIdempotencyKey records the response of the request made with an idempotency key, keeping it
+// until it expires.
+func (r Store) CompleteIdempotencyKey(ctx context.Context, key *domain.IdempotencyKey) error {
+	res, err := r.connFromContext(ctx, "CompleteIdempotencyKey").NamedExecContext(ctx, `
+		UPDATE idempotency_keys SET response=:response, expires_at=:expires_at
+		WHERE caller=:caller AND method=:method AND idempotency_key=:idempotency_key;
+		`, key)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return domain.ErrNoIdempotencyKey
+	}
+	return nil
+}
+
+// DeleteIdempotencyKey removes an idempotency key, so the request can be retried.
+func (r Store) DeleteIdempotencyKey(ctx context.Context, caller string, method string, key string) error {
+	_, err := r.connFromContext(ctx, "DeleteIdempotencyKey").ExecContext(ctx,
+		"DELETE FROM idempotency_keys WHERE caller=$1 AND method=$2 AND idempotency_key=$3", caller, method, key)
+	return err
+}
+
+// DeleteExpiredIdempotencyKeys removes expired idempotency keys, returning how many were removed.
+func (r Store) DeleteExpiredIdempotencyKeys(ctx context.Context) (int64, error) {
+	res, err := r.connFromContext(ctx, "DeleteExpiredIdempotencyKeys").ExecContext(ctx, "DELETE FROM idempotency_keys WHERE expires_at <= now()")
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
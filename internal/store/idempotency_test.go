@@ -0,0 +1,81 @@
+//go:build integration
+// +build integration
+
+package store_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jacktantram/user-service/internal/domain"
+	uuid "github.com/kevinburke/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_IdempotencyKeys(t *testing.T) {
+	newKey := func() *domain.IdempotencyKey {
+		return &domain.IdempotencyKey{
+			Caller:      "a-caller",
+			Method:      "/rpc.user.v1.UserService/CreateUser",
+			Key:         uuid.NewV4().String(),
+			Fingerprint: []byte("fingerprint"),
+			ExpiresAt:   time.Now().Add(time.Minute),
+		}
+	}
+
+	t.Run("should add, complete and get a key", func(t *testing.T) {
+		key := newKey()
+		require.NoError(t, testStore.AddIdempotencyKey(context.Background(), key))
+
+		got, err := testStore.GetIdempotencyKey(context.Background(), key.Caller, key.Method, key.Key)
+		require.NoError(t, err)
+		assert.Equal(t, key.Fingerprint, got.Fingerprint)
+		assert.Nil(t, got.Response)
+
+		key.Response = []byte("response")
+		key.ExpiresAt = time.Now().Add(time.Hour)
+		require.NoError(t, testStore.CompleteIdempotencyKey(context.Background(), key))
+		got, err = testStore.GetIdempotencyKey(context.Background(), key.Caller, key.Method, key.Key)
+		require.NoError(t, err)
+		assert.Equal(t, key.Response, got.Response)
+		assert.WithinDuration(t, key.ExpiresAt, got.ExpiresAt, time.Second)
+	})
+
+	t.Run("should not add a key twice", func(t *testing.T) {
+		key := newKey()
+		require.NoError(t, testStore.AddIdempotencyKey(context.Background(), key))
+		assert.ErrorIs(t, testStore.AddIdempotencyKey(context.Background(), key), domain.ErrIdempotencyKeyExists)
+
+		other := newKey()
+		other.Key, other.Caller = key.Key, "another-caller"
+		assert.NoError(t, testStore.AddIdempotencyKey(context.Background(), other), "keys should be scoped to their caller")
+	})
+
+	t.Run("should replace and purge an expired key", func(t *testing.T) {
+		key := newKey()
+		key.ExpiresAt = time.Now().Add(-time.Minute)
+		require.NoError(t, testStore.AddIdempotencyKey(context.Background(), key))
+		_, err := testStore.GetIdempotencyKey(context.Background(), key.Caller, key.Method, key.Key)
+		assert.ErrorIs(t, err, domain.ErrNoIdempotencyKey)
+
+		deleted, err := testStore.DeleteExpiredIdempotencyKeys(context.Background())
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, deleted, int64(1))
+
+		key.ExpiresAt = time.Now().Add(-time.Minute)
+		require.NoError(t, testStore.AddIdempotencyKey(context.Background(), key))
+		key.ExpiresAt = time.Now().Add(time.Minute)
+		assert.NoError(t, testStore.AddIdempotencyKey(context.Background(), key), "should replace the expired key")
+	})
+
+	t.Run("should delete a key", func(t *testing.T) {
+		key := newKey()
+		require.NoError(t, testStore.AddIdempotencyKey(context.Background(), key))
+		require.NoError(t, testStore.DeleteIdempotencyKey(context.Background(), key.Caller, key.Method, key.Key))
+		_, err := testStore.GetIdempotencyKey(context.Background(), key.Caller, key.Method, key.Key)
+		assert.ErrorIs(t, err, domain.ErrNoIdempotencyKey)
+		assert.ErrorIs(t, testStore.CompleteIdempotencyKey(context.Background(), key), domain.ErrNoIdempotencyKey)
+	})
+}
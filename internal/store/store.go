@@ -4,43 +4,59 @@ import (
 	"context"
 	"database/sql"
 	"github.com/jacktantram/user-service/pkg/driver/v1/postgres"
+	"github.com/jacktantram/user-service/pkg/logging"
 	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/otel"
 )
 
 type Store struct {
 	db postgres.Client
 }
 
+// conn is satisfied by both the db and its transactions, so statements can run in either.
 type conn interface {
-	QueryRowxContext(ctx context.Context, query string, args ...interface{}) *sqlx.Row
-	Queryx(query string, args ...interface{}) (*sqlx.Rows, error)
-	NamedQueryContext(ctx context.Context, query string, arg interface{}) (*sqlx.Rows, error)
+	sqlx.ExtContext
 	NamedExecContext(ctx context.Context, query string, arg interface{}) (sql.Result, error)
-	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
 }
 
+var (
+	_ conn = (*sqlx.DB)(nil)
+	_ conn = (*sqlx.Tx)(nil)
+)
+
 func NewStore(db postgres.Client) Store {
 	return Store{db: db}
 }
 
-// ExecInTransaction allows db calls to be made in transactions across multiple db calls
-func (r Store) ExecInTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+// ExecInTransaction allows db calls to be made in transactions across multiple db calls.
+// The transaction is traced by a store.transaction span, parenting the spans of its statements.
+func (r Store) ExecInTransaction(ctx context.Context, fn func(ctx context.Context) error) (err error) {
 	// already in a transaction
 	if conn, ok := ctx.Value(connKey{}).(conn); conn != nil && ok {
 		return fn(ctx)
 	}
 
-	tx, err := r.db.DB.BeginTx(ctx, &sql.TxOptions{})
+	ctx, span := otel.Tracer(instrumentationName).Start(ctx, "store.transaction")
+	defer func() {
+		endSpan(span, err)
+		span.End()
+	}()
+
+	tx, err := r.db.DB.BeginTxx(ctx, &sql.TxOptions{})
 	if err != nil {
 		return err
 	}
 	if err = fn(context.WithValue(ctx, connKey{}, tx)); err != nil {
 		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			logging.FromContext(ctx).WithError(err).WithField("rollback_error", rollbackErr).
+				Error("unable to roll back failed transaction")
 			return rollbackErr
 		}
+		logging.FromContext(ctx).WithError(err).Debug("rolled back transaction")
 		return err
 	}
 	if err = tx.Commit(); err != nil {
+		logging.FromContext(ctx).WithError(err).Error("unable to commit transaction")
 		return err
 	}
 	return nil
@@ -48,10 +64,11 @@ func (r Store) ExecInTransaction(ctx context.Context, fn func(ctx context.Contex
 
 type connKey struct{}
 
-func (r Store) connFromContext(ctx context.Context) conn {
+// connFromContext returns the transaction of ctx, or the db outside of one, tracing statements under name.
+func (r Store) connFromContext(ctx context.Context, name string) tracedConn {
 	c := ctx.Value(connKey{})
 	if conn, ok := c.(conn); ok {
-		return conn
+		return tracedConn{conn: conn, name: name}
 	}
-	return r.db.DB
+	return tracedConn{conn: r.db.DB, name: name}
 }
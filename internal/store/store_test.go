@@ -4,11 +4,20 @@
 package store_test
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	v1 "github.com/jacktantram/user-service/build/go/shared/user/v1"
+	"github.com/jacktantram/user-service/internal/domain"
 	"github.com/jacktantram/user-service/internal/store"
 	"github.com/jacktantram/user-service/pkg/driver/v1/postgres"
+	uuid "github.com/kevinburke/go.uuid"
 	log "github.com/sirupsen/logrus"
-	"os"
-	"testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var (
@@ -29,3 +38,57 @@ func TestMain(m *testing.M) {
 	os.Exit(exitVal)
 
 }
+
+func newTestUser() *v1.User {
+	return &v1.User{
+		FirstName: "a-first-name",
+		LastName:  "a-last-name",
+		Nickname:  "a-nickname",
+		Password:  "a-password",
+		Email:     fmt.Sprintf("anemail-%s@.com", uuid.NewV4().String()),
+		Country:   "GBR",
+	}
+}
+
+func TestStore_ExecInTransaction(t *testing.T) {
+	errFailed := errors.New("failed")
+
+	t.Run("should commit the writes of the transaction", func(t *testing.T) {
+		user := newTestUser()
+		require.NoError(t, testStore.ExecInTransaction(context.Background(), func(ctx context.Context) error {
+			return testStore.CreateUser(ctx, user)
+		}))
+
+		_, err := testStore.GetUser(context.Background(), user.Id)
+		assert.NoError(t, err)
+	})
+
+	t.Run("should roll back the writes of a failed transaction", func(t *testing.T) {
+		user := newTestUser()
+		err := testStore.ExecInTransaction(context.Background(), func(ctx context.Context) error {
+			require.NoError(t, testStore.CreateUser(ctx, user))
+			return errFailed
+		})
+		require.ErrorIs(t, err, errFailed)
+
+		_, err = testStore.GetUser(context.Background(), user.Id)
+		assert.ErrorIs(t, err, domain.ErrNoUser)
+	})
+
+	t.Run("should run nested calls in the surrounding transaction", func(t *testing.T) {
+		user := newTestUser()
+		err := testStore.ExecInTransaction(context.Background(), func(ctx context.Context) error {
+			require.NoError(t, testStore.ExecInTransaction(ctx, func(ctx context.Context) error {
+				return testStore.CreateUser(ctx, user)
+			}))
+			// the nested write is only visible within the transaction until it commits
+			_, err := testStore.GetUser(ctx, user.Id)
+			require.NoError(t, err)
+			return errFailed
+		})
+		require.ErrorIs(t, err, errFailed)
+
+		_, err = testStore.GetUser(context.Background(), user.Id)
+		assert.ErrorIs(t, err, domain.ErrNoUser)
+	})
+}
@@ -0,0 +1,77 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies the spans created by the store.
+const instrumentationName = "github.com/jacktantram/user-service/internal/store"
+
+// tracedConn traces each statement run on conn in a span named after the store method running it.
+type tracedConn struct {
+	conn conn
+	name string
+}
+
+func (c tracedConn) QueryRowxContext(ctx context.Context, query string, args ...interface{}) *sqlx.Row {
+	ctx, span := startSpan(ctx, c.name, query)
+	defer span.End()
+	row := c.conn.QueryRowxContext(ctx, query, args...)
+	// no rows is an expected outcome rather than a failed statement
+	if err := row.Err(); err != sql.ErrNoRows {
+		endSpan(span, err)
+	}
+	return row
+}
+
+func (c tracedConn) QueryxContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error) {
+	ctx, span := startSpan(ctx, c.name, query)
+	defer span.End()
+	rows, err := c.conn.QueryxContext(ctx, query, args...)
+	endSpan(span, err)
+	return rows, err
+}
+
+func (c tracedConn) NamedQueryContext(ctx context.Context, query string, arg interface{}) (*sqlx.Rows, error) {
+	ctx, span := startSpan(ctx, c.name, query)
+	defer span.End()
+	rows, err := sqlx.NamedQueryContext(ctx, c.conn, query, arg)
+	endSpan(span, err)
+	return rows, err
+}
+
+func (c tracedConn) NamedExecContext(ctx context.Context, query string, arg interface{}) (sql.Result, error) {
+	ctx, span := startSpan(ctx, c.name, query)
+	defer span.End()
+	res, err := c.conn.NamedExecContext(ctx, query, arg)
+	endSpan(span, err)
+	return res, err
+}
+
+func (c tracedConn) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, span := startSpan(ctx, c.name, query)
+	defer span.End()
+	res, err := c.conn.ExecContext(ctx, query, args...)
+	endSpan(span, err)
+	return res, err
+}
+
+// startSpan starts a client span for a statement, named "store.$name".
+func startSpan(ctx context.Context, name string, query string) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, "store."+name, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemPostgreSQL, semconv.DBOperation(name), semconv.DBStatement(query)))
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
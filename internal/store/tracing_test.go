@@ -0,0 +1,51 @@
+//go:build integration
+// +build integration
+
+package store_test
+
+import (
+	"context"
+	"fmt"
+	v1 "github.com/jacktantram/user-service/build/go/shared/user/v1"
+	uuid "github.com/kevinburke/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"testing"
+)
+
+func TestStore_Tracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	user := &v1.User{
+		FirstName: "a-first-name",
+		LastName:  "a-last-name",
+		Nickname:  "a-nickname",
+		Password:  "a-password",
+		Email:     fmt.Sprintf("anemail-%s@.com", uuid.NewV4().String()),
+		Country:   "GBR",
+	}
+	require.NoError(t, testStore.CreateUser(context.Background(), user))
+	require.NoError(t, testStore.ExecInTransaction(context.Background(), func(ctx context.Context) error {
+		_, err := testStore.GetUserForUpdate(ctx, user.Id)
+		return err
+	}))
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 3)
+	assert.Equal(t, "store.CreateUser", spans[0].Name())
+	assert.Contains(t, spans[0].Attributes(), attribute.String("db.system", "postgresql"))
+
+	getUser, transaction := spans[1], spans[2]
+	assert.Equal(t, "store.GetUserForUpdate", getUser.Name())
+	assert.Equal(t, "store.transaction", transaction.Name())
+	assert.Equal(t, transaction.SpanContext().SpanID(), getUser.Parent().SpanID())
+	assert.Contains(t, getUser.Attributes(),
+		attribute.String("db.statement", "SELECT * FROM users WHERE id=$1 FOR UPDATE"))
+}
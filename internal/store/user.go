@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"github.com/jacktantram/user-service/internal/domain"
 	"github.com/lib/pq"
+	"strconv"
 	"strings"
 	"time"
 
@@ -19,11 +20,13 @@ import (
 
 const (
 	emailConstraintKey = "users_email_key"
+
+	defaultScanBatchSize = 100
 )
 
 func (r Store) GetUser(ctx context.Context, id string) (*v1.User, error) {
 	var u domain.User
-	if err := r.connFromContext(ctx).QueryRowxContext(ctx, "SELECT * FROM users WHERE id=$1", uuid.FromStringOrNil(id)).StructScan(&u); err != nil {
+	if err := r.connFromContext(ctx, "GetUser").QueryRowxContext(ctx, "SELECT * FROM users WHERE id=$1", uuid.FromStringOrNil(id)).StructScan(&u); err != nil {
 		if err.Error() == "sql: no rows in result set" {
 			return nil, domain.ErrNoUser
 		}
@@ -33,6 +36,20 @@ func (r Store) GetUser(ctx context.Context, id string) (*v1.User, error) {
 	return u.ToProto(), nil
 }
 
+// GetUserForUpdate fetches a user, locking the row until the surrounding transaction completes.
+// It should be called within ExecInTransaction.
+func (r Store) GetUserForUpdate(ctx context.Context, id string) (*v1.User, error) {
+	var u domain.User
+	if err := r.connFromContext(ctx, "GetUserForUpdate").QueryRowxContext(ctx, "SELECT * FROM users WHERE id=$1 FOR UPDATE", uuid.FromStringOrNil(id)).StructScan(&u); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrNoUser
+		}
+		return nil, err
+	}
+
+	return u.ToProto(), nil
+}
+
 func (r Store) ListUsers(ctx context.Context, filters *userServiceV1.SelectUserFilters, offset uint64, limit uint64) ([]*v1.User, error) {
 	arg := map[string]interface{}{}
 
@@ -63,10 +80,11 @@ func (r Store) ListUsers(ctx context.Context, filters *userServiceV1.SelectUserF
 	}
 
 	query = r.db.DB.Rebind(query)
-	rows, err := r.connFromContext(ctx).Queryx(query, args...)
+	rows, err := r.connFromContext(ctx, "ListUsers").QueryxContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 	users := make([]*v1.User, 0)
 	for rows.Next() {
 		var user domain.User
@@ -81,10 +99,10 @@ func (r Store) ListUsers(ctx context.Context, filters *userServiceV1.SelectUserF
 
 func (r Store) CreateUser(ctx context.Context, user *v1.User) error {
 
-	rows, err := r.connFromContext(ctx).NamedQueryContext(ctx, `
+	rows, err := r.connFromContext(ctx, "CreateUser").NamedQueryContext(ctx, `
 		INSERT INTO users (first_name, last_name, nickname, password, email, country)
 		VALUES(:first_name,:last_name,:nickname,:password,:email,:country)
-		RETURNING id, created_at;
+		RETURNING id, created_at, version;
 		`, &domain.User{
 		FirstName: user.FirstName,
 		LastName:  user.LastName,
@@ -101,22 +119,27 @@ func (r Store) CreateUser(ctx context.Context, user *v1.User) error {
 		}
 		return err
 	}
+	defer rows.Close()
 	if !rows.Next() {
 		return errors.New("row unaffected")
 	}
 	var (
 		id        string
 		createdAt time.Time
+		version   int64
 	)
-	if err = rows.Scan(&id, &createdAt); err != nil {
+	if err = rows.Scan(&id, &createdAt, &version); err != nil {
 		return errors.Wrap(err, "unable to scan row")
 	}
 	user.Id = id
 	user.CreatedAt = timestamppb.New(createdAt)
+	user.Etag = domain.UserEtag(version)
 	return nil
 }
 
-func (r Store) UpdateUser(ctx context.Context, userToUpdate *v1.User, updateFields []v1.UpdateUserField) error {
+// UpdateUser updates the fields of a user. When etag is set the user is only updated if it is still at that
+// version, otherwise domain.ErrUserEtagMismatch is returned.
+func (r Store) UpdateUser(ctx context.Context, userToUpdate *v1.User, updateFields []v1.UpdateUserField, etag string) error {
 	if len(updateFields) == 0 {
 		return errors.New("missing update fields")
 	}
@@ -124,6 +147,11 @@ func (r Store) UpdateUser(ctx context.Context, userToUpdate *v1.User, updateFiel
 	arg := map[string]interface{}{}
 
 	arg["id"] = userToUpdate.Id
+	where := "id=:id"
+	if etag != "" {
+		arg["version"] = etagVersion(etag)
+		where += " AND version=:version"
+	}
 
 	updateBuilder := strings.Builder{}
 	for _, field := range updateFields {
@@ -133,32 +161,40 @@ func (r Store) UpdateUser(ctx context.Context, userToUpdate *v1.User, updateFiel
 		}
 	}
 
-	query, args, err := sqlx.Named(fmt.Sprintf("UPDATE users SET %s, updated_at=now() where id=:id RETURNING updated_at", updateBuilder.String()), arg)
+	query, args, err := sqlx.Named(fmt.Sprintf("UPDATE users SET %s, updated_at=now(), version=version+1 WHERE %s RETURNING updated_at, version", updateBuilder.String(), where), arg)
 	if err != nil {
 		return err
 	}
 
 	query = r.db.DB.Rebind(query)
 
-	row := r.connFromContext(ctx).QueryRowxContext(ctx, query, args...)
-	if row.Err() != nil {
+	row := r.connFromContext(ctx, "UpdateUser").QueryRowxContext(ctx, query, args...)
+	if err = row.Err(); err != nil {
 		return err
 	}
 	var (
 		updatedAt time.Time
+		version   int64
 	)
-	if err = row.Scan(&updatedAt); err != nil {
+	if err = row.Scan(&updatedAt, &version); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return domain.ErrNoUser
+			return r.unchangedUserError(ctx, "UpdateUser", userToUpdate.Id, etag)
 		}
 		return errors.Wrap(err, "unable to scan row")
 	}
 	userToUpdate.UpdatedAt = timestamppb.New(updatedAt)
+	userToUpdate.Etag = domain.UserEtag(version)
 	return nil
 }
 
-func (r Store) DeleteUser(ctx context.Context, id string) error {
-	row, err := r.connFromContext(ctx).ExecContext(ctx, "DELETE FROM users WHERE id=$1", uuid.FromStringOrNil(id))
+// DeleteUser deletes a user. When etag is set the user is only deleted if it is still at that version,
+// otherwise domain.ErrUserEtagMismatch is returned.
+func (r Store) DeleteUser(ctx context.Context, id string, etag string) error {
+	query, args := "DELETE FROM users WHERE id=$1", []interface{}{uuid.FromStringOrNil(id)}
+	if etag != "" {
+		query, args = query+" AND version=$2", append(args, etagVersion(etag))
+	}
+	row, err := r.connFromContext(ctx, "DeleteUser").ExecContext(ctx, query, args...)
 	if err != nil {
 		return err
 	}
@@ -167,8 +203,110 @@ func (r Store) DeleteUser(ctx context.Context, id string) error {
 		return err
 	}
 	if affected == 0 {
+		return r.unchangedUserError(ctx, "DeleteUser", id, etag)
+	}
+	return nil
+}
+
+// unchangedUserError tells apart why a write of a user affected no rows: the user doesn't exist, or it
+// exists at a version other than etag.
+func (r Store) unchangedUserError(ctx context.Context, name string, id string, etag string) error {
+	if etag == "" {
 		return domain.ErrNoUser
+	}
+	var exists bool
+	if err := r.connFromContext(ctx, name).QueryRowxContext(ctx, "SELECT EXISTS(SELECT 1 FROM users WHERE id=$1)",
+		uuid.FromStringOrNil(id)).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return domain.ErrUserEtagMismatch
+	}
+	return domain.ErrNoUser
+}
 
+// etagVersion returns the version of an etag, an etag that isn't a version matches none.
+func etagVersion(etag string) int64 {
+	version, err := strconv.ParseInt(etag, 10, 64)
+	if err != nil {
+		return -1
+	}
+	return version
+}
+
+// ScanFilter narrows the users visited by ScanUsers. Empty fields are not filtered on.
+type ScanFilter struct {
+	IDs       []string
+	Countries []string
+	// UpdatedSince only includes users created or updated at or after this time.
+	UpdatedSince time.Time
+}
+
+// ScanUsers visits every user matching the filter in ID order, fetching batchSize users at a time.
+// Scanning stops at the first error returned by fn.
+func (r Store) ScanUsers(ctx context.Context, filter ScanFilter, batchSize uint64, fn func(user *v1.User) error) error {
+	arg := map[string]interface{}{}
+	conditions := []string{"id > :after"}
+	if len(filter.IDs) != 0 {
+		ids := make([]uuid.UUID, 0, len(filter.IDs))
+		for _, id := range filter.IDs {
+			ids = append(ids, uuid.FromStringOrNil(id))
+		}
+		arg["ids"] = ids
+		conditions = append(conditions, "id IN (:ids)")
+	}
+	if len(filter.Countries) != 0 {
+		arg["country"] = filter.Countries
+		conditions = append(conditions, "country IN (:country)")
+	}
+	if !filter.UpdatedSince.IsZero() {
+		arg["updated_since"] = filter.UpdatedSince
+		conditions = append(conditions, "COALESCE(updated_at, created_at) >= :updated_since")
+	}
+	if batchSize == 0 {
+		batchSize = defaultScanBatchSize
+	}
+	arg["limit"] = batchSize
+
+	after := uuid.Nil
+	for {
+		arg["after"] = after
+		query, args, err := sqlx.Named(fmt.Sprintf("SELECT * FROM users WHERE %s ORDER BY id LIMIT :limit",
+			strings.Join(conditions, " AND ")), arg)
+		if err != nil {
+			return err
+		}
+		query, args, err = sqlx.In(query, args...)
+		if err != nil {
+			return err
+		}
+		query = r.db.DB.Rebind(query)
+
+		rows, err := r.connFromContext(ctx, "ScanUsers").QueryxContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		users := make([]domain.User, 0, batchSize)
+		for rows.Next() {
+			var user domain.User
+			if err = rows.StructScan(&user); err != nil {
+				_ = rows.Close()
+				return err
+			}
+			users = append(users, user)
+		}
+		if err = rows.Close(); err != nil {
+			return err
+		}
+
+		for i := range users {
+			if err = fn(users[i].ToProto()); err != nil {
+				return err
+			}
+		}
+		if uint64(len(users)) < batchSize {
+			return nil
+		}
+		after = users[len(users)-1].ID
 	}
-	return nil
 }
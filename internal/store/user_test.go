@@ -9,11 +9,14 @@ import (
 	userServiceV1 "github.com/jacktantram/user-service/build/go/rpc/user/v1"
 	v1 "github.com/jacktantram/user-service/build/go/shared/user/v1"
 	"github.com/jacktantram/user-service/internal/domain"
+	"github.com/jacktantram/user-service/internal/store"
 	uuid "github.com/kevinburke/go.uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/timestamppb"
 	"testing"
+	"time"
 )
 
 func TestStore_CreateUser(t *testing.T) {
@@ -198,7 +201,7 @@ func TestStoreDeleteUser(t *testing.T) {
 
 		require.NoError(t, testStore.CreateUser(context.Background(), user))
 
-		err := testStore.DeleteUser(context.Background(), user.GetId())
+		err := testStore.DeleteUser(context.Background(), user.GetId(), "")
 		require.NoError(t, err)
 
 		_, err = testStore.GetUser(context.Background(), user.GetId())
@@ -207,9 +210,32 @@ func TestStoreDeleteUser(t *testing.T) {
 
 	})
 	t.Run("should return error for user that does not exist", func(t *testing.T) {
-		err := testStore.DeleteUser(context.Background(), uuid.NewV4().String())
+		err := testStore.DeleteUser(context.Background(), uuid.NewV4().String(), "")
 		require.Error(t, err)
 		assert.Equal(t, domain.ErrNoUser, err)
+
+		err = testStore.DeleteUser(context.Background(), uuid.NewV4().String(), "1")
+		assert.Equal(t, domain.ErrNoUser, err, "should not report an etag mismatch for a missing user")
+	})
+	t.Run("should delete a user at the version of the etag", func(t *testing.T) {
+		user := newTestUser()
+		require.NoError(t, testStore.CreateUser(context.Background(), user))
+
+		require.NoError(t, testStore.DeleteUser(context.Background(), user.GetId(), user.Etag))
+		_, err := testStore.GetUser(context.Background(), user.GetId())
+		assert.Equal(t, domain.ErrNoUser, err)
+	})
+	t.Run("should not delete a user that has changed since the etag", func(t *testing.T) {
+		user := newTestUser()
+		require.NoError(t, testStore.CreateUser(context.Background(), user))
+		staleEtag := user.Etag
+		require.NoError(t, testStore.UpdateUser(context.Background(), user,
+			[]v1.UpdateUserField{v1.UpdateUserField_UPDATE_USER_FIELD_FIRST_NAME}, ""))
+
+		err := testStore.DeleteUser(context.Background(), user.GetId(), staleEtag)
+		assert.Equal(t, domain.ErrUserEtagMismatch, err)
+		_, err = testStore.GetUser(context.Background(), user.GetId())
+		assert.NoError(t, err)
 	})
 }
 
@@ -230,16 +256,19 @@ func TestStore_UpdateUser(t *testing.T) {
 			newName = "Gophie"
 		)
 		require.NoError(t, testStore.CreateUser(context.Background(), user))
+		assert.Equal(t, "1", user.Etag)
 
 		user.FirstName = newName
 
 		err := testStore.UpdateUser(context.Background(), user,
-			[]v1.UpdateUserField{v1.UpdateUserField_UPDATE_USER_FIELD_FIRST_NAME})
+			[]v1.UpdateUserField{v1.UpdateUserField_UPDATE_USER_FIELD_FIRST_NAME}, "")
 		require.NoError(t, err)
+		assert.Equal(t, "2", user.Etag, "the version should be incremented on each write")
 
 		u, err := testStore.GetUser(context.Background(), user.GetId())
 		require.NoError(t, err)
 		assert.Equal(t, newName, u.FirstName)
+		assert.Equal(t, user.Etag, u.Etag)
 		assert.NotNil(t, user.UpdatedAt)
 	})
 	t.Run("should throw an error if user does not exist", func(t *testing.T) {
@@ -261,9 +290,93 @@ func TestStore_UpdateUser(t *testing.T) {
 		user.FirstName = newName
 
 		err := testStore.UpdateUser(context.Background(), user,
-			[]v1.UpdateUserField{v1.UpdateUserField_UPDATE_USER_FIELD_FIRST_NAME})
+			[]v1.UpdateUserField{v1.UpdateUserField_UPDATE_USER_FIELD_FIRST_NAME}, "")
 		require.Error(t, err)
 		assert.Equal(t, domain.ErrNoUser, err)
 	})
+	t.Run("should not update a user that has changed since the etag", func(t *testing.T) {
+		user := newTestUser()
+		require.NoError(t, testStore.CreateUser(context.Background(), user))
+		staleEtag := user.Etag
 
+		// the first writer with the etag wins, the second is rejected rather than overwriting it
+		first := proto.Clone(user).(*v1.User)
+		first.FirstName = "first-writer"
+		require.NoError(t, testStore.UpdateUser(context.Background(), first,
+			[]v1.UpdateUserField{v1.UpdateUserField_UPDATE_USER_FIELD_FIRST_NAME}, staleEtag))
+		second := proto.Clone(user).(*v1.User)
+		second.FirstName = "second-writer"
+		err := testStore.UpdateUser(context.Background(), second,
+			[]v1.UpdateUserField{v1.UpdateUserField_UPDATE_USER_FIELD_FIRST_NAME}, staleEtag)
+		assert.Equal(t, domain.ErrUserEtagMismatch, err)
+
+		u, err := testStore.GetUser(context.Background(), user.GetId())
+		require.NoError(t, err)
+		assert.Equal(t, "first-writer", u.FirstName)
+		assert.Equal(t, first.Etag, u.Etag)
+	})
+	t.Run("should not report an etag mismatch for a user that does not exist", func(t *testing.T) {
+		user := newTestUser()
+		user.Id = uuid.NewV4().String()
+		err := testStore.UpdateUser(context.Background(), user,
+			[]v1.UpdateUserField{v1.UpdateUserField_UPDATE_USER_FIELD_FIRST_NAME}, "1")
+		assert.Equal(t, domain.ErrNoUser, err)
+	})
+}
+
+func TestStore_ScanUsers(t *testing.T) {
+	newUser := func(country string) *v1.User {
+		return &v1.User{
+			FirstName: "Sopme",
+			LastName:  "asdasd",
+			Nickname:  "a-nickname",
+			Password:  "a-password",
+			Email:     fmt.Sprintf("anemail-%s@.com", uuid.NewV4().String()),
+			Country:   country,
+		}
+	}
+
+	t.Run("should visit every user matching the filter across batches", func(t *testing.T) {
+		users := []*v1.User{newUser("SCN"), newUser("SCN"), newUser("SCN")}
+		for _, user := range users {
+			require.NoError(t, testStore.CreateUser(context.Background(), user))
+		}
+
+		var visited []string
+		require.NoError(t, testStore.ScanUsers(context.Background(), store.ScanFilter{Countries: []string{"SCN"}}, 2,
+			func(user *v1.User) error {
+				visited = append(visited, user.Id)
+				return nil
+			}))
+		assert.ElementsMatch(t, []string{users[0].Id, users[1].Id, users[2].Id}, visited)
+	})
+
+	t.Run("should filter by ids", func(t *testing.T) {
+		user1, user2 := newUser("DEU"), newUser("DEU")
+		require.NoError(t, testStore.CreateUser(context.Background(), user1))
+		require.NoError(t, testStore.CreateUser(context.Background(), user2))
+
+		var visited []string
+		require.NoError(t, testStore.ScanUsers(context.Background(), store.ScanFilter{IDs: []string{user2.Id}}, 10,
+			func(user *v1.User) error {
+				visited = append(visited, user.Id)
+				return nil
+			}))
+		assert.Equal(t, []string{user2.Id}, visited)
+	})
+
+	t.Run("should filter by updated since", func(t *testing.T) {
+		user := newUser("UPS")
+		require.NoError(t, testStore.CreateUser(context.Background(), user))
+
+		var visited int
+		require.NoError(t, testStore.ScanUsers(context.Background(), store.ScanFilter{
+			Countries:    []string{"UPS"},
+			UpdatedSince: time.Now().Add(time.Hour),
+		}, 10, func(user *v1.User) error {
+			visited++
+			return nil
+		}))
+		assert.Equal(t, 0, visited)
+	})
 }
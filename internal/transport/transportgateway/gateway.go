@@ -0,0 +1,78 @@
+package transportgateway
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/textproto"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	userServiceV1 "github.com/jacktantram/user-service/build/go/rpc/user/v1"
+	"github.com/jacktantram/user-service/internal/transport/transportgrpc"
+	"github.com/jacktantram/user-service/pkg/logging"
+	"google.golang.org/grpc"
+)
+
+//go:embed openapi
+var openAPI embed.FS
+
+// NewHandler creates a handler translating REST/JSON requests into calls to the gRPC server at endpoint,
+// so requests pass through the same interceptors as native gRPC calls. gRPC status codes are mapped to
+// their HTTP equivalents, i.e. NotFound to 404.
+func NewHandler(ctx context.Context, endpoint string, opts ...grpc.DialOption) (http.Handler, error) {
+	mux := runtime.NewServeMux(runtime.WithIncomingHeaderMatcher(incomingHeaderMatcher),
+		runtime.WithOutgoingHeaderMatcher(outgoingHeaderMatcher))
+	if err := userServiceV1.RegisterUserServiceHandlerFromEndpoint(ctx, mux, endpoint, opts); err != nil {
+		return nil, fmt.Errorf("unable to register user service gateway: %w", err)
+	}
+	return mux, nil
+}
+
+// incomingHeaderMatcher forwards the Idempotency-Key and X-Request-Id headers as gRPC metadata, alongside the
+// headers forwarded by default.
+func incomingHeaderMatcher(key string) (string, bool) {
+	switch textproto.CanonicalMIMEHeaderKey(key) {
+	case textproto.CanonicalMIMEHeaderKey(transportgrpc.IdempotencyKeyHeader):
+		return transportgrpc.IdempotencyKeyHeader, true
+	case textproto.CanonicalMIMEHeaderKey(logging.RequestIDHeader):
+		return logging.RequestIDHeader, true
+	}
+	return runtime.DefaultHeaderMatcher(key)
+}
+
+// outgoingHeaderMatcher returns the request ID in the X-Request-Id header, other metadata is prefixed with
+// Grpc-Metadata- by default.
+func outgoingHeaderMatcher(key string) (string, bool) {
+	if key == logging.RequestIDHeader {
+		return textproto.CanonicalMIMEHeaderKey(key), true
+	}
+	return runtime.MetadataHeaderPrefix + key, true
+}
+
+// NewOpenAPIHandler serves the generated OpenAPI documentation, v2 at /v2.json and v3 at /v3.yaml.
+// It is expected to be mounted with http.StripPrefix.
+func NewOpenAPIHandler() http.Handler {
+	docs, err := fs.Sub(openAPI, "openapi")
+	if err != nil {
+		// the embedded directory is fixed at compile time
+		panic(err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v2.json", serveFile(docs, "user_service.swagger.json", "application/json"))
+	mux.HandleFunc("/v3.yaml", serveFile(docs, "openapi.yaml", "application/yaml"))
+	return mux
+}
+
+func serveFile(docs fs.FS, name string, contentType string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		b, err := fs.ReadFile(docs, name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("content-type", contentType)
+		_, _ = w.Write(b)
+	}
+}
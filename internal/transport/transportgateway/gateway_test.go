@@ -0,0 +1,192 @@
+package transportgateway_test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	v1 "github.com/jacktantram/user-service/build/go/shared/user/v1"
+	"github.com/jacktantram/user-service/internal/domain"
+	"github.com/jacktantram/user-service/internal/transport/transportgateway"
+	"github.com/jacktantram/user-service/internal/transport/transportgrpc"
+	"github.com/jacktantram/user-service/internal/transport/transportgrpc/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const userID = "a8bdce5a-31dc-4647-98b5-ce9cb343138f"
+
+func newGateway(t *testing.T, service transportgrpc.Service, interceptors ...grpc.UnaryServerInterceptor) http.Handler {
+	t.Helper()
+	lis := bufconn.Listen(1024 * 1024)
+	validator, err := transportgrpc.NewValidator()
+	require.NoError(t, err)
+	interceptors = append([]grpc.UnaryServerInterceptor{validator.UnaryServerInterceptor()}, interceptors...)
+	server, err := transportgrpc.NewServer(grpc.NewServer(grpc.ChainUnaryInterceptor(interceptors...)), service)
+	require.NoError(t, err)
+	go func() {
+		_ = server.Serve(lis)
+	}()
+	t.Cleanup(server.Stop)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	handler, err := transportgateway.NewHandler(ctx, "bufnet",
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}))
+	require.NoError(t, err)
+	return handler
+}
+
+func TestNewHandler(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		setup      func(mockService *mocks.MockService)
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:   "should get a user",
+			method: http.MethodGet,
+			path:   "/v1/users/" + userID,
+			setup: func(mockService *mocks.MockService) {
+				mockService.EXPECT().GetUser(gomock.Any(), userID).Return(&v1.User{Id: userID, FirstName: "John"}, nil)
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `"firstName":"John"`,
+		},
+		{
+			name:   "should map not found to 404",
+			method: http.MethodGet,
+			path:   "/v1/users/" + userID,
+			setup: func(mockService *mocks.MockService) {
+				mockService.EXPECT().GetUser(gomock.Any(), userID).Return(nil, domain.ErrNoUser)
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "should map invalid arguments to 400",
+			method:     http.MethodGet,
+			path:       "/v1/users/not-a-uuid",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "should list users filtered by country",
+			method: http.MethodGet,
+			path:   "/v1/users?filters.countries=GBR&limit=10",
+			setup: func(mockService *mocks.MockService) {
+				mockService.EXPECT().ListUsers(gomock.Any(), gomock.Any(), uint64(0), uint64(10)).
+					DoAndReturn(func(ctx context.Context, filters interface{ GetCountries() []string }, offset uint64, limit uint64) ([]*v1.User, error) {
+						assert.Equal(t, []string{"GBR"}, filters.GetCountries())
+						return []*v1.User{{Id: userID}}, nil
+					})
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `"id":"` + userID + `"`,
+		},
+		{
+			name:   "should delete a user",
+			method: http.MethodDelete,
+			path:   "/v1/users/" + userID,
+			setup: func(mockService *mocks.MockService) {
+				mockService.EXPECT().DeleteUser(gomock.Any(), userID, "").Return(nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:   "should create a user from the request body",
+			method: http.MethodPost,
+			path:   "/v1/users",
+			body: `{"firstName":"John","lastName":"Smith","nickname":"js","password":"password",` +
+				`"email":"john@example.com","country":"GBR"}`,
+			setup: func(mockService *mocks.MockService) {
+				mockService.EXPECT().CreateUser(gomock.Any(), gomock.Any()).Return(nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			mockService := mocks.NewMockService(gomock.NewController(t))
+			if tt.setup != nil {
+				tt.setup(mockService)
+			}
+			handler := newGateway(t, mockService)
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+			assert.Equal(t, tt.wantStatus, rec.Code, rec.Body.String())
+			if tt.wantBody != "" {
+				assert.Contains(t, rec.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestNewHandler_IdempotencyKey(t *testing.T) {
+	t.Parallel()
+	mockService := mocks.NewMockService(gomock.NewController(t))
+	mockService.EXPECT().DeleteUser(gomock.Any(), userID, "").Return(nil)
+	var key []string
+	handler := newGateway(t, mockService, func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		key = md.Get(transportgrpc.IdempotencyKeyHeader)
+		return handler(ctx, req)
+	})
+
+	req := httptest.NewRequest(http.MethodDelete, "/v1/users/"+userID, nil)
+	req.Header.Set("Idempotency-Key", "delete-john")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, []string{"delete-john"}, key)
+}
+
+func TestNewHandler_RequestID(t *testing.T) {
+	t.Parallel()
+	mockService := mocks.NewMockService(gomock.NewController(t))
+	mockService.EXPECT().DeleteUser(gomock.Any(), userID, "").Return(nil)
+	handler := newGateway(t, mockService, transportgrpc.RequestIDUnaryServerInterceptor())
+
+	req := httptest.NewRequest(http.MethodDelete, "/v1/users/"+userID, nil)
+	req.Header.Set("X-Request-Id", "a-request-id")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "a-request-id", rec.Header().Get("X-Request-Id"))
+}
+
+func TestNewOpenAPIHandler(t *testing.T) {
+	t.Parallel()
+	handler := transportgateway.NewOpenAPIHandler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v2.json", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var doc map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &doc))
+	assert.Equal(t, "2.0", doc["swagger"])
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v3.yaml", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "openapi: 3.0.3")
+}
@@ -0,0 +1,200 @@
+package transportgrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/MicahParks/keyfunc"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/jacktantram/user-service/pkg/logging"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	errUnauthenticated = status.New(codes.Unauthenticated, "missing or invalid bearer token").Err()
+
+	// signingMethods are the algorithms tokens may be signed with.
+	signingMethods = []string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodES256.Alg()}
+
+	// defaultPublicMethods are called by infrastructure such as load balancers, so don't require a token.
+	defaultPublicMethods = []string{"/grpc.health.v1.Health/", "/grpc.reflection."}
+)
+
+// Principal identifies the authenticated caller of a request.
+type Principal struct {
+	// Subject is the sub claim of the token, the user ID for end users, or the identity of a client certificate.
+	Subject string
+	// Roles are the roles granted by the roles claim of the token.
+	Roles []string
+}
+
+type principalKey struct{}
+
+// ContextWithPrincipal returns a copy of ctx carrying the principal.
+func ContextWithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the principal of an authenticated request.
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(*Principal)
+	return principal, ok
+}
+
+// AuthConfig configures how callers are authenticated.
+type AuthConfig struct {
+	// Issuer is the expected iss claim, not checked when empty.
+	Issuer string
+	// Audience is the expected aud claim, not checked when empty.
+	Audience string
+	// ClientCertificates authenticates callers without a token by the identity of their verified client
+	// certificate, see PeerIdentity.
+	ClientCertificates bool
+	// PublicMethods are prefixes of full method names that don't require a token, defaults to the health
+	// and reflection services.
+	PublicMethods []string
+}
+
+// Authenticator validates the bearer JWT sent in the authorization metadata of a request.
+type Authenticator struct {
+	keyfunc jwt.Keyfunc
+	cfg     AuthConfig
+	parser  *jwt.Parser
+}
+
+type claims struct {
+	jwt.RegisteredClaims
+	Roles []string `json:"roles"`
+}
+
+// NewAuthenticator creates an authenticator verifying token signatures with the key returned by keyfunc.
+// A nil keyfunc rejects every token, so only client certificates are accepted.
+func NewAuthenticator(keyfunc jwt.Keyfunc, cfg AuthConfig) *Authenticator {
+	if cfg.PublicMethods == nil {
+		cfg.PublicMethods = defaultPublicMethods
+	}
+	return &Authenticator{keyfunc: keyfunc, cfg: cfg, parser: jwt.NewParser(jwt.WithValidMethods(signingMethods))}
+}
+
+// Authenticate returns the principal of the bearer token in the incoming metadata of ctx, or of the
+// client certificate of a caller without a token when enabled.
+func (a *Authenticator) Authenticate(ctx context.Context) (*Principal, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		if identity, ok := PeerIdentity(ctx); ok && a.cfg.ClientCertificates {
+			return &Principal{Subject: identity}, nil
+		}
+		return nil, errors.New("missing authorization metadata")
+	}
+	if a.keyfunc == nil {
+		return nil, errors.New("bearer tokens are not accepted")
+	}
+	scheme, token, ok := strings.Cut(values[0], " ")
+	if !ok || !strings.EqualFold(scheme, "bearer") {
+		return nil, errors.New("authorization is not a bearer token")
+	}
+
+	var c claims
+	if _, err := a.parser.ParseWithClaims(token, &c, a.keyfunc); err != nil {
+		return nil, err
+	}
+	if a.cfg.Issuer != "" && !c.VerifyIssuer(a.cfg.Issuer, true) {
+		return nil, fmt.Errorf("unexpected issuer %q", c.Issuer)
+	}
+	if a.cfg.Audience != "" && !c.VerifyAudience(a.cfg.Audience, true) {
+		return nil, fmt.Errorf("unexpected audience %q", c.Audience)
+	}
+	if c.Subject == "" {
+		return nil, errors.New("token is missing a subject")
+	}
+	return &Principal{Subject: c.Subject, Roles: c.Roles}, nil
+}
+
+// UnaryServerInterceptor rejects unary calls without a valid token with Unauthenticated, otherwise
+// the principal is added to the context.
+func (a *Authenticator) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := a.authenticateCall(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor rejects streams without a valid token with Unauthenticated, otherwise
+// the principal is added to the context of the stream.
+func (a *Authenticator) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := a.authenticateCall(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+func (a *Authenticator) authenticateCall(ctx context.Context, fullMethod string) (context.Context, error) {
+	for _, prefix := range a.cfg.PublicMethods {
+		if strings.HasPrefix(fullMethod, prefix) {
+			return ctx, nil
+		}
+	}
+	principal, err := a.Authenticate(ctx)
+	if err != nil {
+		logging.FromContext(ctx).WithError(err).WithField("method", fullMethod).Info("unauthenticated call")
+		return nil, errUnauthenticated
+	}
+	return ContextWithPrincipal(ctx, principal), nil
+}
+
+// serverStream overrides the context of a stream.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// JWKSFromFile returns a keyfunc resolving token keys from a local JSON Web Key Set file.
+func JWKSFromFile(path string) (jwt.Keyfunc, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read jwks: %w", err)
+	}
+	jwks, err := keyfunc.NewJSON(b)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse jwks: %w", err)
+	}
+	return jwks.Keyfunc, nil
+}
+
+// JWKSFromURL returns a keyfunc resolving token keys from a remote JSON Web Key Set. The set is refreshed
+// every refreshInterval, and when a token has an unknown key ID, until ctx is done.
+func JWKSFromURL(ctx context.Context, url string, refreshInterval time.Duration) (jwt.Keyfunc, error) {
+	jwks, err := keyfunc.Get(url, keyfunc.Options{
+		Ctx:               ctx,
+		RefreshInterval:   refreshInterval,
+		RefreshRateLimit:  time.Minute,
+		RefreshTimeout:    10 * time.Second,
+		RefreshUnknownKID: true,
+		RefreshErrorHandler: func(err error) {
+			log.WithError(err).WithField("url", url).Error("unable to refresh jwks")
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to get jwks: %w", err)
+	}
+	return jwks.Keyfunc, nil
+}
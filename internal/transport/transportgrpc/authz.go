@@ -0,0 +1,162 @@
+package transportgrpc
+
+import (
+	"context"
+	"strings"
+
+	userServiceV1 "github.com/jacktantram/user-service/build/go/rpc/user/v1"
+	"github.com/jacktantram/user-service/pkg/logging"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// RoleAdmin may call every method.
+	RoleAdmin = "admin"
+	// RoleService is granted to other services, which may read users.
+	RoleService = "service"
+)
+
+var errPermissionDenied = status.New(codes.PermissionDenied, "caller is not allowed to call this method").Err()
+
+// Rule grants access to a method.
+type Rule struct {
+	// Roles grants access to callers with any of the roles.
+	Roles []string `yaml:"roles"`
+	// Subjects grants access to callers with any of the subjects, i.e. the SPIFFE ID of a client certificate.
+	Subjects []string `yaml:"subjects"`
+	// Self grants access to callers whose subject is the ID of the user the request is for.
+	Self bool `yaml:"self"`
+}
+
+// Policy declares which callers may call each method. A call is allowed when any rule of its method
+// matches, methods without rules are denied.
+type Policy struct {
+	// Methods maps full method names, i.e. /rpc.user.v1.UserService/GetUser, to their rules.
+	Methods map[string][]Rule `yaml:"methods"`
+}
+
+// DefaultPolicy allows admins to call every method, services to read users and end users to get
+// and update themselves.
+func DefaultPolicy() Policy {
+	admin := Rule{Roles: []string{RoleAdmin}}
+	read := Rule{Roles: []string{RoleAdmin, RoleService}}
+	self := Rule{Self: true}
+	return Policy{Methods: map[string][]Rule{
+		userServiceMethod("GetUser"):    {read, self},
+		userServiceMethod("ListUsers"):  {read},
+		userServiceMethod("CreateUser"): {admin},
+		userServiceMethod("UpdateUser"): {admin, self},
+		userServiceMethod("DeleteUser"): {admin},
+	}}
+}
+
+// userServiceMethod returns the full name of a UserService method.
+func userServiceMethod(name string) string {
+	return "/" + userServiceV1.UserService_ServiceDesc.ServiceName + "/" + name
+}
+
+// Authorizer evaluates a policy against the principal of authenticated calls.
+type Authorizer struct {
+	policy        Policy
+	publicMethods []string
+}
+
+// NewAuthorizer creates an authorizer for policy. Like authentication the health and reflection
+// services are not authorized.
+func NewAuthorizer(policy Policy) *Authorizer {
+	return &Authorizer{policy: policy, publicMethods: defaultPublicMethods}
+}
+
+// Authorize returns the rule allowing the principal to call fullMethod with req, or false when denied.
+func (a *Authorizer) Authorize(principal *Principal, fullMethod string, req interface{}) (Rule, bool) {
+	if principal == nil {
+		return Rule{}, false
+	}
+	for _, rule := range a.policy.Methods[fullMethod] {
+		if rule.matches(principal, req) {
+			return rule, true
+		}
+	}
+	return Rule{}, false
+}
+
+func (r Rule) matches(principal *Principal, req interface{}) bool {
+	for _, role := range r.Roles {
+		for _, granted := range principal.Roles {
+			if role == granted {
+				return true
+			}
+		}
+	}
+	for _, subject := range r.Subjects {
+		if subject == principal.Subject {
+			return true
+		}
+	}
+	if r.Self {
+		id, ok := requestUserID(req)
+		return ok && id != "" && id == principal.Subject
+	}
+	return false
+}
+
+// requestUserID returns the ID of the user a request is for.
+func requestUserID(req interface{}) (string, bool) {
+	switch r := req.(type) {
+	case *userServiceV1.GetUserRequest:
+		return r.GetId(), true
+	case *userServiceV1.UpdateUserRequest:
+		return r.GetUser().GetId(), true
+	case *userServiceV1.DeleteUserRequest:
+		return r.GetId(), true
+	default:
+		return "", false
+	}
+}
+
+// UnaryServerInterceptor rejects calls the policy does not allow with PermissionDenied. It must be
+// chained after authentication.
+func (a *Authorizer) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := a.authorizeCall(ctx, info.FullMethod, req); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor rejects streams the policy does not allow with PermissionDenied. Self rules
+// never match a stream as there is no request to check. It must be chained after authentication.
+func (a *Authorizer) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := a.authorizeCall(ss.Context(), info.FullMethod, nil); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+// authorizeCall evaluates the policy for a call, logging the decision for auditing.
+func (a *Authorizer) authorizeCall(ctx context.Context, fullMethod string, req interface{}) error {
+	for _, prefix := range a.publicMethods {
+		if strings.HasPrefix(fullMethod, prefix) {
+			return nil
+		}
+	}
+	principal, _ := PrincipalFromContext(ctx)
+	rule, allowed := a.Authorize(principal, fullMethod, req)
+
+	logger := logging.FromContext(ctx).WithFields(log.Fields{"method": fullMethod, "allowed": allowed})
+	if principal != nil {
+		logger = logger.WithFields(log.Fields{"subject": principal.Subject, "roles": principal.Roles})
+	}
+	if !allowed {
+		logger.Warn("authorization decision")
+		return errPermissionDenied
+	}
+	logger.WithFields(log.Fields{"rule_roles": rule.Roles, "rule_subjects": rule.Subjects, "rule_self": rule.Self}).Info("authorization decision")
+	return nil
+}
@@ -0,0 +1,103 @@
+package transportgrpc_test
+
+import (
+	"context"
+	userServiceV1 "github.com/jacktantram/user-service/build/go/rpc/user/v1"
+	v1 "github.com/jacktantram/user-service/build/go/shared/user/v1"
+	"github.com/jacktantram/user-service/internal/transport/transportgrpc"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+func TestAuthorizer_UnaryServerInterceptor(t *testing.T) {
+	t.Parallel()
+	const (
+		otherID     = "9c67d9ae-54cf-4f5e-a693-ae9931420798"
+		getUser     = "/rpc.user.v1.UserService/GetUser"
+		listUsers   = "/rpc.user.v1.UserService/ListUsers"
+		createUser  = "/rpc.user.v1.UserService/CreateUser"
+		updateUser  = "/rpc.user.v1.UserService/UpdateUser"
+		deleteUser  = "/rpc.user.v1.UserService/DeleteUser"
+		healthCheck = "/grpc.health.v1.Health/Check"
+	)
+	admin := &transportgrpc.Principal{Subject: "admin-user", Roles: []string{transportgrpc.RoleAdmin}}
+	service := &transportgrpc.Principal{Subject: "billing", Roles: []string{transportgrpc.RoleService}}
+	user := &transportgrpc.Principal{Subject: subject}
+
+	tests := []struct {
+		name      string
+		principal *transportgrpc.Principal
+		method    string
+		req       interface{}
+		wantAllow bool
+	}{
+		{name: "admin may delete users", principal: admin, method: deleteUser,
+			req: &userServiceV1.DeleteUserRequest{Id: otherID}, wantAllow: true},
+		{name: "admin may create users", principal: admin, method: createUser,
+			req: &userServiceV1.CreateUserRequest{}, wantAllow: true},
+		{name: "service may get users", principal: service, method: getUser,
+			req: &userServiceV1.GetUserRequest{Id: otherID}, wantAllow: true},
+		{name: "service may list users", principal: service, method: listUsers,
+			req: &userServiceV1.ListUsersRequest{}, wantAllow: true},
+		{name: "service may not update users", principal: service, method: updateUser,
+			req: &userServiceV1.UpdateUserRequest{User: &v1.User{Id: otherID}}},
+		{name: "user may get themselves", principal: user, method: getUser,
+			req: &userServiceV1.GetUserRequest{Id: subject}, wantAllow: true},
+		{name: "user may update themselves", principal: user, method: updateUser,
+			req: &userServiceV1.UpdateUserRequest{User: &v1.User{Id: subject}}, wantAllow: true},
+		{name: "user may not get other users", principal: user, method: getUser,
+			req: &userServiceV1.GetUserRequest{Id: otherID}},
+		{name: "user may not update other users", principal: user, method: updateUser,
+			req: &userServiceV1.UpdateUserRequest{User: &v1.User{Id: otherID}}},
+		{name: "user may not delete themselves", principal: user, method: deleteUser,
+			req: &userServiceV1.DeleteUserRequest{Id: subject}},
+		{name: "user may not list users", principal: user, method: listUsers,
+			req: &userServiceV1.ListUsersRequest{}},
+		{name: "methods without rules are denied", principal: admin, method: "/rpc.user.v1.UserService/Unknown"},
+		{name: "unauthenticated calls are denied", method: getUser, req: &userServiceV1.GetUserRequest{Id: subject}},
+		{name: "public methods are allowed", method: healthCheck, wantAllow: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+			if tt.principal != nil {
+				ctx = transportgrpc.ContextWithPrincipal(ctx, tt.principal)
+			}
+			interceptor := transportgrpc.NewAuthorizer(transportgrpc.DefaultPolicy()).UnaryServerInterceptor()
+
+			var called bool
+			_, err := interceptor(ctx, tt.req, &grpc.UnaryServerInfo{FullMethod: tt.method},
+				func(ctx context.Context, req interface{}) (interface{}, error) {
+					called = true
+					return nil, nil
+				})
+			assert.Equal(t, tt.wantAllow, called)
+			if tt.wantAllow {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Equal(t, codes.PermissionDenied, status.Code(err))
+		})
+	}
+}
+
+func TestAuthorizer_CustomPolicy(t *testing.T) {
+	t.Parallel()
+	authorizer := transportgrpc.NewAuthorizer(transportgrpc.Policy{Methods: map[string][]transportgrpc.Rule{
+		"/rpc.user.v1.UserService/ListUsers": {{Roles: []string{"support"}}},
+	}})
+
+	rule, allowed := authorizer.Authorize(&transportgrpc.Principal{Subject: "agent", Roles: []string{"support"}},
+		"/rpc.user.v1.UserService/ListUsers", &userServiceV1.ListUsersRequest{})
+	assert.True(t, allowed)
+	assert.Equal(t, []string{"support"}, rule.Roles)
+
+	_, allowed = authorizer.Authorize(&transportgrpc.Principal{Subject: "agent", Roles: []string{transportgrpc.RoleAdmin}},
+		"/rpc.user.v1.UserService/ListUsers", &userServiceV1.ListUsersRequest{})
+	assert.False(t, allowed)
+}
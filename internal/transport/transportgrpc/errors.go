@@ -0,0 +1,78 @@
+package transportgrpc
+
+import (
+	"strings"
+
+	"github.com/bufbuild/protovalidate-go"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/runtime/protoiface"
+)
+
+// errorDomain is the domain of the ErrorInfo details returned by the service.
+const errorDomain = "user-service"
+
+// Reasons of the ErrorInfo details returned for domain errors, so clients can handle them without
+// matching messages.
+const (
+	// ReasonEmailAlreadyExists is returned when the email of a user being created belongs to another user.
+	ReasonEmailAlreadyExists = "EMAIL_ALREADY_EXISTS"
+	// ReasonUserNotFound is returned when the user of a request does not exist.
+	ReasonUserNotFound = "USER_NOT_FOUND"
+	// ReasonEtagMismatch is returned when the etag of an update or delete is not the etag of the user, as it has
+	// changed since it was read.
+	ReasonEtagMismatch = "ETAG_MISMATCH"
+	// ReasonIdempotencyKeyReused is returned when an idempotency key is sent with a different request to
+	// the one it was first used for.
+	ReasonIdempotencyKeyReused = "IDEMPOTENCY_KEY_REUSED"
+	// ReasonIdempotencyKeyInProgress is returned when the request first made with an idempotency key has
+	// not completed.
+	ReasonIdempotencyKeyInProgress = "IDEMPOTENCY_KEY_IN_PROGRESS"
+)
+
+// withDetails returns st with details, or st alone when they can't be marshalled.
+func withDetails(st *status.Status, details ...protoiface.MessageV1) error {
+	withDetails, err := st.WithDetails(details...)
+	if err != nil {
+		log.WithError(err).Error("unable to add error details")
+		return st.Err()
+	}
+	return withDetails.Err()
+}
+
+// reasonError returns a status with an ErrorInfo detail giving the reason for it.
+func reasonError(code codes.Code, msg string, reason string, metadata map[string]string) error {
+	return withDetails(status.New(code, msg), &errdetails.ErrorInfo{Reason: reason, Domain: errorDomain, Metadata: metadata})
+}
+
+// userNotFound returns NotFound for a user that does not exist.
+func userNotFound(msg string, id string) error {
+	return reasonError(codes.NotFound, msg, ReasonUserNotFound, map[string]string{"id": id})
+}
+
+// etagMismatch returns Aborted for a write based on a version of a user that is no longer current.
+func etagMismatch(id string) error {
+	return reasonError(codes.Aborted, "user has changed since the etag was read", ReasonEtagMismatch,
+		map[string]string{"id": id})
+}
+
+// validationError returns InvalidArgument with a BadRequest field violation for each violated constraint,
+// identified by the path of its field in the request, i.e. user.email.
+func validationError(err *protovalidate.ValidationError) error {
+	badRequest := &errdetails.BadRequest{}
+	violations := make([]string, 0, len(err.Violations))
+	for _, violation := range err.ToProto().GetViolations() {
+		badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       violation.GetFieldPath(),
+			Description: violation.GetMessage(),
+		})
+		if violation.GetFieldPath() == "" {
+			violations = append(violations, violation.GetMessage())
+			continue
+		}
+		violations = append(violations, violation.GetFieldPath()+": "+violation.GetMessage())
+	}
+	return withDetails(status.New(codes.InvalidArgument, "invalid request: "+strings.Join(violations, ", ")), badRequest)
+}
@@ -0,0 +1,47 @@
+package transportgrpc
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// ForwardedForHeader is the metadata key in which grpc-gateway and the Connect handlers pass on the address of
+// their caller, appending it to any addresses the caller forwarded.
+const ForwardedForHeader = "x-forwarded-for"
+
+// ForwardedPeerUnaryServerInterceptor replaces the peer of a call with the last address of its x-forwarded-for
+// metadata, the one appended by the proxy, so the rate limit and logs see the caller of the proxy rather than the
+// proxy itself. Any caller can send x-forwarded-for, so it must only be installed on the in-memory server the REST
+// gateway and Connect handlers forward to. It should be first in the chain.
+func ForwardedPeerUnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return handler(forwardedPeerContext(ctx), req)
+	}
+}
+
+// ForwardedPeerStreamServerInterceptor is the streaming equivalent of ForwardedPeerUnaryServerInterceptor.
+func ForwardedPeerStreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, serverStream{ServerStream: ss, ctx: forwardedPeerContext(ss.Context())})
+	}
+}
+
+// forwardedPeerContext returns ctx with the forwarded peer, or ctx unchanged when no valid address was forwarded.
+func forwardedPeerContext(ctx context.Context) context.Context {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(ForwardedForHeader)
+	if len(values) == 0 {
+		return ctx
+	}
+	addresses := strings.Split(values[len(values)-1], ",")
+	ip := net.ParseIP(strings.TrimSpace(addresses[len(addresses)-1]))
+	if ip == nil {
+		return ctx
+	}
+	return peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: ip}})
+}
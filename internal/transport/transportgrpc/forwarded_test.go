@@ -0,0 +1,90 @@
+package transportgrpc_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jacktantram/user-service/internal/transport/transportgrpc"
+	"github.com/jacktantram/user-service/internal/transport/transportgrpc/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+func TestForwardedPeerUnaryServerInterceptor(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name         string
+		forwardedFor []string
+		wantAddr     string
+	}{
+		{
+			name:         "should use the forwarded address",
+			forwardedFor: []string{"203.0.113.7"},
+			wantAddr:     "203.0.113.7:0",
+		},
+		{
+			name:         "should use the address appended by the proxy",
+			forwardedFor: []string{"198.51.100.1, 203.0.113.7"},
+			wantAddr:     "203.0.113.7:0",
+		},
+		{
+			name:     "should keep the peer without a forwarded address",
+			wantAddr: "bufconn",
+		},
+		{
+			name:         "should keep the peer with an invalid forwarded address",
+			forwardedFor: []string{"not-an-ip"},
+			wantAddr:     "bufconn",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var handlerPeer *peer.Peer
+			server, err := transportgrpc.NewServer(grpc.NewServer(grpc.ChainUnaryInterceptor(
+				transportgrpc.ForwardedPeerUnaryServerInterceptor(),
+				func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+					handlerPeer, _ = peer.FromContext(ctx)
+					return handler(ctx, req)
+				})), mocks.NewMockService(gomock.NewController(t)))
+			require.NoError(t, err)
+			client := newHealthClient(t, server)
+
+			ctx := context.Background()
+			for _, addr := range tt.forwardedFor {
+				ctx = metadata.AppendToOutgoingContext(ctx, transportgrpc.ForwardedForHeader, addr)
+			}
+			_, err = client.Check(ctx, &healthpb.HealthCheckRequest{})
+			require.NoError(t, err)
+
+			require.NotNil(t, handlerPeer)
+			assert.Equal(t, tt.wantAddr, handlerPeer.Addr.String())
+		})
+	}
+}
+
+func TestRateLimiter_ForwardedPeer(t *testing.T) {
+	t.Parallel()
+	limiter := transportgrpc.NewRateLimiter(transportgrpc.RateLimitConfig{
+		Default: transportgrpc.RateLimit{Rate: 1}, PublicMethods: []string{}})
+	server, err := transportgrpc.NewServer(grpc.NewServer(grpc.ChainUnaryInterceptor(
+		transportgrpc.ForwardedPeerUnaryServerInterceptor(), limiter.UnaryServerInterceptor())),
+		mocks.NewMockService(gomock.NewController(t)))
+	require.NoError(t, err)
+	client := newHealthClient(t, server)
+
+	check := func(addr string) error {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), transportgrpc.ForwardedForHeader, addr)
+		_, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+		return err
+	}
+	require.NoError(t, check("203.0.113.7"))
+	assert.Error(t, check("203.0.113.7"), "the caller should be limited")
+	assert.NoError(t, check("203.0.113.8"), "other callers of the proxy should have their own limit")
+}
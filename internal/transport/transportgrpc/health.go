@@ -0,0 +1,53 @@
+package transportgrpc
+
+import (
+	"context"
+	userServiceV1 "github.com/jacktantram/user-service/build/go/rpc/user/v1"
+	log "github.com/sirupsen/logrus"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"time"
+)
+
+const defaultHealthInterval = 5 * time.Second
+
+// HealthCheck reports whether the dependencies needed to serve requests are healthy.
+type HealthCheck func(ctx context.Context) error
+
+// WatchHealth runs the health check every interval until ctx is done, reporting the server as
+// SERVING while it passes and NOT_SERVING otherwise. It blocks, so is expected to run in a goroutine.
+func (s *Server) WatchHealth(ctx context.Context) {
+	if s.healthCheck == nil {
+		return
+	}
+	ticker := time.NewTicker(s.healthInterval)
+	defer ticker.Stop()
+	for {
+		s.checkHealth(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Shutdown reports the server as NOT_SERVING, ignoring later health checks, so load balancers
+// stop routing to it before it is stopped.
+func (s *Server) Shutdown() {
+	s.health.Shutdown()
+}
+
+func (s *Server) checkHealth(ctx context.Context) {
+	if err := s.healthCheck(ctx); err != nil {
+		log.WithError(err).Warn("health check failed, grpc server not serving")
+		s.setServingStatus(healthpb.HealthCheckResponse_NOT_SERVING)
+		return
+	}
+	s.setServingStatus(healthpb.HealthCheckResponse_SERVING)
+}
+
+// setServingStatus sets the status of the server as a whole and of the user service.
+func (s *Server) setServingStatus(status healthpb.HealthCheckResponse_ServingStatus) {
+	s.health.SetServingStatus("", status)
+	s.health.SetServingStatus(userServiceV1.UserService_ServiceDesc.ServiceName, status)
+}
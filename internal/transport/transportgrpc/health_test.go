@@ -0,0 +1,137 @@
+package transportgrpc_test
+
+import (
+	"context"
+	"errors"
+	"github.com/golang/mock/gomock"
+	"github.com/jacktantram/user-service/internal/transport/transportgrpc"
+	"github.com/jacktantram/user-service/internal/transport/transportgrpc/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+	"net"
+	"testing"
+	"time"
+)
+
+func newHealthClient(t *testing.T, s *transportgrpc.Server) healthpb.HealthClient {
+	t.Helper()
+	lis := bufconn.Listen(1024 * 1024)
+	go func() {
+		_ = s.Serve(lis)
+	}()
+	t.Cleanup(s.Stop)
+	conn, err := grpc.Dial("bufnet", grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return healthpb.NewHealthClient(conn)
+}
+
+func servingStatus(t *testing.T, client healthpb.HealthClient, service string) healthpb.HealthCheckResponse_ServingStatus {
+	t.Helper()
+	resp, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+	require.NoError(t, err)
+	return resp.GetStatus()
+}
+
+func TestServer_Health(t *testing.T) {
+	t.Parallel()
+	errUnhealthy := errors.New("postgres unreachable")
+	tests := []struct {
+		name       string
+		opts       []transportgrpc.Option
+		watch      bool
+		shutdown   bool
+		wantStatus healthpb.HealthCheckResponse_ServingStatus
+	}{
+		{
+			name:       "should be serving without a health check",
+			wantStatus: healthpb.HealthCheckResponse_SERVING,
+		},
+		{
+			name: "should not be serving until the health check has run",
+			opts: []transportgrpc.Option{transportgrpc.WithHealthCheck(func(ctx context.Context) error {
+				return nil
+			}, time.Hour)},
+			wantStatus: healthpb.HealthCheckResponse_NOT_SERVING,
+		},
+		{
+			name: "should be serving when the health check passes",
+			opts: []transportgrpc.Option{transportgrpc.WithHealthCheck(func(ctx context.Context) error {
+				return nil
+			}, time.Millisecond)},
+			watch:      true,
+			wantStatus: healthpb.HealthCheckResponse_SERVING,
+		},
+		{
+			name: "should not be serving when the health check fails",
+			opts: []transportgrpc.Option{transportgrpc.WithHealthCheck(func(ctx context.Context) error {
+				return errUnhealthy
+			}, time.Millisecond)},
+			watch:      true,
+			wantStatus: healthpb.HealthCheckResponse_NOT_SERVING,
+		},
+		{
+			name: "should not be serving once shutdown",
+			opts: []transportgrpc.Option{transportgrpc.WithHealthCheck(func(ctx context.Context) error {
+				return nil
+			}, time.Millisecond)},
+			watch:      true,
+			shutdown:   true,
+			wantStatus: healthpb.HealthCheckResponse_NOT_SERVING,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			s, err := transportgrpc.NewServer(grpc.NewServer(), mocks.NewMockService(gomock.NewController(t)), tt.opts...)
+			require.NoError(t, err)
+			client := newHealthClient(t, s)
+
+			if tt.watch {
+				ctx, cancel := context.WithCancel(context.Background())
+				t.Cleanup(cancel)
+				go s.WatchHealth(ctx)
+				// allow the first checks to run
+				time.Sleep(20 * time.Millisecond)
+			}
+			if tt.shutdown {
+				s.Shutdown()
+				time.Sleep(20 * time.Millisecond)
+			}
+
+			assert.Equal(t, tt.wantStatus, servingStatus(t, client, ""))
+			assert.Equal(t, tt.wantStatus, servingStatus(t, client, "rpc.user.v1.UserService"))
+		})
+	}
+}
+
+func TestServer_Reflection(t *testing.T) {
+	t.Parallel()
+	const reflectionService = "grpc.reflection.v1alpha.ServerReflection"
+	tests := []struct {
+		name string
+		opts []transportgrpc.Option
+		want bool
+	}{
+		{name: "should not register reflection by default"},
+		{name: "should register reflection when enabled", opts: []transportgrpc.Option{transportgrpc.WithReflection()}, want: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			s, err := transportgrpc.NewServer(grpc.NewServer(), mocks.NewMockService(gomock.NewController(t)), tt.opts...)
+			require.NoError(t, err)
+			_, registered := s.GetServiceInfo()[reflectionService]
+			assert.Equal(t, tt.want, registered)
+		})
+	}
+}
@@ -0,0 +1,272 @@
+package transportgrpc
+
+//go:generate mockgen -source=idempotency.go -destination=mocks/mock_idempotency.go -package=mocks
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	v1 "github.com/jacktantram/user-service/build/go/shared/user/v1"
+	"github.com/jacktantram/user-service/internal/domain"
+	"github.com/jacktantram/user-service/pkg/logging"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+const (
+	// IdempotencyKeyHeader is the request metadata key of the idempotency key a client sends, so retries
+	// of the request are only applied once.
+	IdempotencyKeyHeader = "idempotency-key"
+	// IdempotentReplayedHeader is set on responses that are the replay of an earlier request.
+	IdempotentReplayedHeader = "idempotent-replayed"
+
+	defaultIdempotencyTTL = 24 * time.Hour
+	// idempotencyLockTimeout is how long a key is held by a request in progress, so it can be retried
+	// when the server stops before the request completes.
+	idempotencyLockTimeout  = time.Minute
+	idempotencyStoreTimeout = 5 * time.Second
+	maxIdempotencyKeyLength = 255
+)
+
+// IdempotencyStore persists idempotency keys and the responses of their requests.
+type IdempotencyStore interface {
+	AddIdempotencyKey(ctx context.Context, key *domain.IdempotencyKey) error
+	GetIdempotencyKey(ctx context.Context, caller string, method string, key string) (*domain.IdempotencyKey, error)
+	CompleteIdempotencyKey(ctx context.Context, key *domain.IdempotencyKey) error
+	DeleteIdempotencyKey(ctx context.Context, caller string, method string, key string) error
+	DeleteExpiredIdempotencyKeys(ctx context.Context) (int64, error)
+}
+
+// IdempotencyConfig configures which methods accept idempotency keys.
+type IdempotencyConfig struct {
+	// TTL is how long the response of a request is replayed for, defaults to 24 hours.
+	TTL time.Duration
+	// Methods are the full method names accepting idempotency keys, defaults to the UserService methods
+	// creating, updating and deleting users.
+	Methods []string
+}
+
+// Idempotency replays the original response to requests retried with the same idempotency key.
+type Idempotency struct {
+	store   IdempotencyStore
+	ttl     time.Duration
+	methods map[string]struct{}
+}
+
+// NewIdempotency creates an idempotency interceptor persisting keys to store.
+func NewIdempotency(store IdempotencyStore, cfg IdempotencyConfig) *Idempotency {
+	if cfg.TTL <= 0 {
+		cfg.TTL = defaultIdempotencyTTL
+	}
+	if cfg.Methods == nil {
+		cfg.Methods = []string{userServiceMethod("CreateUser"), userServiceMethod("UpdateUser"),
+			userServiceMethod("DeleteUser")}
+	}
+	methods := make(map[string]struct{}, len(cfg.Methods))
+	for _, method := range cfg.Methods {
+		methods[method] = struct{}{}
+	}
+	return &Idempotency{store: store, ttl: cfg.TTL, methods: methods}
+}
+
+// UnaryServerInterceptor applies a request with an idempotency key once, recording its response. A retry
+// with the same key and request returns the recorded response, a retry with a different request is
+// rejected with FailedPrecondition and a retry while the request is in progress with Aborted. Failed
+// requests are not recorded so they can be retried. It should be chained after authentication, as keys
+// are scoped to the caller.
+func (i *Idempotency) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		key := idempotencyKey(ctx)
+		msg, ok := req.(proto.Message)
+		if _, accepted := i.methods[info.FullMethod]; key == "" || !accepted || !ok {
+			return handler(ctx, req)
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			return nil, status.Errorf(codes.InvalidArgument, "%s must be at most %d characters", IdempotencyKeyHeader,
+				maxIdempotencyKeyLength)
+		}
+		fingerprint, err := requestFingerprint(msg)
+		if err != nil {
+			logging.FromContext(ctx).WithError(err).WithField("method", info.FullMethod).Error("unable to fingerprint request")
+			return nil, errSomethingWentWrong
+		}
+
+		record := &domain.IdempotencyKey{
+			Caller:      idempotencyCaller(ctx),
+			Method:      info.FullMethod,
+			Key:         key,
+			Fingerprint: fingerprint,
+			ExpiresAt:   time.Now().Add(idempotencyLockTimeout),
+		}
+		logger := logging.FromContext(ctx).WithFields(log.Fields{"method": record.Method, "idempotency_key": record.Key})
+		if err = i.store.AddIdempotencyKey(ctx, record); err != nil {
+			if errors.Is(err, domain.ErrIdempotencyKeyExists) {
+				return i.replay(ctx, record)
+			}
+			logger.WithError(err).Error("unable to add idempotency key")
+			return nil, errSomethingWentWrong
+		}
+
+		resp, err := handler(ctx, req)
+		// the client may have given up waiting, but the outcome must still be recorded for its retry
+		storeCtx, cancel := context.WithTimeout(context.Background(), idempotencyStoreTimeout)
+		defer cancel()
+		if err != nil {
+			if deleteErr := i.store.DeleteIdempotencyKey(storeCtx, record.Caller, record.Method, record.Key); deleteErr != nil {
+				logger.WithError(deleteErr).Error("unable to delete idempotency key of failed request")
+			}
+			return resp, err
+		}
+		if record.Response, err = proto.Marshal(redactResponse(resp.(proto.Message))); err != nil {
+			logger.WithError(err).Error("unable to marshal idempotent response")
+			return resp, nil
+		}
+		record.ExpiresAt = time.Now().Add(i.ttl)
+		if err = i.store.CompleteIdempotencyKey(storeCtx, record); err != nil {
+			logger.WithError(err).Error("unable to record idempotent response")
+		}
+		return resp, nil
+	}
+}
+
+// replay returns the recorded response of the request made with the key of record.
+func (i *Idempotency) replay(ctx context.Context, record *domain.IdempotencyKey) (interface{}, error) {
+	existing, err := i.store.GetIdempotencyKey(ctx, record.Caller, record.Method, record.Key)
+	if err != nil {
+		if errors.Is(err, domain.ErrNoIdempotencyKey) {
+			// the request failed or expired since the key was added
+			return nil, reasonError(codes.Aborted, "request with this idempotency key did not complete, retry it",
+				ReasonIdempotencyKeyInProgress, nil)
+		}
+		logging.FromContext(ctx).WithError(err).WithField("idempotency_key", record.Key).Error("unable to get idempotency key")
+		return nil, errSomethingWentWrong
+	}
+	if !bytes.Equal(existing.Fingerprint, record.Fingerprint) {
+		return nil, reasonError(codes.FailedPrecondition, "idempotency key has already been used for a different request",
+			ReasonIdempotencyKeyReused, nil)
+	}
+	if existing.Response == nil {
+		return nil, reasonError(codes.Aborted, "request with this idempotency key is in progress",
+			ReasonIdempotencyKeyInProgress, nil)
+	}
+	resp, err := newResponse(record.Method)
+	if err == nil {
+		err = proto.Unmarshal(existing.Response, resp)
+	}
+	if err != nil {
+		logging.FromContext(ctx).WithError(err).WithField("idempotency_key", record.Key).Error("unable to unmarshal idempotent response")
+		return nil, errSomethingWentWrong
+	}
+	if err = grpc.SetHeader(ctx, metadata.Pairs(IdempotentReplayedHeader, "true")); err != nil {
+		logging.FromContext(ctx).WithError(err).Error("unable to set idempotent replayed header")
+	}
+	return resp, nil
+}
+
+// PurgeExpired deletes expired idempotency keys every interval until ctx is done. It blocks, so is
+// expected to run in a goroutine. A non-positive interval disables purging.
+func (i *Idempotency) PurgeExpired(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		log.Warn("purging of expired idempotency keys is disabled")
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := i.store.DeleteExpiredIdempotencyKeys(ctx)
+			if err != nil {
+				log.WithError(err).Error("unable to delete expired idempotency keys")
+				continue
+			}
+			log.WithField("deleted", deleted).Debug("deleted expired idempotency keys")
+		}
+	}
+}
+
+func idempotencyKey(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(IdempotencyKeyHeader); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// idempotencyCaller scopes keys to the authenticated caller, so callers can't replay each other's responses.
+// Without a principal, i.e. when authentication is disabled, keys are scoped to the client certificate identity
+// or IP address of the caller.
+func idempotencyCaller(ctx context.Context) string {
+	if principal, ok := PrincipalFromContext(ctx); ok && principal != nil {
+		return principal.Subject
+	}
+	return callerKey(ctx)
+}
+
+// redactResponse returns a copy of resp with sensitive fields such as password cleared from its users, so they
+// aren't stored with its idempotency key. A replayed response omits them.
+func redactResponse(resp proto.Message) proto.Message {
+	redacted := proto.Clone(resp)
+	msg := redacted.ProtoReflect()
+	fields := msg.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		if field.Kind() != protoreflect.MessageKind || field.IsMap() || !msg.Has(field) {
+			continue
+		}
+		if field.IsList() {
+			list := msg.Get(field).List()
+			for j := 0; j < list.Len(); j++ {
+				if user, ok := list.Get(j).Message().Interface().(*v1.User); ok {
+					list.Set(j, protoreflect.ValueOfMessage(domain.RedactUser(user).ProtoReflect()))
+				}
+			}
+			continue
+		}
+		if user, ok := msg.Get(field).Message().Interface().(*v1.User); ok {
+			msg.Set(field, protoreflect.ValueOfMessage(domain.RedactUser(user).ProtoReflect()))
+		}
+	}
+	return redacted
+}
+
+func requestFingerprint(msg proto.Message) ([]byte, error) {
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(b)
+	return sum[:], nil
+}
+
+// newResponse returns an empty response message of a method, i.e. CreateUserResponse for
+// /rpc.user.v1.UserService/CreateUser.
+func newResponse(fullMethod string) (proto.Message, error) {
+	name := protoreflect.FullName(strings.Replace(strings.TrimPrefix(fullMethod, "/"), "/", ".", 1))
+	desc, err := protoregistry.GlobalFiles.FindDescriptorByName(name)
+	if err != nil {
+		return nil, err
+	}
+	method, ok := desc.(protoreflect.MethodDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a method", name)
+	}
+	messageType, err := protoregistry.GlobalTypes.FindMessageByName(method.Output().FullName())
+	if err != nil {
+		return nil, err
+	}
+	return messageType.New().Interface(), nil
+}
@@ -0,0 +1,253 @@
+package transportgrpc_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	userServiceV1 "github.com/jacktantram/user-service/build/go/rpc/user/v1"
+	v1 "github.com/jacktantram/user-service/build/go/shared/user/v1"
+	"github.com/jacktantram/user-service/internal/domain"
+	"github.com/jacktantram/user-service/internal/transport/transportgrpc"
+	"github.com/jacktantram/user-service/internal/transport/transportgrpc/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	createUser     = "/rpc.user.v1.UserService/CreateUser"
+	idempotencyKey = "5b0c2b1e-create-john"
+)
+
+func withIdempotencyKey(key string) context.Context {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(transportgrpc.IdempotencyKeyHeader, key))
+	return transportgrpc.ContextWithPrincipal(ctx, &transportgrpc.Principal{Subject: subject})
+}
+
+func TestIdempotency_UnaryServerInterceptor(t *testing.T) {
+	t.Parallel()
+	req := &userServiceV1.CreateUserRequest{User: validUser()}
+	created := &userServiceV1.CreateUserResponse{User: &v1.User{Id: "a8bdce5a-31dc-4647-98b5-ce9cb343138f"}}
+	createdWithPassword := &userServiceV1.CreateUserResponse{User: &v1.User{Id: "a8bdce5a-31dc-4647-98b5-ce9cb343138f",
+		Password: "hunter2"}}
+	createdBytes, err := proto.Marshal(created)
+	require.NoError(t, err)
+
+	// existing returns the key recorded by an earlier request, with the fingerprint of req
+	existing := func(store *mocks.MockIdempotencyStore, response []byte, sameRequest bool) {
+		var fingerprint []byte
+		store.EXPECT().AddIdempotencyKey(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, key *domain.IdempotencyKey) error {
+				fingerprint = key.Fingerprint
+				if !sameRequest {
+					fingerprint = []byte("another request")
+				}
+				return domain.ErrIdempotencyKeyExists
+			})
+		store.EXPECT().GetIdempotencyKey(gomock.Any(), subject, createUser, idempotencyKey).
+			DoAndReturn(func(ctx context.Context, caller string, method string, key string) (*domain.IdempotencyKey, error) {
+				return &domain.IdempotencyKey{Caller: caller, Method: method, Key: key, Fingerprint: fingerprint,
+					Response: response}, nil
+			})
+	}
+
+	tests := []struct {
+		name        string
+		ctx         context.Context
+		method      string
+		setup       func(store *mocks.MockIdempotencyStore)
+		handlerResp proto.Message
+		handlerErr  error
+		wantHandler bool
+		wantResp    proto.Message
+		wantCode    codes.Code
+	}{
+		{
+			name:        "should call the handler without an idempotency key",
+			ctx:         context.Background(),
+			wantHandler: true,
+			wantResp:    created,
+		},
+		{
+			name:        "should call the handler for methods not accepting idempotency keys",
+			ctx:         withIdempotencyKey(idempotencyKey),
+			method:      "/rpc.user.v1.UserService/GetUser",
+			wantHandler: true,
+			wantResp:    created,
+		},
+		{
+			name: "should record the response of the first request",
+			ctx:  withIdempotencyKey(idempotencyKey),
+			setup: func(store *mocks.MockIdempotencyStore) {
+				store.EXPECT().AddIdempotencyKey(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(ctx context.Context, key *domain.IdempotencyKey) error {
+						assert.Equal(t, subject, key.Caller)
+						assert.Equal(t, idempotencyKey, key.Key)
+						assert.NotEmpty(t, key.Fingerprint)
+						assert.Nil(t, key.Response)
+						return nil
+					})
+				store.EXPECT().CompleteIdempotencyKey(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(ctx context.Context, key *domain.IdempotencyKey) error {
+						assert.Equal(t, createdBytes, key.Response)
+						assert.WithinDuration(t, time.Now().Add(time.Hour), key.ExpiresAt, time.Minute)
+						return nil
+					})
+			},
+			wantHandler: true,
+			wantResp:    created,
+		},
+		{
+			name: "should not store the password of the response",
+			ctx:  withIdempotencyKey(idempotencyKey),
+			setup: func(store *mocks.MockIdempotencyStore) {
+				store.EXPECT().AddIdempotencyKey(gomock.Any(), gomock.Any()).Return(nil)
+				store.EXPECT().CompleteIdempotencyKey(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(ctx context.Context, key *domain.IdempotencyKey) error {
+						var stored userServiceV1.CreateUserResponse
+						require.NoError(t, proto.Unmarshal(key.Response, &stored))
+						assert.Equal(t, createdWithPassword.User.GetId(), stored.GetUser().GetId())
+						assert.Empty(t, stored.GetUser().GetPassword())
+						return nil
+					})
+			},
+			handlerResp: createdWithPassword,
+			wantHandler: true,
+			wantResp:    createdWithPassword,
+		},
+		{
+			name: "should scope keys to the address of callers without a principal",
+			ctx: metadata.NewIncomingContext(withPeerIP("10.0.0.1"),
+				metadata.Pairs(transportgrpc.IdempotencyKeyHeader, idempotencyKey)),
+			setup: func(store *mocks.MockIdempotencyStore) {
+				store.EXPECT().AddIdempotencyKey(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(ctx context.Context, key *domain.IdempotencyKey) error {
+						assert.Equal(t, "ip:10.0.0.1", key.Caller)
+						return nil
+					})
+				store.EXPECT().CompleteIdempotencyKey(gomock.Any(), gomock.Any()).Return(nil)
+			},
+			wantHandler: true,
+			wantResp:    created,
+		},
+		{
+			name: "should delete the key of a failed request so it can be retried",
+			ctx:  withIdempotencyKey(idempotencyKey),
+			setup: func(store *mocks.MockIdempotencyStore) {
+				store.EXPECT().AddIdempotencyKey(gomock.Any(), gomock.Any()).Return(nil)
+				store.EXPECT().DeleteIdempotencyKey(gomock.Any(), subject, createUser, idempotencyKey).Return(nil)
+			},
+			handlerErr:  status.Error(codes.AlreadyExists, "user already exists with this email"),
+			wantHandler: true,
+			wantCode:    codes.AlreadyExists,
+		},
+		{
+			name: "should replay the response of a completed request",
+			ctx:  withIdempotencyKey(idempotencyKey),
+			setup: func(store *mocks.MockIdempotencyStore) {
+				existing(store, createdBytes, true)
+			},
+			wantResp: created,
+		},
+		{
+			name: "should reject a key reused for a different request",
+			ctx:  withIdempotencyKey(idempotencyKey),
+			setup: func(store *mocks.MockIdempotencyStore) {
+				existing(store, createdBytes, false)
+			},
+			wantCode: codes.FailedPrecondition,
+		},
+		{
+			name: "should reject a retry while the request is in progress",
+			ctx:  withIdempotencyKey(idempotencyKey),
+			setup: func(store *mocks.MockIdempotencyStore) {
+				existing(store, nil, true)
+			},
+			wantCode: codes.Aborted,
+		},
+		{
+			name:     "should reject a key that is too long",
+			ctx:      withIdempotencyKey(strings.Repeat("k", 256)),
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name: "should return internal if the key can't be added",
+			ctx:  withIdempotencyKey(idempotencyKey),
+			setup: func(store *mocks.MockIdempotencyStore) {
+				store.EXPECT().AddIdempotencyKey(gomock.Any(), gomock.Any()).Return(errors.New("connection refused"))
+			},
+			wantCode: codes.Internal,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			store := mocks.NewMockIdempotencyStore(gomock.NewController(t))
+			if tt.setup != nil {
+				tt.setup(store)
+			}
+			method := tt.method
+			if method == "" {
+				method = createUser
+			}
+			interceptor := transportgrpc.NewIdempotency(store, transportgrpc.IdempotencyConfig{TTL: time.Hour}).
+				UnaryServerInterceptor()
+
+			var called bool
+			resp, err := interceptor(tt.ctx, req, &grpc.UnaryServerInfo{FullMethod: method},
+				func(ctx context.Context, req interface{}) (interface{}, error) {
+					called = true
+					if tt.handlerErr != nil {
+						return nil, tt.handlerErr
+					}
+					if tt.handlerResp != nil {
+						return tt.handlerResp, nil
+					}
+					return created, nil
+				})
+			assert.Equal(t, tt.wantHandler, called)
+			if tt.wantCode != codes.OK {
+				assert.Equal(t, tt.wantCode, status.Code(err))
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, proto.Equal(tt.wantResp, resp.(proto.Message)))
+		})
+	}
+}
+
+func TestIdempotency_PurgeExpired(t *testing.T) {
+	t.Parallel()
+
+	t.Run("should delete expired keys every interval until the context is done", func(t *testing.T) {
+		t.Parallel()
+		store := mocks.NewMockIdempotencyStore(gomock.NewController(t))
+		ctx, cancel := context.WithCancel(context.Background())
+		store.EXPECT().DeleteExpiredIdempotencyKeys(gomock.Any()).
+			DoAndReturn(func(ctx context.Context) (int64, error) {
+				cancel()
+				return 1, nil
+			}).MinTimes(1)
+
+		transportgrpc.NewIdempotency(store, transportgrpc.IdempotencyConfig{}).PurgeExpired(ctx, time.Millisecond)
+	})
+
+	t.Run("should not purge with a non-positive interval", func(t *testing.T) {
+		t.Parallel()
+		store := mocks.NewMockIdempotencyStore(gomock.NewController(t))
+		store.EXPECT().DeleteExpiredIdempotencyKeys(gomock.Any()).Times(0)
+
+		idempotency := transportgrpc.NewIdempotency(store, transportgrpc.IdempotencyConfig{})
+		idempotency.PurgeExpired(context.Background(), 0)
+		idempotency.PurgeExpired(context.Background(), -time.Minute)
+	})
+}
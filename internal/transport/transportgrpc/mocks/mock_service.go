@@ -51,17 +51,17 @@ func (mr *MockServiceMockRecorder) CreateUser(ctx, user interface{}) *gomock.Cal
 }
 
 // DeleteUser mocks base method.
-func (m *MockService) DeleteUser(ctx context.Context, id string) error {
+func (m *MockService) DeleteUser(ctx context.Context, id, etag string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteUser", ctx, id)
+	ret := m.ctrl.Call(m, "DeleteUser", ctx, id, etag)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // DeleteUser indicates an expected call of DeleteUser.
-func (mr *MockServiceMockRecorder) DeleteUser(ctx, id interface{}) *gomock.Call {
+func (mr *MockServiceMockRecorder) DeleteUser(ctx, id, etag interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUser", reflect.TypeOf((*MockService)(nil).DeleteUser), ctx, id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUser", reflect.TypeOf((*MockService)(nil).DeleteUser), ctx, id, etag)
 }
 
 // GetUser mocks base method.
@@ -95,15 +95,15 @@ func (mr *MockServiceMockRecorder) ListUsers(ctx, filters, offset, limit interfa
 }
 
 // UpdateUser mocks base method.
-func (m *MockService) UpdateUser(ctx context.Context, userToUpdate *v10.User, updateFields []v10.UpdateUserField) error {
+func (m *MockService) UpdateUser(ctx context.Context, userToUpdate *v10.User, updateFields []v10.UpdateUserField, etag string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateUser", ctx, userToUpdate, updateFields)
+	ret := m.ctrl.Call(m, "UpdateUser", ctx, userToUpdate, updateFields, etag)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // UpdateUser indicates an expected call of UpdateUser.
-func (mr *MockServiceMockRecorder) UpdateUser(ctx, userToUpdate, updateFields interface{}) *gomock.Call {
+func (mr *MockServiceMockRecorder) UpdateUser(ctx, userToUpdate, updateFields, etag interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUser", reflect.TypeOf((*MockService)(nil).UpdateUser), ctx, userToUpdate, updateFields)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUser", reflect.TypeOf((*MockService)(nil).UpdateUser), ctx, userToUpdate, updateFields, etag)
 }
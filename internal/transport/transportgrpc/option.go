@@ -1,15 +1,26 @@
 package transportgrpc
 
 import (
-	"github.com/go-playground/validator/v10"
+	"time"
 )
 
 // Option allows functional options to be passed into server
 type Option func(s *Server)
 
-// WithValidator allows the caller to override the default validator
-func WithValidator(validate *validator.Validate) Option {
+// WithHealthCheck drives the serving status of the grpc.health.v1.Health service from check,
+// which is run every interval once WatchHealth is called.
+func WithHealthCheck(check HealthCheck, interval time.Duration) Option {
 	return func(s *Server) {
-		s.validate = validate
+		s.healthCheck = check
+		if interval > 0 {
+			s.healthInterval = interval
+		}
+	}
+}
+
+// WithReflection registers the server reflection service, allowing tools such as grpcurl to discover the API.
+func WithReflection() Option {
+	return func(s *Server) {
+		s.reflection = true
 	}
 }
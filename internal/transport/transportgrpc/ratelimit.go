@@ -0,0 +1,219 @@
+package transportgrpc
+
+import (
+	"context"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jacktantram/user-service/pkg/logging"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+const (
+	// RetryAfterHeader is the metadata key telling a throttled caller how many seconds to wait before retrying.
+	RetryAfterHeader = "retry-after"
+
+	defaultIdleTimeout = 10 * time.Minute
+)
+
+// RateLimit is a token bucket allowing bursts of Burst calls, refilled at Rate calls per second.
+type RateLimit struct {
+	Rate float64 `yaml:"rate"`
+	// Burst defaults to the rate rounded up, and is at least one.
+	Burst int `yaml:"burst"`
+}
+
+// RateLimitConfig configures the limits of each caller. Every caller has its own bucket for each method.
+type RateLimitConfig struct {
+	// Default limits methods without their own limit, they are not limited when its rate is zero.
+	Default RateLimit
+	// Methods maps full method names, i.e. /rpc.user.v1.UserService/ListUsers, to their limit.
+	Methods map[string]RateLimit
+	// IdleTimeout forgets the buckets of callers that have not called a method for the duration,
+	// defaults to 10 minutes.
+	IdleTimeout time.Duration
+	// PublicMethods are prefixes of full method names that are not limited, defaults to the health
+	// and reflection services.
+	PublicMethods []string
+}
+
+// RateLimiter throttles callers exceeding their limits with ResourceExhausted. Callers are identified
+// by their authenticated subject, otherwise their client certificate identity or IP address. Calls through
+// the REST gateway are identified by the address it forwards, see ForwardedPeerUnaryServerInterceptor.
+type RateLimiter struct {
+	cfg RateLimitConfig
+
+	mu          sync.Mutex
+	buckets     map[bucketKey]*bucket
+	lastEvicted time.Time
+
+	throttled *prometheus.CounterVec
+}
+
+type bucketKey struct {
+	method string
+	caller string
+}
+
+type bucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// NewRateLimiter creates a rate limiter enforcing cfg.
+func NewRateLimiter(cfg RateLimitConfig) *RateLimiter {
+	if cfg.IdleTimeout <= 0 {
+		cfg.IdleTimeout = defaultIdleTimeout
+	}
+	if cfg.PublicMethods == nil {
+		cfg.PublicMethods = defaultPublicMethods
+	}
+	return &RateLimiter{
+		cfg:         cfg,
+		buckets:     make(map[bucketKey]*bucket),
+		lastEvicted: time.Now(),
+		throttled: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "user_service_grpc_rate_limited_total",
+			Help: "Number of grpc calls rejected for exceeding their rate limit.",
+		}, []string{"grpc_method"}),
+	}
+}
+
+// Collector returns the counter of throttled calls, labelled by method.
+func (l *RateLimiter) Collector() prometheus.Collector {
+	return l.throttled
+}
+
+// Allow takes a token from the bucket of the caller for fullMethod. When the bucket is empty it
+// returns false and how long until a token is available.
+func (l *RateLimiter) Allow(ctx context.Context, fullMethod string) (time.Duration, bool) {
+	limit, ok := l.cfg.Methods[fullMethod]
+	if !ok {
+		limit = l.cfg.Default
+	}
+	if limit.Rate <= 0 {
+		return 0, true
+	}
+	now := time.Now()
+	key := bucketKey{method: fullMethod, caller: callerKey(ctx)}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.evict(now)
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{limiter: rate.NewLimiter(rate.Limit(limit.Rate), burst(limit))}
+		l.buckets[key] = b
+	}
+	b.lastSeen = now
+	reservation := b.limiter.ReserveN(now, 1)
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		return delay, false
+	}
+	return 0, true
+}
+
+// evict forgets idle buckets at most once every idle timeout, a bucket idle for that long has
+// refilled so is equivalent to a new one.
+func (l *RateLimiter) evict(now time.Time) {
+	if now.Sub(l.lastEvicted) < l.cfg.IdleTimeout {
+		return
+	}
+	for key, b := range l.buckets {
+		if now.Sub(b.lastSeen) >= l.cfg.IdleTimeout {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastEvicted = now
+}
+
+func burst(limit RateLimit) int {
+	if limit.Burst > 0 {
+		return limit.Burst
+	}
+	return int(math.Max(1, math.Ceil(limit.Rate)))
+}
+
+// callerKey identifies the caller of a request, preferring the authenticated principal.
+func callerKey(ctx context.Context) string {
+	if principal, ok := PrincipalFromContext(ctx); ok && principal != nil {
+		return "subject:" + principal.Subject
+	}
+	if identity, ok := PeerIdentity(ctx); ok {
+		return "peer:" + identity
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		host, _, err := net.SplitHostPort(p.Addr.String())
+		if err != nil {
+			host = p.Addr.String()
+		}
+		return "ip:" + host
+	}
+	return ""
+}
+
+// UnaryServerInterceptor rejects calls exceeding their limit with ResourceExhausted. It should be chained
+// after authentication so callers are limited by their subject.
+func (l *RateLimiter) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := l.limitCall(ctx, info.FullMethod, func(md metadata.MD) error {
+			return grpc.SetHeader(ctx, md)
+		}); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor rejects streams exceeding their limit with ResourceExhausted. It should be
+// chained after authentication so callers are limited by their subject.
+func (l *RateLimiter) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := l.limitCall(ss.Context(), info.FullMethod, ss.SetHeader); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+// limitCall returns ResourceExhausted when the caller exceeded the limit of fullMethod, sending how
+// long to wait as retry-after metadata and RetryInfo details.
+func (l *RateLimiter) limitCall(ctx context.Context, fullMethod string, setHeader func(metadata.MD) error) error {
+	for _, prefix := range l.cfg.PublicMethods {
+		if strings.HasPrefix(fullMethod, prefix) {
+			return nil
+		}
+	}
+	delay, ok := l.Allow(ctx, fullMethod)
+	if ok {
+		return nil
+	}
+	l.throttled.WithLabelValues(fullMethod).Inc()
+	logging.FromContext(ctx).WithFields(log.Fields{"method": fullMethod, "caller": callerKey(ctx), "retry_after": delay}).
+		Info("rate limited call")
+
+	seconds := int(math.Ceil(delay.Seconds()))
+	if err := setHeader(metadata.Pairs(RetryAfterHeader, strconv.Itoa(seconds))); err != nil {
+		logging.FromContext(ctx).WithError(err).Error("unable to set retry-after header")
+	}
+	st, err := status.New(codes.ResourceExhausted, "rate limit exceeded").
+		WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(delay)})
+	if err != nil {
+		return status.Error(codes.ResourceExhausted, "rate limit exceeded")
+	}
+	return st.Err()
+}
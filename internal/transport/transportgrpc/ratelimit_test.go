@@ -0,0 +1,118 @@
+package transportgrpc_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jacktantram/user-service/internal/transport/transportgrpc"
+	"github.com/jacktantram/user-service/internal/transport/transportgrpc/mocks"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const listUsers = "/rpc.user.v1.UserService/ListUsers"
+
+func withPeerIP(ip string) context.Context {
+	return peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: 50000}})
+}
+
+func TestRateLimiter_UnaryServerInterceptor(t *testing.T) {
+	t.Parallel()
+	limiter := transportgrpc.NewRateLimiter(transportgrpc.RateLimitConfig{
+		Default: transportgrpc.RateLimit{Rate: 1, Burst: 2},
+		Methods: map[string]transportgrpc.RateLimit{listUsers: {Rate: 0.5, Burst: 1}},
+	})
+	interceptor := limiter.UnaryServerInterceptor()
+	call := func(ctx context.Context, method string) error {
+		_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method},
+			func(ctx context.Context, req interface{}) (interface{}, error) {
+				return nil, nil
+			})
+		return err
+	}
+
+	alice := transportgrpc.ContextWithPrincipal(withPeerIP("10.0.0.1"), &transportgrpc.Principal{Subject: "alice"})
+	bob := transportgrpc.ContextWithPrincipal(withPeerIP("10.0.0.1"), &transportgrpc.Principal{Subject: "bob"})
+	getUser := "/rpc.user.v1.UserService/GetUser"
+
+	require.NoError(t, call(alice, getUser))
+	require.NoError(t, call(alice, getUser))
+	err := call(alice, getUser)
+	require.Equal(t, codes.ResourceExhausted, status.Code(err), "should throttle once the burst is used")
+	details := status.Convert(err).Details()
+	require.Len(t, details, 1)
+	retryInfo, ok := details[0].(*errdetails.RetryInfo)
+	require.True(t, ok)
+	assert.InDelta(t, time.Second, retryInfo.GetRetryDelay().AsDuration(), float64(50*time.Millisecond))
+
+	assert.NoError(t, call(bob, getUser), "should limit each subject separately")
+	assert.NoError(t, call(alice, "/grpc.health.v1.Health/Check"), "should not limit public methods")
+
+	require.NoError(t, call(alice, listUsers), "should limit each method separately")
+	err = call(alice, listUsers)
+	require.Equal(t, codes.ResourceExhausted, status.Code(err), "should apply the method limit")
+	retryInfo = status.Convert(err).Details()[0].(*errdetails.RetryInfo)
+	assert.InDelta(t, 2*time.Second, retryInfo.GetRetryDelay().AsDuration(), float64(50*time.Millisecond))
+
+	assert.Equal(t, 2, testutil.CollectAndCount(limiter.Collector()), "should count throttled calls by method")
+}
+
+func TestRateLimiter_Allow(t *testing.T) {
+	t.Parallel()
+	limiter := transportgrpc.NewRateLimiter(transportgrpc.RateLimitConfig{
+		Default: transportgrpc.RateLimit{Rate: 50},
+		Methods: map[string]transportgrpc.RateLimit{listUsers: {}},
+	})
+	getUser := "/rpc.user.v1.UserService/GetUser"
+
+	first, second := withPeerIP("10.0.0.1"), withPeerIP("10.0.0.2")
+	for i := 0; i < 50; i++ {
+		_, ok := limiter.Allow(first, getUser)
+		require.True(t, ok, "burst should default to the rate")
+	}
+	delay, ok := limiter.Allow(first, getUser)
+	require.False(t, ok)
+	assert.LessOrEqual(t, delay, 20*time.Millisecond)
+	_, ok = limiter.Allow(second, getUser)
+	assert.True(t, ok, "should limit callers without a principal by IP")
+
+	time.Sleep(delay + 5*time.Millisecond)
+	_, ok = limiter.Allow(first, getUser)
+	assert.True(t, ok, "should refill the bucket")
+
+	for i := 0; i < 100; i++ {
+		_, ok = limiter.Allow(first, listUsers)
+		require.True(t, ok, "should not limit methods with a zero rate")
+	}
+}
+
+func TestRateLimiter_RetryAfterHeader(t *testing.T) {
+	t.Parallel()
+	limiter := transportgrpc.NewRateLimiter(transportgrpc.RateLimitConfig{
+		Default:       transportgrpc.RateLimit{Rate: 0.1, Burst: 1},
+		PublicMethods: []string{},
+	})
+	server, err := transportgrpc.NewServer(grpc.NewServer(
+		grpc.ChainUnaryInterceptor(limiter.UnaryServerInterceptor())), mocks.NewMockService(gomock.NewController(t)))
+	require.NoError(t, err)
+	client := newHealthClient(t, server)
+
+	_, err = client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+
+	var header metadata.MD
+	_, err = client.Check(context.Background(), &healthpb.HealthCheckRequest{}, grpc.Header(&header))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.Equal(t, []string{"10"}, header.Get(transportgrpc.RetryAfterHeader))
+}
@@ -0,0 +1,69 @@
+package transportgrpc
+
+import (
+	"context"
+
+	"github.com/jacktantram/user-service/pkg/logging"
+	uuid "github.com/kevinburke/go.uuid"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// maxRequestIDLength bounds the request IDs accepted from callers, so they can't bloat every log line.
+const maxRequestIDLength = 128
+
+// RequestIDUnaryServerInterceptor accepts the x-request-id of a call, generating one if the caller sent none,
+// and stores a logger with the request ID and method in the context of the handler, along with the trace ID of
+// sampled calls. The ID is returned in the x-request-id response header. It should be first in the chain, after
+// the tracing interceptor, so every log line of the call carries the IDs.
+func RequestIDUnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, id := requestContext(ctx, info.FullMethod)
+		if err := grpc.SetHeader(ctx, metadata.Pairs(logging.RequestIDHeader, id)); err != nil {
+			logging.FromContext(ctx).WithError(err).Error("unable to set request id header")
+		}
+		return handler(ctx, req)
+	}
+}
+
+// RequestIDStreamServerInterceptor is the streaming equivalent of RequestIDUnaryServerInterceptor.
+func RequestIDStreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, id := requestContext(ss.Context(), info.FullMethod)
+		if err := ss.SetHeader(metadata.Pairs(logging.RequestIDHeader, id)); err != nil {
+			logging.FromContext(ctx).WithError(err).Error("unable to set request id header")
+		}
+		return handler(srv, serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+func requestContext(ctx context.Context, fullMethod string) (context.Context, string) {
+	id := incomingRequestID(ctx)
+	if id == "" {
+		id = uuid.NewV4().String()
+	}
+	entry := log.WithField("grpc_method", fullMethod)
+	// only sampled traces are exported, so the trace ID of any other call would lead nowhere
+	if sc := trace.SpanContextFromContext(ctx); sc.IsSampled() {
+		entry = entry.WithField(logging.TraceIDField, sc.TraceID().String())
+	}
+	ctx = logging.WithLogger(ctx, entry)
+	return logging.WithRequestID(ctx, id), id
+}
+
+// incomingRequestID returns the request ID sent by the caller, empty if it is missing or not printable ASCII.
+func incomingRequestID(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(logging.RequestIDHeader)
+	if len(values) == 0 || len(values[0]) > maxRequestIDLength {
+		return ""
+	}
+	for _, c := range values[0] {
+		if c < ' ' || c > '~' {
+			return ""
+		}
+	}
+	return values[0]
+}
@@ -0,0 +1,126 @@
+package transportgrpc_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jacktantram/user-service/internal/transport/transportgrpc"
+	"github.com/jacktantram/user-service/internal/transport/transportgrpc/mocks"
+	"github.com/jacktantram/user-service/pkg/logging"
+	uuid "github.com/kevinburke/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestRequestIDUnaryServerInterceptor(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name          string
+		requestID     string
+		wantRequestID string
+	}{
+		{
+			name:          "should keep the request id of the caller",
+			requestID:     "a-request-id",
+			wantRequestID: "a-request-id",
+		},
+		{
+			name: "should generate a request id if the caller sent none",
+		},
+		{
+			name:      "should replace a request id that is too long",
+			requestID: strings.Repeat("r", 129),
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var handlerCtx context.Context
+			server, err := transportgrpc.NewServer(grpc.NewServer(grpc.ChainUnaryInterceptor(
+				transportgrpc.RequestIDUnaryServerInterceptor(),
+				func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+					handlerCtx = ctx
+					return handler(ctx, req)
+				})), mocks.NewMockService(gomock.NewController(t)))
+			require.NoError(t, err)
+			client := newHealthClient(t, server)
+
+			ctx := context.Background()
+			if tt.requestID != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, logging.RequestIDHeader, tt.requestID)
+			}
+			var header metadata.MD
+			_, err = client.Check(ctx, &healthpb.HealthCheckRequest{}, grpc.Header(&header))
+			require.NoError(t, err)
+
+			requestID := logging.RequestIDFromContext(handlerCtx)
+			if tt.wantRequestID != "" {
+				assert.Equal(t, tt.wantRequestID, requestID)
+			} else {
+				assert.NotEqual(t, uuid.Nil, uuid.FromStringOrNil(requestID), "should generate a uuid")
+			}
+			assert.Equal(t, []string{requestID}, header.Get(logging.RequestIDHeader))
+			assert.Equal(t, requestID, logging.FromContext(handlerCtx).Data[logging.RequestIDField])
+			assert.Equal(t, "/grpc.health.v1.Health/Check", logging.FromContext(handlerCtx).Data["grpc_method"])
+		})
+	}
+}
+
+func TestRequestIDUnaryServerInterceptor_TraceID(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		sampler     sdktrace.Sampler
+		wantTraceID bool
+	}{
+		{
+			name:        "should log the trace id of a sampled call",
+			sampler:     sdktrace.AlwaysSample(),
+			wantTraceID: true,
+		},
+		{
+			name:    "should not log the trace id of a call that isn't exported",
+			sampler: sdktrace.NeverSample(),
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			recorder := tracetest.NewSpanRecorder()
+			provider := sdktrace.NewTracerProvider(sdktrace.WithSampler(tt.sampler), sdktrace.WithSpanProcessor(recorder))
+			var handlerCtx context.Context
+			server, err := transportgrpc.NewServer(grpc.NewServer(grpc.ChainUnaryInterceptor(
+				otelgrpc.UnaryServerInterceptor(otelgrpc.WithTracerProvider(provider)),
+				transportgrpc.RequestIDUnaryServerInterceptor(),
+				func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+					handlerCtx = ctx
+					return handler(ctx, req)
+				})), mocks.NewMockService(gomock.NewController(t)))
+			require.NoError(t, err)
+			client := newHealthClient(t, server)
+
+			_, err = client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+			require.NoError(t, err)
+
+			traceID, ok := logging.FromContext(handlerCtx).Data[logging.TraceIDField]
+			if !tt.wantTraceID {
+				assert.False(t, ok)
+				return
+			}
+			assert.Equal(t, trace.SpanContextFromContext(handlerCtx).TraceID().String(), traceID)
+			require.Len(t, recorder.Ended(), 1)
+			assert.Equal(t, "grpc.health.v1.Health/Check", recorder.Ended()[0].Name())
+		})
+	}
+}
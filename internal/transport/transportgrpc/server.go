@@ -5,11 +5,14 @@ package transportgrpc
 import (
 	"context"
 	"errors"
-	"github.com/go-playground/validator/v10"
 	userServiceV1 "github.com/jacktantram/user-service/build/go/rpc/user/v1"
 	v1 "github.com/jacktantram/user-service/build/go/shared/user/v1"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"time"
 
 	"google.golang.org/grpc/status"
 )
@@ -23,16 +26,20 @@ type Service interface {
 	CreateUser(ctx context.Context, user *v1.User) error
 	GetUser(ctx context.Context, id string) (*v1.User, error)
 	ListUsers(ctx context.Context, filters *userServiceV1.SelectUserFilters, offset uint64, limit uint64) ([]*v1.User, error)
-	UpdateUser(ctx context.Context, userToUpdate *v1.User, updateFields []v1.UpdateUserField) error
-	DeleteUser(ctx context.Context, id string) error
+	UpdateUser(ctx context.Context, userToUpdate *v1.User, updateFields []v1.UpdateUserField, etag string) error
+	DeleteUser(ctx context.Context, id string, etag string) error
 }
 
 // Server defines a GRPC server
 type Server struct {
 	userServiceV1.UnimplementedUserServiceServer
 	*grpc.Server
-	service  Service
-	validate *validator.Validate
+	service Service
+
+	health         *health.Server
+	healthCheck    HealthCheck
+	healthInterval time.Duration
+	reflection     bool
 }
 
 // NewServer Creates a new server
@@ -40,10 +47,21 @@ func NewServer(server *grpc.Server, service Service, opts ...Option) (*Server, e
 	if (server == nil) || (service == nil) {
 		return nil, errors.New("server and service must not be nil")
 	}
-	s := &Server{Server: server, service: service, validate: validator.New()}
-	userServiceV1.RegisterUserServiceServer(server, s)
+	s := &Server{Server: server, service: service, health: health.NewServer(),
+		healthInterval: defaultHealthInterval}
 	for _, opt := range opts {
 		opt(s)
 	}
+	userServiceV1.RegisterUserServiceServer(server, s)
+	healthpb.RegisterHealthServer(server, s.health)
+	if s.healthCheck != nil {
+		// not serving until the first check passes
+		s.setServingStatus(healthpb.HealthCheckResponse_NOT_SERVING)
+	} else {
+		s.setServingStatus(healthpb.HealthCheckResponse_SERVING)
+	}
+	if s.reflection {
+		reflection.Register(server)
+	}
 	return s, nil
 }
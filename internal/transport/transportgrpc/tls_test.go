@@ -0,0 +1,323 @@
+package transportgrpc_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jacktantram/user-service/internal/transport/transportgrpc"
+	"github.com/jacktantram/user-service/internal/transport/transportgrpc/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/peer"
+)
+
+const spiffeID = "spiffe://example.org/ns/billing/sa/billing"
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T) testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return testCA{cert: cert, key: key}
+}
+
+// issue returns a PEM encoded certificate and key signed by the CA.
+func (ca testCA) issue(t *testing.T, commonName string, uris ...string) (certPEM []byte, keyPEM []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	for _, u := range uris {
+		parsed, err := url.Parse(u)
+		require.NoError(t, err)
+		template.URIs = append(template.URIs, parsed)
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func (ca testCA) pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	return pool
+}
+
+func (ca testCA) pem() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw})
+}
+
+func writeFile(t *testing.T, path string, b []byte, modTime time.Time) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, b, 0o600))
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+// serveTLS serves the health service over TLS, recording the peer identity of each call.
+func serveTLS(t *testing.T, reloader *transportgrpc.CertReloader, identities chan<- string) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server, err := transportgrpc.NewServer(grpc.NewServer(
+		grpc.Creds(credentials.NewTLS(reloader.TLSConfig())),
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+			handler grpc.UnaryHandler) (interface{}, error) {
+			identity, _ := transportgrpc.PeerIdentity(ctx)
+			identities <- identity
+			return handler(ctx, req)
+		})), mocks.NewMockService(gomock.NewController(t)))
+	require.NoError(t, err)
+	go func() {
+		_ = server.Serve(lis)
+	}()
+	t.Cleanup(server.Stop)
+	return lis.Addr().String()
+}
+
+func check(ctx context.Context, addr string, cfg *tls.Config) error {
+	conn, err := grpc.DialContext(ctx, addr, grpc.WithTransportCredentials(credentials.NewTLS(cfg)))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	return err
+}
+
+func TestCertReloader_MutualTLS(t *testing.T) {
+	t.Parallel()
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	cfg := transportgrpc.TLSConfig{
+		CertFile:          filepath.Join(dir, "tls.crt"),
+		KeyFile:           filepath.Join(dir, "tls.key"),
+		ClientCAFile:      filepath.Join(dir, "ca.crt"),
+		RequireClientCert: true,
+	}
+	serverCert, serverKey := ca.issue(t, "user-service")
+	now := time.Now()
+	writeFile(t, cfg.CertFile, serverCert, now)
+	writeFile(t, cfg.KeyFile, serverKey, now)
+	writeFile(t, cfg.ClientCAFile, ca.pem(), now)
+
+	reloader, err := transportgrpc.NewCertReloader(cfg)
+	require.NoError(t, err)
+	identities := make(chan string, 1)
+	addr := serveTLS(t, reloader, identities)
+
+	clientConfig := func(t *testing.T, commonName string, uris ...string) *tls.Config {
+		certPEM, keyPEM := ca.issue(t, commonName, uris...)
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		require.NoError(t, err)
+		return &tls.Config{RootCAs: ca.pool(), ServerName: "localhost", Certificates: []tls.Certificate{cert}}
+	}
+
+	tests := []struct {
+		name         string
+		cfg          *tls.Config
+		wantIdentity string
+		wantErr      bool
+	}{
+		{
+			name:         "should identify a client by its SPIFFE ID",
+			cfg:          clientConfig(t, "billing", spiffeID),
+			wantIdentity: spiffeID,
+		},
+		{
+			name:         "should identify a client by its common name without a SPIFFE ID",
+			cfg:          clientConfig(t, "billing"),
+			wantIdentity: "billing",
+		},
+		{
+			name:    "should reject a client without a certificate",
+			cfg:     &tls.Config{RootCAs: ca.pool(), ServerName: "localhost"},
+			wantErr: true,
+		},
+		{
+			name: "should reject a client with a certificate from another CA",
+			cfg: func() *tls.Config {
+				c := clientConfig(t, "billing")
+				certPEM, keyPEM := newTestCA(t).issue(t, "billing")
+				cert, err := tls.X509KeyPair(certPEM, keyPEM)
+				require.NoError(t, err)
+				c.Certificates = []tls.Certificate{cert}
+				return c
+			}(),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			err := check(ctx, addr, tt.cfg)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantIdentity, <-identities)
+		})
+	}
+}
+
+func TestCertReloader_Reload(t *testing.T) {
+	t.Parallel()
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	cfg := transportgrpc.TLSConfig{CertFile: filepath.Join(dir, "tls.crt"), KeyFile: filepath.Join(dir, "tls.key")}
+	certPEM, keyPEM := ca.issue(t, "before")
+	modTime := time.Now().Add(-time.Minute)
+	writeFile(t, cfg.CertFile, certPEM, modTime)
+	writeFile(t, cfg.KeyFile, keyPEM, modTime)
+
+	reloader, err := transportgrpc.NewCertReloader(cfg)
+	require.NoError(t, err)
+	lis, err := tls.Listen("tcp", "127.0.0.1:0", reloader.TLSConfig())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = lis.Close() })
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.(*tls.Conn).Handshake()
+			_ = conn.Close()
+		}
+	}()
+
+	serverName := func() string {
+		conn, err := tls.Dial("tcp", lis.Addr().String(), &tls.Config{RootCAs: ca.pool(), ServerName: "localhost"})
+		require.NoError(t, err)
+		defer conn.Close()
+		return conn.ConnectionState().PeerCertificates[0].Subject.CommonName
+	}
+	assert.Equal(t, "before", serverName())
+
+	// an unchanged certificate is not reloaded
+	require.NoError(t, reloader.Reload())
+	assert.Equal(t, "before", serverName())
+
+	// an invalid certificate keeps the previous certificate
+	writeFile(t, cfg.CertFile, []byte("not a certificate"), time.Now())
+	assert.Error(t, reloader.Reload())
+	assert.Equal(t, "before", serverName())
+
+	certPEM, keyPEM = ca.issue(t, "after")
+	writeFile(t, cfg.CertFile, certPEM, time.Now())
+	writeFile(t, cfg.KeyFile, keyPEM, time.Now())
+	require.NoError(t, reloader.Reload())
+	assert.Equal(t, "after", serverName())
+}
+
+func TestCertReloader_Watch(t *testing.T) {
+	t.Parallel()
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	cfg := transportgrpc.TLSConfig{CertFile: filepath.Join(dir, "tls.crt"), KeyFile: filepath.Join(dir, "tls.key")}
+	certPEM, keyPEM := ca.issue(t, "localhost")
+	writeFile(t, cfg.CertFile, certPEM, time.Now())
+	writeFile(t, cfg.KeyFile, keyPEM, time.Now())
+	reloader, err := transportgrpc.NewCertReloader(cfg)
+	require.NoError(t, err)
+
+	t.Run("should reload until the context is done", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		reloader.Watch(ctx, time.Millisecond)
+	})
+
+	t.Run("should not reload with a non-positive interval", func(t *testing.T) {
+		t.Parallel()
+		reloader.Watch(context.Background(), 0)
+		reloader.Watch(context.Background(), -time.Minute)
+	})
+}
+
+func TestNewCertReloader_Error(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		cfg  transportgrpc.TLSConfig
+	}{
+		{name: "missing cert", cfg: transportgrpc.TLSConfig{KeyFile: "tls.key"}},
+		{name: "client certs required without a CA", cfg: transportgrpc.TLSConfig{CertFile: "tls.crt", KeyFile: "tls.key",
+			RequireClientCert: true}},
+		{name: "missing files", cfg: transportgrpc.TLSConfig{CertFile: "missing.crt", KeyFile: "missing.key"}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := transportgrpc.NewCertReloader(tt.cfg)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestAuthenticator_ClientCertificates(t *testing.T) {
+	t.Parallel()
+	spiffeURL, err := url.Parse(spiffeID)
+	require.NoError(t, err)
+	ctx := peer.NewContext(context.Background(), &peer.Peer{AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{
+		VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: "billing"}, URIs: []*url.URL{spiffeURL}}}},
+	}}})
+
+	principal, err := transportgrpc.NewAuthenticator(nil, transportgrpc.AuthConfig{ClientCertificates: true}).Authenticate(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, &transportgrpc.Principal{Subject: spiffeID}, principal)
+
+	_, err = transportgrpc.NewAuthenticator(nil, transportgrpc.AuthConfig{}).Authenticate(ctx)
+	assert.Error(t, err, "client certificates should only be accepted when enabled")
+}
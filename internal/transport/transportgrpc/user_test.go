@@ -12,6 +12,7 @@ import (
 	uuid "github.com/kevinburke/go.uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -20,6 +21,31 @@ import (
 	"time"
 )
 
+const notFoundID = "0b3bd4b4-4b43-4a1a-a8ae-8ee1a3a5d7f5"
+
+// userNotFoundErr returns the error of a user that does not exist.
+func userNotFoundErr(t *testing.T, msg string, id string) error {
+	t.Helper()
+	st, err := status.New(codes.NotFound, msg).WithDetails(&errdetails.ErrorInfo{
+		Reason:   transportgrpc.ReasonUserNotFound,
+		Domain:   "user-service",
+		Metadata: map[string]string{"id": id},
+	})
+	require.NoError(t, err)
+	return st.Err()
+}
+
+func etagMismatchErr(t *testing.T, id string) error {
+	t.Helper()
+	st, err := status.New(codes.Aborted, "user has changed since the etag was read").WithDetails(&errdetails.ErrorInfo{
+		Reason:   transportgrpc.ReasonEtagMismatch,
+		Domain:   "user-service",
+		Metadata: map[string]string{"id": id},
+	})
+	require.NoError(t, err)
+	return st.Err()
+}
+
 func TestServer_CreateUser_Success(t *testing.T) {
 	t.Parallel()
 
@@ -99,151 +125,8 @@ func TestServer_CreateUser_Error(t *testing.T) {
 		args            args
 		wantErrContains string
 		wantStatusCode  codes.Code
+		wantReason      string
 	}{
-		{
-			name: "should return error unable to create a user due to invalid email",
-			args: args{
-				request: &userServiceV1.CreateUserRequest{User: &v1.User{
-					FirstName: "A name",
-					LastName:  "Some field",
-					Nickname:  "Nickanme",
-					Password:  "a-password",
-					Email:     "anemailgopher.com",
-					Country:   "DEU",
-					UpdatedAt: nil,
-				}}},
-			setup:           nil,
-			wantErrContains: "Field validation for 'Email' failed",
-			wantStatusCode:  codes.InvalidArgument,
-		},
-		{
-			name: "should return error unable to create a user due to missing email",
-			args: args{
-				request: &userServiceV1.CreateUserRequest{User: &v1.User{
-					FirstName: "A name",
-					LastName:  "Some field",
-					Nickname:  "Nickanme",
-					Password:  "a-password",
-					Email:     "",
-					Country:   "DEU",
-					UpdatedAt: nil,
-				}}},
-			setup:           nil,
-			wantErrContains: "Field validation for 'Email' failed",
-			wantStatusCode:  codes.InvalidArgument,
-		},
-		{
-			name: "should return error unable to create a user due to invalid country code",
-			args: args{
-				request: &userServiceV1.CreateUserRequest{User: &v1.User{
-					FirstName: "A name",
-					LastName:  "Some field",
-					Nickname:  "Nickanme",
-					Password:  "a-password",
-					Email:     "anemail@gopher.com",
-					Country:   "DE",
-					UpdatedAt: nil,
-				}}},
-			setup:           nil,
-			wantErrContains: "Field validation for 'Country' failed",
-			wantStatusCode:  codes.InvalidArgument,
-		},
-		{
-			name: "should return error unable to create a user due to missing country",
-			args: args{
-				request: &userServiceV1.CreateUserRequest{User: &v1.User{
-					FirstName: "A name",
-					LastName:  "Some field",
-					Nickname:  "Nickanme",
-					Password:  "a-password",
-					Email:     "a@email.com",
-					Country:   "",
-					UpdatedAt: nil,
-				}}},
-			setup:           nil,
-			wantErrContains: "Field validation for 'Country' failed",
-			wantStatusCode:  codes.InvalidArgument,
-		},
-		{
-			name: "should return error unable to create a user due to invalid country code",
-			args: args{
-				request: &userServiceV1.CreateUserRequest{User: &v1.User{
-					FirstName: "A name",
-					LastName:  "Some field",
-					Nickname:  "Nickanme",
-					Password:  "a-password",
-					Email:     "anemail@gopher.com",
-					Country:   "DEUU",
-					UpdatedAt: nil,
-				}}},
-			setup:           nil,
-			wantErrContains: "Field validation for 'Country' failed",
-			wantStatusCode:  codes.InvalidArgument,
-		},
-		{
-			name: "should return error unable to create a user due to missing first name",
-			args: args{
-				request: &userServiceV1.CreateUserRequest{User: &v1.User{
-					FirstName: "",
-					LastName:  "Some field",
-					Nickname:  "Nickanme",
-					Password:  "a-password",
-					Email:     "anemail@gopher.com",
-					Country:   "DEU",
-					UpdatedAt: nil,
-				}}},
-			setup:           nil,
-			wantErrContains: "Field validation for 'FirstName' failed",
-			wantStatusCode:  codes.InvalidArgument,
-		},
-		{
-			name: "should return error unable to create a user due to missing last name",
-			args: args{
-				request: &userServiceV1.CreateUserRequest{User: &v1.User{
-					FirstName: "Sopme",
-					LastName:  "",
-					Nickname:  "Nickanme",
-					Password:  "a-password",
-					Email:     "anemail@gopher.com",
-					Country:   "DEU",
-					UpdatedAt: nil,
-				}}},
-			setup:           nil,
-			wantErrContains: "Field validation for 'LastName' failed",
-			wantStatusCode:  codes.InvalidArgument,
-		},
-		{
-			name: "should return error unable to create a user due to missing nickname",
-			args: args{
-				request: &userServiceV1.CreateUserRequest{User: &v1.User{
-					FirstName: "Sopme",
-					LastName:  "asdasd",
-					Nickname:  "",
-					Password:  "a-password",
-					Email:     "anemail@gopher.com",
-					Country:   "DEU",
-					UpdatedAt: nil,
-				}}},
-			setup:           nil,
-			wantErrContains: "Field validation for 'Nickname' failed",
-			wantStatusCode:  codes.InvalidArgument,
-		},
-		{
-			name: "should return error unable to create a user due to missing password",
-			args: args{
-				request: &userServiceV1.CreateUserRequest{User: &v1.User{
-					FirstName: "Sopme",
-					LastName:  "asdasd",
-					Nickname:  "asdas",
-					Password:  "",
-					Email:     "anemail@gopher.com",
-					Country:   "DEU",
-					UpdatedAt: nil,
-				}}},
-			setup:           nil,
-			wantErrContains: "Field validation for 'Password' failed",
-			wantStatusCode:  codes.InvalidArgument,
-		},
 		{
 			name: "should return error unable to create a user due service returning error",
 			args: args{
@@ -283,6 +166,7 @@ func TestServer_CreateUser_Error(t *testing.T) {
 			},
 			wantErrContains: "user already exists with this email",
 			wantStatusCode:  codes.AlreadyExists,
+			wantReason:      transportgrpc.ReasonEmailAlreadyExists,
 		},
 	}
 
@@ -304,6 +188,13 @@ func TestServer_CreateUser_Error(t *testing.T) {
 			require.Error(t, err)
 			assert.Contains(t, err.Error(), tt.wantErrContains)
 			assert.Equal(t, tt.wantStatusCode, status.Convert(err).Code())
+			for _, detail := range status.Convert(err).Details() {
+				if info, ok := detail.(*errdetails.ErrorInfo); ok {
+					assert.Equal(t, tt.wantReason, info.GetReason())
+					tt.wantReason = ""
+				}
+			}
+			assert.Empty(t, tt.wantReason, "should return the error reason")
 
 		})
 	}
@@ -372,28 +263,16 @@ func TestServer_GetUser_Error(t *testing.T) {
 		args    args
 		wantErr error
 	}{
-		{
-			name:    "should throw error if id is missing",
-			args:    args{request: &userServiceV1.GetUserRequest{Id: ""}},
-			setup:   nil,
-			wantErr: status.Error(codes.InvalidArgument, "user id must be provided"),
-		},
-		{
-			name:    "should throw error if id is not a uuid",
-			args:    args{request: &userServiceV1.GetUserRequest{Id: "some-val"}},
-			setup:   nil,
-			wantErr: status.Error(codes.InvalidArgument, "user id must be in the UUID format"),
-		},
 		{
 			name: "should return NotFound error if service returns domain.ErrNoUser",
-			args: args{request: &userServiceV1.GetUserRequest{Id: uuid.NewV4().String()}},
+			args: args{request: &userServiceV1.GetUserRequest{Id: notFoundID}},
 			setup: func(mockService *mocks.MockService, args args) {
 				mockService.
 					EXPECT().
 					GetUser(gomock.Any(), gomock.Any()).
 					Return(nil, domain.ErrNoUser)
 			},
-			wantErr: status.New(codes.NotFound, "user is not found").Err(),
+			wantErr: userNotFoundErr(t, "user is not found", notFoundID),
 		},
 		{
 			name: "should return internal error if service returns error",
@@ -612,8 +491,8 @@ func TestServer_UpdateUser_Success(t *testing.T) {
 				want *userServiceV1.UpdateUserResponse) {
 				mockService.
 					EXPECT().
-					UpdateUser(gomock.Any(), gomock.Eq(args.request.User), gomock.Eq(args.request.UpdateFields)).
-					DoAndReturn(func(ctx context.Context, user *v1.User, field []v1.UpdateUserField) error {
+					UpdateUser(gomock.Any(), gomock.Eq(args.request.User), gomock.Eq(args.request.UpdateFields), "").
+					DoAndReturn(func(ctx context.Context, user *v1.User, field []v1.UpdateUserField, etag string) error {
 						args.request.User = want.User
 						return nil
 					})
@@ -664,66 +543,6 @@ func TestServer_UpdateUser_Error(t *testing.T) {
 		args    args
 		wantErr error
 	}{
-		{
-			name:    "should return error when user is not provided",
-			args:    args{request: &userServiceV1.UpdateUserRequest{User: nil, UpdateFields: []v1.UpdateUserField{v1.UpdateUserField_UPDATE_USER_FIELD_FIRST_NAME}}},
-			setup:   nil,
-			wantErr: status.New(codes.InvalidArgument, "user must be provided").Err(),
-		},
-		{
-			name:    "should return error when user id is not provided",
-			args:    args{request: &userServiceV1.UpdateUserRequest{User: &v1.User{Id: ""}, UpdateFields: []v1.UpdateUserField{v1.UpdateUserField_UPDATE_USER_FIELD_FIRST_NAME}}},
-			setup:   nil,
-			wantErr: status.New(codes.InvalidArgument, "user id must be provided").Err(),
-		},
-		{
-			name: "should return error when update fields are not provided",
-			args: args{request: &userServiceV1.UpdateUserRequest{User: &v1.User{
-				Id:        "a-user",
-				FirstName: "Max",
-				LastName:  "Some field",
-				Nickname:  "Nickanme",
-				Password:  "a-password",
-				Email:     "anemail@gopher.com",
-				Country:   "DEU",
-				CreatedAt: timestamppb.New(time.Date(2021, 12, 12, 1, 0, 0, 0, time.UTC).UTC()),
-				UpdatedAt: nil,
-			}, UpdateFields: nil}},
-			setup:   nil,
-			wantErr: status.New(codes.InvalidArgument, "at least one update field must be provided").Err(),
-		},
-		{
-			name: "should return error when update fields only unspecified",
-			args: args{request: &userServiceV1.UpdateUserRequest{User: &v1.User{
-				Id:        "a-user",
-				FirstName: "Max",
-				LastName:  "Some field",
-				Nickname:  "Nickanme",
-				Password:  "a-password",
-				Email:     "anemail@gopher.com",
-				Country:   "DEU",
-				CreatedAt: timestamppb.New(time.Date(2021, 12, 12, 1, 0, 0, 0, time.UTC).UTC()),
-				UpdatedAt: nil,
-			}, UpdateFields: []v1.UpdateUserField{v1.UpdateUserField_UPDATE_USER_FIELD_UNSPECIFIED}}},
-			setup:   nil,
-			wantErr: status.New(codes.InvalidArgument, "at least one update field must be provided and not be unspecified value").Err(),
-		},
-		{
-			name: "should return error when update fields are provided twice",
-			args: args{request: &userServiceV1.UpdateUserRequest{User: &v1.User{
-				Id:        "a-user",
-				FirstName: "Max",
-				LastName:  "Some field",
-				Nickname:  "Nickanme",
-				Password:  "a-password",
-				Email:     "anemail@gopher.com",
-				Country:   "DEU",
-				CreatedAt: timestamppb.New(time.Date(2021, 12, 12, 1, 0, 0, 0, time.UTC).UTC()),
-				UpdatedAt: nil,
-			}, UpdateFields: []v1.UpdateUserField{v1.UpdateUserField_UPDATE_USER_FIELD_FIRST_NAME, v1.UpdateUserField_UPDATE_USER_FIELD_FIRST_NAME}}},
-			setup:   nil,
-			wantErr: status.New(codes.InvalidArgument, "should only input unique update fields").Err(),
-		},
 		{
 			name: "should return error when something went wrong updating",
 			args: args{request: &userServiceV1.UpdateUserRequest{User: &v1.User{
@@ -740,7 +559,7 @@ func TestServer_UpdateUser_Error(t *testing.T) {
 			setup: func(mockService *mocks.MockService, args args) {
 				mockService.
 					EXPECT().
-					UpdateUser(gomock.Any(), gomock.Eq(args.request.User), gomock.Eq(args.request.UpdateFields)).
+					UpdateUser(gomock.Any(), gomock.Eq(args.request.User), gomock.Eq(args.request.UpdateFields), "").
 					Return(errors.New("something went wrong"))
 			},
 			wantErr: status.New(codes.Internal, "oops something went wrong!").Err(),
@@ -761,10 +580,22 @@ func TestServer_UpdateUser_Error(t *testing.T) {
 			setup: func(mockService *mocks.MockService, args args) {
 				mockService.
 					EXPECT().
-					UpdateUser(gomock.Any(), gomock.Any(), gomock.Any()).
+					UpdateUser(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
 					Return(domain.ErrNoUser)
 			},
-			wantErr: status.New(codes.NotFound, domain.ErrNoUser.Error()).Err(),
+			wantErr: userNotFoundErr(t, domain.ErrNoUser.Error(), "a-user"),
+		},
+		{
+			name: "should return Aborted when the user has changed since the etag",
+			args: args{request: &userServiceV1.UpdateUserRequest{User: &v1.User{Id: "a-user", FirstName: "Max"},
+				UpdateFields: []v1.UpdateUserField{v1.UpdateUserField_UPDATE_USER_FIELD_FIRST_NAME}, Etag: "1"}},
+			setup: func(mockService *mocks.MockService, args args) {
+				mockService.
+					EXPECT().
+					UpdateUser(gomock.Any(), gomock.Any(), gomock.Any(), "1").
+					Return(domain.ErrUserEtagMismatch)
+			},
+			wantErr: etagMismatchErr(t, "a-user"),
 		},
 	}
 
@@ -805,7 +636,7 @@ func TestServer_DeleteUser_Success(t *testing.T) {
 			name: "should be able to successfully get the user",
 			args: args{request: &userServiceV1.DeleteUserRequest{Id: "a8bdce5a-31dc-4647-98b5-ce9cb343138f"}},
 			setup: func(mockService *mocks.MockService, args args) {
-				mockService.EXPECT().DeleteUser(gomock.Any(), gomock.Eq(args.request.Id)).Return(nil)
+				mockService.EXPECT().DeleteUser(gomock.Any(), gomock.Eq(args.request.Id), "").Return(nil)
 			},
 			want: &userServiceV1.DeleteUserResponse{},
 		}}
@@ -841,28 +672,16 @@ func TestServer_DeleteUser_Error(t *testing.T) {
 		args    args
 		wantErr error
 	}{
-		{
-			name:    "should throw error if id is missing",
-			args:    args{request: &userServiceV1.DeleteUserRequest{Id: ""}},
-			setup:   nil,
-			wantErr: status.Error(codes.InvalidArgument, "user id must be provided"),
-		},
-		{
-			name:    "should throw error if id is not a uuid",
-			args:    args{request: &userServiceV1.DeleteUserRequest{Id: "some-val"}},
-			setup:   nil,
-			wantErr: status.Error(codes.InvalidArgument, "user id must be in the UUID format"),
-		},
 		{
 			name: "should return NotFound error if service returns domain.ErrNoUser",
-			args: args{request: &userServiceV1.DeleteUserRequest{Id: uuid.NewV4().String()}},
+			args: args{request: &userServiceV1.DeleteUserRequest{Id: notFoundID}},
 			setup: func(mockService *mocks.MockService, args args) {
 				mockService.
 					EXPECT().
-					DeleteUser(gomock.Any(), gomock.Any()).
+					DeleteUser(gomock.Any(), gomock.Any(), gomock.Any()).
 					Return(domain.ErrNoUser)
 			},
-			wantErr: status.New(codes.NotFound, "user is not found").Err(),
+			wantErr: userNotFoundErr(t, "user is not found", notFoundID),
 		},
 		{
 			name: "should return internal error if service returns error",
@@ -870,11 +689,22 @@ func TestServer_DeleteUser_Error(t *testing.T) {
 			setup: func(mockService *mocks.MockService, args args) {
 				mockService.
 					EXPECT().
-					DeleteUser(gomock.Any(), gomock.Any()).
+					DeleteUser(gomock.Any(), gomock.Any(), gomock.Any()).
 					Return(errors.New("some error"))
 			},
 			wantErr: status.New(codes.Internal, "oops something went wrong!").Err(),
 		},
+		{
+			name: "should return Aborted when the user has changed since the etag",
+			args: args{request: &userServiceV1.DeleteUserRequest{Id: notFoundID, Etag: "1"}},
+			setup: func(mockService *mocks.MockService, args args) {
+				mockService.
+					EXPECT().
+					DeleteUser(gomock.Any(), notFoundID, "1").
+					Return(domain.ErrUserEtagMismatch)
+			},
+			wantErr: etagMismatchErr(t, notFoundID),
+		},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,81 @@
+package transportgrpc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/bufbuild/protovalidate-go"
+	userServiceV1 "github.com/jacktantram/user-service/build/go/rpc/user/v1"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+// Validator validates requests against the buf.validate constraints declared on their messages.
+type Validator struct {
+	validator *protovalidate.Validator
+}
+
+// NewValidator creates a validator, compiling the constraints of the UserService requests up front.
+func NewValidator() (*Validator, error) {
+	validator, err := protovalidate.New(protovalidate.WithMessages(
+		&userServiceV1.GetUserRequest{},
+		&userServiceV1.ListUsersRequest{},
+		&userServiceV1.CreateUserRequest{},
+		&userServiceV1.UpdateUserRequest{},
+		&userServiceV1.DeleteUserRequest{},
+	))
+	if err != nil {
+		return nil, err
+	}
+	return &Validator{validator: validator}, nil
+}
+
+// Validate returns InvalidArgument with a BadRequest field violation for each constraint req violates.
+// Requests that are not proto messages are not validated.
+func (v *Validator) Validate(req interface{}) error {
+	msg, ok := req.(proto.Message)
+	if !ok {
+		return nil
+	}
+	err := v.validator.Validate(msg)
+	if err == nil {
+		return nil
+	}
+	var validationErr *protovalidate.ValidationError
+	if errors.As(err, &validationErr) {
+		return validationError(validationErr)
+	}
+	log.WithError(err).WithField("message", msg.ProtoReflect().Descriptor().FullName()).Error("unable to validate request")
+	return errSomethingWentWrong
+}
+
+// UnaryServerInterceptor rejects requests violating their constraints with InvalidArgument.
+func (v *Validator) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := v.Validate(req); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor rejects each received message violating its constraints with InvalidArgument.
+func (v *Validator) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, validatingStream{ServerStream: ss, validator: v})
+	}
+}
+
+// validatingStream validates the messages received on a stream.
+type validatingStream struct {
+	grpc.ServerStream
+	validator *Validator
+}
+
+func (s validatingStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return s.validator.Validate(m)
+}
@@ -0,0 +1,180 @@
+package transportgrpc_test
+
+import (
+	"context"
+	"testing"
+
+	userServiceV1 "github.com/jacktantram/user-service/build/go/rpc/user/v1"
+	v1 "github.com/jacktantram/user-service/build/go/shared/user/v1"
+	"github.com/jacktantram/user-service/internal/transport/transportgrpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func validUser() *v1.User {
+	return &v1.User{
+		FirstName: "John",
+		LastName:  "Gopher",
+		Nickname:  "Goopher",
+		Password:  "A-password",
+		Email:     "jon@gopher.com",
+		Country:   "GBR",
+	}
+}
+
+func TestValidator_UnaryServerInterceptor(t *testing.T) {
+	t.Parallel()
+	withUser := func(fn func(u *v1.User)) *v1.User {
+		u := validUser()
+		fn(u)
+		return u
+	}
+	firstName := []v1.UpdateUserField{v1.UpdateUserField_UPDATE_USER_FIELD_FIRST_NAME}
+
+	tests := []struct {
+		name       string
+		req        interface{}
+		wantFields []string
+	}{
+		{
+			name: "should allow a valid create",
+			req:  &userServiceV1.CreateUserRequest{User: validUser()},
+		},
+		{
+			name:       "should reject a create without a user",
+			req:        &userServiceV1.CreateUserRequest{},
+			wantFields: []string{"user"},
+		},
+		{
+			name:       "should reject a create with an invalid email",
+			req:        &userServiceV1.CreateUserRequest{User: withUser(func(u *v1.User) { u.Email = "anemailgopher.com" })},
+			wantFields: []string{"user.email"},
+		},
+		{
+			name:       "should reject a create with a country that isn't 3 characters",
+			req:        &userServiceV1.CreateUserRequest{User: withUser(func(u *v1.User) { u.Country = "DE" })},
+			wantFields: []string{"user.country"},
+		},
+		{
+			name: "should report every missing field of a create",
+			req:  &userServiceV1.CreateUserRequest{User: &v1.User{}},
+			wantFields: []string{"user.first_name", "user.last_name", "user.nickname", "user.password", "user.email",
+				"user.country"},
+		},
+		{
+			name:       "should reject a create with an id that isn't a uuid",
+			req:        &userServiceV1.CreateUserRequest{User: withUser(func(u *v1.User) { u.Id = "some-val" })},
+			wantFields: []string{"user.id"},
+		},
+		{
+			name: "should allow a valid get",
+			req:  &userServiceV1.GetUserRequest{Id: "a8bdce5a-31dc-4647-98b5-ce9cb343138f"},
+		},
+		{
+			name:       "should reject a get without an id",
+			req:        &userServiceV1.GetUserRequest{},
+			wantFields: []string{"id"},
+		},
+		{
+			name:       "should reject a get with an id that isn't a uuid",
+			req:        &userServiceV1.GetUserRequest{Id: "some-val"},
+			wantFields: []string{"id"},
+		},
+		{
+			name: "should allow a valid list",
+			req: &userServiceV1.ListUsersRequest{Limit: 100,
+				Filters: &userServiceV1.SelectUserFilters{Countries: []string{"GBR", "DEU"}}},
+		},
+		{
+			name:       "should reject a list with a limit that is too high",
+			req:        &userServiceV1.ListUsersRequest{Limit: 101},
+			wantFields: []string{"limit"},
+		},
+		{
+			name:       "should reject a list filtering by an invalid country",
+			req:        &userServiceV1.ListUsersRequest{Filters: &userServiceV1.SelectUserFilters{Countries: []string{"GBR", "DE"}}},
+			wantFields: []string{"filters.countries[1]"},
+		},
+		{
+			name: "should allow a valid update",
+			req: &userServiceV1.UpdateUserRequest{UpdateFields: firstName,
+				User: withUser(func(u *v1.User) { u.Id = "a8bdce5a-31dc-4647-98b5-ce9cb343138f" })},
+		},
+		{
+			name:       "should reject an update without a user id",
+			req:        &userServiceV1.UpdateUserRequest{User: validUser(), UpdateFields: firstName},
+			wantFields: []string{""},
+		},
+		{
+			name: "should reject an update without update fields",
+			req: &userServiceV1.UpdateUserRequest{
+				User: withUser(func(u *v1.User) { u.Id = "a8bdce5a-31dc-4647-98b5-ce9cb343138f" })},
+			wantFields: []string{"update_fields"},
+		},
+		{
+			name: "should reject an update with unspecified or repeated update fields",
+			req: &userServiceV1.UpdateUserRequest{
+				User: withUser(func(u *v1.User) { u.Id = "a8bdce5a-31dc-4647-98b5-ce9cb343138f" }),
+				UpdateFields: []v1.UpdateUserField{v1.UpdateUserField_UPDATE_USER_FIELD_UNSPECIFIED,
+					v1.UpdateUserField_UPDATE_USER_FIELD_FIRST_NAME, v1.UpdateUserField_UPDATE_USER_FIELD_FIRST_NAME}},
+			wantFields: []string{"update_fields", "update_fields[0]"},
+		},
+		{
+			name:       "should reject a delete with an id that isn't a uuid",
+			req:        &userServiceV1.DeleteUserRequest{Id: "some-val"},
+			wantFields: []string{"id"},
+		},
+		{
+			name: "should not validate requests that aren't proto messages",
+			req:  struct{}{},
+		},
+	}
+
+	validator, err := transportgrpc.NewValidator()
+	require.NoError(t, err)
+	interceptor := validator.UnaryServerInterceptor()
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var called bool
+			_, err := interceptor(context.Background(), tt.req, &grpc.UnaryServerInfo{},
+				func(ctx context.Context, req interface{}) (interface{}, error) {
+					called = true
+					return nil, nil
+				})
+			if len(tt.wantFields) == 0 {
+				require.NoError(t, err)
+				assert.True(t, called)
+				return
+			}
+			assert.False(t, called)
+			st := status.Convert(err)
+			require.Equal(t, codes.InvalidArgument, st.Code())
+			require.Len(t, st.Details(), 1)
+			badRequest, ok := st.Details()[0].(*errdetails.BadRequest)
+			require.True(t, ok)
+			fields := make([]string, 0, len(badRequest.GetFieldViolations()))
+			for _, violation := range badRequest.GetFieldViolations() {
+				fields = append(fields, violation.GetField())
+				assert.NotEmpty(t, violation.GetDescription())
+			}
+			assert.ElementsMatch(t, tt.wantFields, fields)
+		})
+	}
+}
+
+func TestValidator_Message(t *testing.T) {
+	t.Parallel()
+	validator, err := transportgrpc.NewValidator()
+	require.NoError(t, err)
+
+	user := validUser()
+	user.Email = "anemailgopher.com"
+	err = validator.Validate(&userServiceV1.CreateUserRequest{User: user})
+	assert.EqualError(t, err, "rpc error: code = InvalidArgument desc = invalid request: user.email: value must be a valid email address")
+}
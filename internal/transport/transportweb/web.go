@@ -0,0 +1,211 @@
+package transportweb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"connectrpc.com/connect"
+	userServiceV1 "github.com/jacktantram/user-service/build/go/rpc/user/v1"
+	"github.com/jacktantram/user-service/build/go/rpc/user/v1/v1connect"
+	"github.com/jacktantram/user-service/internal/transport/transportgrpc"
+	"github.com/rs/cors"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// healthCheckProcedure is the unary method of the grpc health service served to browsers.
+const healthCheckProcedure = "/grpc.health.v1.Health/Check"
+
+var (
+	// requestHeaders are the headers browsers need to send for the Connect and gRPC-Web protocols.
+	requestHeaders = []string{
+		"Content-Type", "Connect-Protocol-Version", "Connect-Timeout-Ms", "Grpc-Timeout",
+		"X-Grpc-Web", "X-User-Agent", "Authorization",
+	}
+	// responseHeaders are the headers browsers need to read for the Connect and gRPC-Web protocols.
+	responseHeaders = []string{"Grpc-Status", "Grpc-Message", "Grpc-Status-Details-Bin"}
+)
+
+// CORSConfig configures which browser origins may call the service.
+type CORSConfig struct {
+	// AllowedOrigins lists the origins allowed to make requests, "*" allows any origin. Cross-origin requests
+	// are denied when it is empty.
+	AllowedOrigins []string
+	// AllowedHeaders lists request headers allowed in addition to those required by the protocols.
+	AllowedHeaders []string
+	// ExposedHeaders lists response headers exposed in addition to those required by the protocols.
+	ExposedHeaders []string
+	// AllowCredentials allows requests to include cookies and authorization headers.
+	AllowCredentials bool
+	// MaxAge is how long the result of a preflight request can be cached.
+	MaxAge time.Duration
+}
+
+// NewHandler creates a handler serving UserService and the health check over the Connect, gRPC-Web and gRPC
+// protocols with connect-go, so browsers can call them directly. Requests are forwarded to the gRPC server at
+// endpoint, so they pass through the same interceptors as native gRPC calls.
+//
+// Only unary methods are supported.
+func NewHandler(ctx context.Context, endpoint string, cfg CORSConfig, opts ...grpc.DialOption) (http.Handler, error) {
+	conn, err := grpc.DialContext(ctx, endpoint, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to dial %s: %w", endpoint, err)
+	}
+	go func() {
+		<-ctx.Done()
+		_ = conn.Close()
+	}()
+
+	mux := http.NewServeMux()
+	mux.Handle(v1connect.NewUserServiceHandler(userService{client: userServiceV1.NewUserServiceClient(conn)}))
+	mux.Handle(healthCheckProcedure, connect.NewUnaryHandler(healthCheckProcedure,
+		forward(healthpb.NewHealthClient(conn).Check)))
+
+	opt := cors.Options{
+		AllowedOrigins:   cfg.AllowedOrigins,
+		AllowedMethods:   []string{http.MethodPost},
+		AllowedHeaders:   append(append([]string{}, requestHeaders...), cfg.AllowedHeaders...),
+		ExposedHeaders:   append(append([]string{}, responseHeaders...), cfg.ExposedHeaders...),
+		AllowCredentials: cfg.AllowCredentials,
+		MaxAge:           int(cfg.MaxAge.Seconds()),
+	}
+	if len(cfg.AllowedOrigins) == 0 {
+		// cors allows any origin when none are configured.
+		opt.AllowOriginFunc = func(string) bool { return false }
+	}
+	return cors.New(opt).Handler(mux), nil
+}
+
+// Patterns returns the http.ServeMux patterns the handler should be registered on.
+func Patterns() []string {
+	return []string{"/" + v1connect.UserServiceName + "/", healthCheckProcedure}
+}
+
+// userService implements UserService by forwarding each call to the gRPC server.
+type userService struct {
+	client userServiceV1.UserServiceClient
+}
+
+func (s userService) GetUser(ctx context.Context,
+	req *connect.Request[userServiceV1.GetUserRequest]) (*connect.Response[userServiceV1.GetUserResponse], error) {
+	return forward(s.client.GetUser)(ctx, req)
+}
+
+func (s userService) ListUsers(ctx context.Context,
+	req *connect.Request[userServiceV1.ListUsersRequest]) (*connect.Response[userServiceV1.ListUsersResponse], error) {
+	return forward(s.client.ListUsers)(ctx, req)
+}
+
+func (s userService) CreateUser(ctx context.Context,
+	req *connect.Request[userServiceV1.CreateUserRequest]) (*connect.Response[userServiceV1.CreateUserResponse], error) {
+	return forward(s.client.CreateUser)(ctx, req)
+}
+
+func (s userService) UpdateUser(ctx context.Context,
+	req *connect.Request[userServiceV1.UpdateUserRequest]) (*connect.Response[userServiceV1.UpdateUserResponse], error) {
+	return forward(s.client.UpdateUser)(ctx, req)
+}
+
+func (s userService) DeleteUser(ctx context.Context,
+	req *connect.Request[userServiceV1.DeleteUserRequest]) (*connect.Response[userServiceV1.DeleteUserResponse], error) {
+	return forward(s.client.DeleteUser)(ctx, req)
+}
+
+// forward adapts a unary gRPC client method into a connect handler. Request headers are sent as metadata with
+// the caller's address appended to x-forwarded-for, as grpc-gateway does, and the response metadata and errors are
+// translated back.
+func forward[Req, Res any](call func(context.Context, *Req, ...grpc.CallOption) (*Res, error),
+) func(context.Context, *connect.Request[Req]) (*connect.Response[Res], error) {
+	return func(ctx context.Context, req *connect.Request[Req]) (*connect.Response[Res], error) {
+		ctx = metadata.NewOutgoingContext(ctx, requestMetadata(req.Header(), req.Peer().Addr))
+		var header, trailer metadata.MD
+		res, err := call(ctx, req.Msg, grpc.Header(&header), grpc.Trailer(&trailer))
+		if err != nil {
+			connectErr := connectError(err)
+			copyMetadata(connectErr.Meta(), header)
+			copyMetadata(connectErr.Meta(), trailer)
+			return nil, connectErr
+		}
+		resp := connect.NewResponse(res)
+		copyMetadata(resp.Header(), header)
+		copyMetadata(resp.Trailer(), trailer)
+		return resp, nil
+	}
+}
+
+// requestMetadata returns the headers of a request as metadata, other than those used by the protocols.
+func requestMetadata(h http.Header, addr string) metadata.MD {
+	md := metadata.MD{}
+	for k, values := range h {
+		k = strings.ToLower(k)
+		if isProtocolHeader(k) {
+			continue
+		}
+		for _, v := range values {
+			if strings.HasSuffix(k, "-bin") {
+				b, err := connect.DecodeBinaryHeader(v)
+				if err != nil {
+					continue
+				}
+				v = string(b)
+			}
+			md.Append(k, v)
+		}
+	}
+	md.Delete(transportgrpc.ForwardedForHeader)
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		if fwd := h.Get("X-Forwarded-For"); fwd != "" {
+			host = fwd + ", " + host
+		}
+		md.Set(transportgrpc.ForwardedForHeader, host)
+	}
+	return md
+}
+
+// copyMetadata adds gRPC metadata to HTTP headers or trailers.
+func copyMetadata(h http.Header, md metadata.MD) {
+	for k, values := range md {
+		if isProtocolHeader(k) {
+			continue
+		}
+		for _, v := range values {
+			if strings.HasSuffix(k, "-bin") {
+				v = connect.EncodeBinaryHeader([]byte(v))
+			}
+			h.Add(k, v)
+		}
+	}
+}
+
+// connectError converts a gRPC status error, its codes are the same as connect's.
+func connectError(err error) *connect.Error {
+	st := status.Convert(err)
+	connectErr := connect.NewError(connect.Code(st.Code()), errors.New(st.Message()))
+	for _, d := range st.Proto().GetDetails() {
+		msg, err := d.UnmarshalNew()
+		if err != nil {
+			continue
+		}
+		if detail, err := connect.NewErrorDetail(msg); err == nil {
+			connectErr.AddDetail(detail)
+		}
+	}
+	return connectErr
+}
+
+func isProtocolHeader(k string) bool {
+	k = strings.ToLower(k)
+	switch k {
+	case "content-type", "content-length", "content-encoding", "accept-encoding", "te", "trailer",
+		"connection", "host", "user-agent", "x-grpc-web", "x-user-agent":
+		return true
+	}
+	return strings.HasPrefix(k, "grpc-") || strings.HasPrefix(k, "connect-")
+}
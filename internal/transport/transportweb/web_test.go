@@ -0,0 +1,253 @@
+package transportweb_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	userServiceV1 "github.com/jacktantram/user-service/build/go/rpc/user/v1"
+	v1 "github.com/jacktantram/user-service/build/go/shared/user/v1"
+	"github.com/jacktantram/user-service/internal/domain"
+	"github.com/jacktantram/user-service/internal/transport/transportgrpc"
+	"github.com/jacktantram/user-service/internal/transport/transportgrpc/mocks"
+	"github.com/jacktantram/user-service/internal/transport/transportweb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	userID  = "a8bdce5a-31dc-4647-98b5-ce9cb343138f"
+	getUser = "/rpc.user.v1.UserService/GetUser"
+	origin  = "https://app.example.com"
+)
+
+// interceptor marks responses so tests can assert requests pass through the server's interceptors.
+func interceptor(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	_ = grpc.SetHeader(ctx, metadata.Pairs("x-intercepted", "true"))
+	return handler(ctx, req)
+}
+
+func newHandler(t *testing.T, service transportgrpc.Service) http.Handler {
+	t.Helper()
+	return newCORSHandler(t, service, transportweb.CORSConfig{AllowedOrigins: []string{origin}})
+}
+
+// newCORSHandler creates a handler forwarding to a grpc server with the service served in-memory.
+func newCORSHandler(t *testing.T, service transportgrpc.Service, cfg transportweb.CORSConfig) http.Handler {
+	t.Helper()
+	server, err := transportgrpc.NewServer(grpc.NewServer(grpc.UnaryInterceptor(interceptor)), service)
+	require.NoError(t, err)
+	lis := bufconn.Listen(1 << 20)
+	go func() { _ = server.Serve(lis) }()
+	t.Cleanup(server.Stop)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	handler, err := transportweb.NewHandler(ctx, "in-memory", cfg,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}))
+	require.NoError(t, err)
+	return handler
+}
+
+func frame(t *testing.T, msg proto.Message) []byte {
+	t.Helper()
+	b, err := proto.Marshal(msg)
+	require.NoError(t, err)
+	f := make([]byte, 5+len(b))
+	binary.BigEndian.PutUint32(f[1:5], uint32(len(b)))
+	copy(f[5:], b)
+	return f
+}
+
+func TestNewHandler_Connect(t *testing.T) {
+	t.Parallel()
+	getUserRequest, err := proto.Marshal(&userServiceV1.GetUserRequest{Id: userID})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name            string
+		path            string
+		contentType     string
+		body            []byte
+		setup           func(mockService *mocks.MockService)
+		wantStatus      int
+		wantContentType string
+		wantBody        string
+		wantIntercepted bool
+	}{
+		{
+			name:        "should call a method with a json request",
+			path:        getUser,
+			contentType: "application/json",
+			body:        []byte(`{"id":"` + userID + `"}`),
+			setup: func(mockService *mocks.MockService) {
+				mockService.EXPECT().GetUser(gomock.Any(), userID).Return(&v1.User{Id: userID, FirstName: "John"}, nil)
+			},
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/json",
+			wantBody:        `"firstName":"John"`,
+			wantIntercepted: true,
+		},
+		{
+			name:        "should call a method with a proto request",
+			path:        getUser,
+			contentType: "application/proto",
+			body:        getUserRequest,
+			setup: func(mockService *mocks.MockService) {
+				mockService.EXPECT().GetUser(gomock.Any(), userID).Return(&v1.User{Id: userID, FirstName: "John"}, nil)
+			},
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/proto",
+			wantIntercepted: true,
+		},
+		{
+			name:        "should map errors to connect errors",
+			path:        getUser,
+			contentType: "application/json",
+			body:        []byte(`{"id":"` + userID + `"}`),
+			setup: func(mockService *mocks.MockService) {
+				mockService.EXPECT().GetUser(gomock.Any(), userID).Return(nil, domain.ErrNoUser)
+			},
+			wantStatus:      http.StatusNotFound,
+			wantContentType: "application/json",
+			wantBody:        `"code":"not_found"`,
+			wantIntercepted: true,
+		},
+		{
+			name:            "should call the health check",
+			path:            "/grpc.health.v1.Health/Check",
+			contentType:     "application/json",
+			body:            []byte(`{}`),
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/json",
+			wantBody:        `"status":"SERVING"`,
+			wantIntercepted: true,
+		},
+		{
+			name:            "should reject an invalid json request",
+			path:            getUser,
+			contentType:     "application/json",
+			body:            []byte(`{"id":`),
+			wantStatus:      http.StatusBadRequest,
+			wantContentType: "application/json",
+			wantBody:        `"code":"invalid_argument"`,
+		},
+		{
+			name:        "should reject an unknown method",
+			path:        "/rpc.user.v1.UserService/Unknown",
+			contentType: "application/json",
+			body:        []byte(`{}`),
+			wantStatus:  http.StatusNotFound,
+		},
+		{
+			name:        "should reject an unsupported content type",
+			path:        getUser,
+			contentType: "text/plain",
+			wantStatus:  http.StatusUnsupportedMediaType,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			mockService := mocks.NewMockService(gomock.NewController(t))
+			if tt.setup != nil {
+				tt.setup(mockService)
+			}
+			req := httptest.NewRequest(http.MethodPost, tt.path, bytes.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			req.Header.Set("Connect-Protocol-Version", "1")
+			rec := httptest.NewRecorder()
+			newHandler(t, mockService).ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code, rec.Body.String())
+			if tt.wantContentType != "" {
+				assert.Equal(t, tt.wantContentType, rec.Header().Get("Content-Type"))
+			}
+			assert.Contains(t, rec.Body.String(), tt.wantBody)
+			if tt.wantIntercepted {
+				assert.Equal(t, "true", rec.Header().Get("X-Intercepted"))
+			}
+			if tt.wantContentType == "application/proto" {
+				var resp userServiceV1.GetUserResponse
+				require.NoError(t, proto.Unmarshal(rec.Body.Bytes(), &resp))
+				assert.Equal(t, "John", resp.GetUser().GetFirstName())
+			}
+		})
+	}
+}
+
+func TestNewHandler_GRPCWeb(t *testing.T) {
+	t.Parallel()
+	mockService := mocks.NewMockService(gomock.NewController(t))
+	mockService.EXPECT().GetUser(gomock.Any(), userID).Return(&v1.User{Id: userID, FirstName: "John"}, nil)
+
+	req := httptest.NewRequest(http.MethodPost, getUser, bytes.NewReader(frame(t, &userServiceV1.GetUserRequest{Id: userID})))
+	req.Header.Set("Content-Type", "application/grpc-web+proto")
+	req.Header.Set("X-Grpc-Web", "1")
+	rec := httptest.NewRecorder()
+	newHandler(t, mockService).ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "true", rec.Header().Get("X-Intercepted"))
+	body := rec.Body.Bytes()
+	require.Greater(t, len(body), 5)
+	size := binary.BigEndian.Uint32(body[1:5])
+	var resp userServiceV1.GetUserResponse
+	require.NoError(t, proto.Unmarshal(body[5:5+size], &resp))
+	assert.Equal(t, "John", resp.GetUser().GetFirstName())
+	// gRPC-Web sends trailers in a final frame flagged 0x80
+	trailers := body[5+size:]
+	require.NotEmpty(t, trailers)
+	assert.Equal(t, byte(0x80), trailers[0])
+	assert.Contains(t, strings.ToLower(string(trailers)), "grpc-status: 0")
+}
+
+func TestNewHandler_CORS(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name       string
+		origins    []string
+		origin     string
+		wantOrigin string
+	}{
+		{name: "should allow a configured origin", origins: []string{origin}, origin: origin, wantOrigin: origin},
+		{name: "should not allow other origins", origins: []string{origin}, origin: "https://evil.example.com"},
+		{name: "should not allow any origin when none are configured", origin: origin},
+		{name: "should allow any origin with a wildcard", origins: []string{"*"}, origin: origin, wantOrigin: "*"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			req := httptest.NewRequest(http.MethodOptions, getUser, nil)
+			req.Header.Set("Origin", tt.origin)
+			req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			req.Header.Set("Access-Control-Request-Headers", "connect-protocol-version,content-type")
+			rec := httptest.NewRecorder()
+			newCORSHandler(t, mocks.NewMockService(gomock.NewController(t)),
+				transportweb.CORSConfig{AllowedOrigins: tt.origins}).ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantOrigin, rec.Header().Get("Access-Control-Allow-Origin"))
+		})
+	}
+}
+
+func TestPatterns(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, []string{"/rpc.user.v1.UserService/", "/grpc.health.v1.Health/Check"}, transportweb.Patterns())
+}
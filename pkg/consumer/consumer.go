@@ -0,0 +1,316 @@
+// Package consumer provides typed handlers for consuming user events.
+//
+// Handlers are registered with OnUserCreated, OnUserUpdated, OnUserUpdatedV2 and OnUserDeleted.
+// Failed handlers are retried according to the RetryPolicy and then sent to a dead letter
+// topic, and redelivered events are skipped using the event ID.
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	deadLetterV1 "github.com/jacktantram/user-service/build/go/events/deadletter/v1"
+	eventsV1 "github.com/jacktantram/user-service/build/go/events/user/v1"
+	eventsV2 "github.com/jacktantram/user-service/build/go/events/user/v2"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var (
+	// ErrHandlerFailed is returned when a handler fails and no dead letter topic is configured.
+	ErrHandlerFailed = errors.New("unable to handle event")
+)
+
+// Topics defines the topics user events are consumed from.
+type Topics struct {
+	Created   string
+	Updated   string
+	UpdatedV2 string
+	Deleted   string
+}
+
+// DefaultTopics returns the default topic names.
+func DefaultTopics() Topics {
+	return Topics{
+		Created:   "user-created_v1",
+		Updated:   "user-updated_v1",
+		UpdatedV2: "user-updated_v2",
+		Deleted:   "user-deleted_v1",
+	}
+}
+
+// Message is a message read from a topic.
+type Message struct {
+	Topic     string
+	Partition int32
+	Offset    int64
+	Key       string
+	Value     []byte
+	Headers   map[string][]byte
+}
+
+// Metadata describes the message an event was read from.
+type Metadata struct {
+	Topic     string
+	Partition int32
+	Offset    int64
+	Key       string
+	Headers   map[string][]byte
+	EventID   string
+	// Attempt is the current handling attempt, starting at 1.
+	Attempt int
+}
+
+type metadataKey struct{}
+
+// MetadataFromContext returns the metadata of the event being handled.
+func MetadataFromContext(ctx context.Context) (Metadata, bool) {
+	md, ok := ctx.Value(metadataKey{}).(Metadata)
+	return md, ok
+}
+
+// RetryPolicy configures how failed handlers are retried.
+type RetryPolicy struct {
+	// MaxAttempts is the number of times a handler is called before giving up.
+	MaxAttempts int
+	// InitialBackoff is the wait before the first retry, doubling on every subsequent retry.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the wait between retries.
+	MaxBackoff time.Duration
+}
+
+// DefaultRetryPolicy returns the default retry policy.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{MaxAttempts: 3, InitialBackoff: 100 * time.Millisecond, MaxBackoff: 5 * time.Second}
+}
+
+func (r RetryPolicy) backoff(attempt int) time.Duration {
+	backoff := r.InitialBackoff
+	for i := 1; i < attempt; i++ {
+		backoff *= 2
+		if r.MaxBackoff > 0 && backoff >= r.MaxBackoff {
+			return r.MaxBackoff
+		}
+	}
+	return backoff
+}
+
+// Producer produces dead letters.
+type Producer interface {
+	ProduceKeyedMessage(ctx context.Context, topic string, key string, msg proto.Message) (partition int32, offset int64, err error)
+}
+
+type handler struct {
+	newEvent func() proto.Message
+	handle   func(ctx context.Context, event proto.Message) error
+	// handleMessage handles the raw messages of handlers registered with OnMessage, newEvent is nil.
+	handleMessage func(ctx context.Context, msg Message) error
+}
+
+// Consumer routes user events to typed handlers.
+type Consumer struct {
+	topics          Topics
+	retry           RetryPolicy
+	idempotency     IdempotencyStore
+	deadLetter      Producer
+	deadLetterTopic string
+	handlers        map[string]handler
+}
+
+// Option allows functional options to be passed into the consumer
+type Option func(c *Consumer)
+
+// WithTopics allows the caller to override the default topics
+func WithTopics(topics Topics) Option {
+	return func(c *Consumer) {
+		c.topics = topics
+	}
+}
+
+// WithRetryPolicy allows the caller to override the default retry policy
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *Consumer) {
+		c.retry = policy
+	}
+}
+
+// WithIdempotencyStore sets the store used to skip events that have already been handled.
+// Defaults to an in-memory store remembering the last 10000 events.
+func WithIdempotencyStore(store IdempotencyStore) Option {
+	return func(c *Consumer) {
+		c.idempotency = store
+	}
+}
+
+// WithDeadLetterTopic sends events that still fail after retrying to a dead letter topic as a
+// events.deadletter.v1.DeadLetter, allowing consumption to continue.
+// Without a dead letter topic a failed event stops consumption so it is not committed.
+func WithDeadLetterTopic(p Producer, topic string) Option {
+	return func(c *Consumer) {
+		c.deadLetter = p
+		c.deadLetterTopic = topic
+	}
+}
+
+// New creates a new consumer
+func New(opts ...Option) *Consumer {
+	c := &Consumer{
+		topics:      DefaultTopics(),
+		retry:       DefaultRetryPolicy(),
+		idempotency: NewMemoryIdempotencyStore(defaultIdempotencySize),
+		handlers:    map[string]handler{},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// OnUserCreated registers the handler for UserCreatedEvent.
+func (c *Consumer) OnUserCreated(fn func(ctx context.Context, event *eventsV1.UserCreatedEvent) error) {
+	c.handlers[c.topics.Created] = handler{
+		newEvent: func() proto.Message { return &eventsV1.UserCreatedEvent{} },
+		handle: func(ctx context.Context, event proto.Message) error {
+			return fn(ctx, event.(*eventsV1.UserCreatedEvent))
+		},
+	}
+}
+
+// OnUserUpdated registers the handler for UserUpdatedEvent.
+func (c *Consumer) OnUserUpdated(fn func(ctx context.Context, event *eventsV1.UserUpdatedEvent) error) {
+	c.handlers[c.topics.Updated] = handler{
+		newEvent: func() proto.Message { return &eventsV1.UserUpdatedEvent{} },
+		handle: func(ctx context.Context, event proto.Message) error {
+			return fn(ctx, event.(*eventsV1.UserUpdatedEvent))
+		},
+	}
+}
+
+// OnUserUpdatedV2 registers the handler for the v2 UserUpdatedEvent, carrying the before and after state.
+func (c *Consumer) OnUserUpdatedV2(fn func(ctx context.Context, event *eventsV2.UserUpdatedEvent) error) {
+	c.handlers[c.topics.UpdatedV2] = handler{
+		newEvent: func() proto.Message { return &eventsV2.UserUpdatedEvent{} },
+		handle: func(ctx context.Context, event proto.Message) error {
+			return fn(ctx, event.(*eventsV2.UserUpdatedEvent))
+		},
+	}
+}
+
+// OnUserDeleted registers the handler for UserDeletedEvent.
+func (c *Consumer) OnUserDeleted(fn func(ctx context.Context, event *eventsV1.UserDeletedEvent) error) {
+	c.handlers[c.topics.Deleted] = handler{
+		newEvent: func() proto.Message { return &eventsV1.UserDeletedEvent{} },
+		handle: func(ctx context.Context, event proto.Message) error {
+			return fn(ctx, event.(*eventsV1.UserDeletedEvent))
+		},
+	}
+}
+
+// OnMessage registers a handler for the raw messages of a topic, leaving them to be decoded by the handler.
+// Failed handlers are retried and dead lettered like event handlers, but redelivered messages aren't skipped.
+func (c *Consumer) OnMessage(topic string, fn func(ctx context.Context, msg Message) error) {
+	c.handlers[topic] = handler{handleMessage: fn}
+}
+
+// Topics returns the topics events are consumed from.
+func (c *Consumer) Topics() Topics {
+	return c.topics
+}
+
+// subscriptions returns the topics that have a registered handler.
+func (c *Consumer) subscriptions() []string {
+	topics := make([]string, 0, len(c.handlers))
+	for topic := range c.handlers {
+		topics = append(topics, topic)
+	}
+	return topics
+}
+
+// eventIDGetter is implemented by every user event.
+type eventIDGetter interface {
+	GetEventId() string
+}
+
+// Handle decodes a message and calls its handler, retrying on failure.
+// A nil error means the message is done with and its offset can be committed.
+func (c *Consumer) Handle(ctx context.Context, msg Message) error {
+	h, ok := c.handlers[msg.Topic]
+	if !ok {
+		return nil
+	}
+	md := Metadata{Topic: msg.Topic, Partition: msg.Partition, Offset: msg.Offset, Key: msg.Key, Headers: msg.Headers}
+	logger := log.WithFields(log.Fields{"topic_name": msg.Topic, "partition": msg.Partition, "offset": msg.Offset})
+
+	handle := func(ctx context.Context) error { return h.handleMessage(ctx, msg) }
+	if h.newEvent != nil {
+		event := h.newEvent()
+		if err := proto.Unmarshal(msg.Value, event); err != nil {
+			// retrying won't help an event that can't be decoded
+			return c.sendToDeadLetter(ctx, msg, 0, fmt.Errorf("unable to unmarshal event: %w", err))
+		}
+		if getter, ok := event.(eventIDGetter); ok {
+			md.EventID = getter.GetEventId()
+		}
+		handle = func(ctx context.Context) error { return h.handle(ctx, event) }
+	}
+	if md.EventID != "" {
+		seen, err := c.idempotency.Seen(ctx, md.EventID)
+		if err != nil {
+			return fmt.Errorf("unable to check if event was handled: %w", err)
+		}
+		if seen {
+			logger.WithField("event_id", md.EventID).Debug("skipping event that has already been handled")
+			return nil
+		}
+	}
+
+	var err error
+	for md.Attempt = 1; ; md.Attempt++ {
+		if err = handle(context.WithValue(ctx, metadataKey{}, md)); err == nil {
+			break
+		}
+		logger.WithError(err).WithField("attempt", md.Attempt).Warn("unable to handle event")
+		if md.Attempt >= c.retry.MaxAttempts {
+			if err = c.sendToDeadLetter(ctx, msg, md.Attempt, err); err != nil {
+				return err
+			}
+			break
+		}
+		select {
+		case <-time.After(c.retry.backoff(md.Attempt)):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	if md.EventID != "" {
+		if err = c.idempotency.MarkSeen(ctx, md.EventID); err != nil {
+			return fmt.Errorf("unable to mark event as handled: %w", err)
+		}
+	}
+	return nil
+}
+
+func (c *Consumer) sendToDeadLetter(ctx context.Context, msg Message, attempts int, handleErr error) error {
+	if c.deadLetter == nil {
+		return fmt.Errorf("%w: %v", ErrHandlerFailed, handleErr)
+	}
+	deadLetter := &deadLetterV1.DeadLetter{
+		Topic:     msg.Topic,
+		Key:       msg.Key,
+		Payload:   msg.Value,
+		Error:     handleErr.Error(),
+		Attempts:  int32(attempts),
+		CreatedAt: timestamppb.Now(),
+	}
+	if h, ok := c.handlers[msg.Topic]; ok && h.newEvent != nil {
+		deadLetter.MessageType = string(h.newEvent().ProtoReflect().Descriptor().FullName())
+	}
+	if _, _, err := c.deadLetter.ProduceKeyedMessage(ctx, c.deadLetterTopic, msg.Key, deadLetter); err != nil {
+		return fmt.Errorf("unable to produce dead letter: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package consumer_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	deadLetterV1 "github.com/jacktantram/user-service/build/go/events/deadletter/v1"
+	eventsV1 "github.com/jacktantram/user-service/build/go/events/user/v1"
+	sharedV1 "github.com/jacktantram/user-service/build/go/shared/user/v1"
+	"github.com/jacktantram/user-service/pkg/consumer"
+	"github.com/jacktantram/user-service/pkg/consumer/consumertest"
+	"github.com/jacktantram/user-service/pkg/driver/v1/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var noBackoff = consumer.WithRetryPolicy(consumer.RetryPolicy{MaxAttempts: 3})
+
+func TestConsumer_Handle(t *testing.T) {
+	t.Parallel()
+
+	t.Run("should route events to typed handlers with metadata", func(t *testing.T) {
+		t.Parallel()
+		c := consumer.New()
+		var (
+			created *eventsV1.UserCreatedEvent
+			deleted *eventsV1.UserDeletedEvent
+			md      consumer.Metadata
+		)
+		c.OnUserCreated(func(ctx context.Context, event *eventsV1.UserCreatedEvent) error {
+			created = event
+			md, _ = consumer.MetadataFromContext(ctx)
+			return nil
+		})
+		c.OnUserDeleted(func(ctx context.Context, event *eventsV1.UserDeletedEvent) error {
+			deleted = event
+			return nil
+		})
+		h := consumertest.NewHarness(c)
+
+		require.NoError(t, h.UserCreated(context.Background(), &eventsV1.UserCreatedEvent{
+			User: &sharedV1.User{Id: "a-user-id"}, EventId: "event-1"}))
+		require.NoError(t, h.UserDeleted(context.Background(), &eventsV1.UserDeletedEvent{
+			User: &sharedV1.User{Id: "a-user-id"}, EventId: "event-2"}))
+		// no handler is registered for updates so they are ignored
+		require.NoError(t, h.UserUpdated(context.Background(), &eventsV1.UserUpdatedEvent{EventId: "event-3"}))
+
+		require.NotNil(t, created)
+		assert.Equal(t, "a-user-id", created.GetUser().GetId())
+		assert.Equal(t, consumer.Metadata{Topic: "user-created_v1", EventID: "event-1", Attempt: 1}, md)
+		require.NotNil(t, deleted)
+	})
+
+	t.Run("should route raw messages to message handlers", func(t *testing.T) {
+		t.Parallel()
+		c := consumer.New(noBackoff)
+		var (
+			values []string
+			md     consumer.Metadata
+		)
+		c.OnMessage("usercommand-requested_v1", func(ctx context.Context, msg consumer.Message) error {
+			values = append(values, string(msg.Value))
+			md, _ = consumer.MetadataFromContext(ctx)
+			if len(values) < 2 {
+				return errors.New("temporary failure")
+			}
+			return nil
+		})
+
+		require.NoError(t, consumertest.NewHarness(c).FeedRaw(context.Background(), consumer.Message{
+			Topic: "usercommand-requested_v1", Value: []byte("not a proto"),
+			Headers: map[string][]byte{"x-request-id": []byte("a-request-id")}}))
+		assert.Equal(t, []string{"not a proto", "not a proto"}, values, "should retry the handler")
+		assert.Equal(t, consumer.Metadata{Topic: "usercommand-requested_v1", Attempt: 2,
+			Headers: map[string][]byte{"x-request-id": []byte("a-request-id")}}, md)
+	})
+
+	t.Run("should skip events that have already been handled", func(t *testing.T) {
+		t.Parallel()
+		c := consumer.New()
+		calls := 0
+		c.OnUserCreated(func(ctx context.Context, event *eventsV1.UserCreatedEvent) error {
+			calls++
+			return nil
+		})
+		h := consumertest.NewHarness(c)
+
+		for i := 0; i < 2; i++ {
+			require.NoError(t, h.UserCreated(context.Background(), &eventsV1.UserCreatedEvent{EventId: "event-1"}))
+		}
+		require.NoError(t, h.UserCreated(context.Background(), &eventsV1.UserCreatedEvent{EventId: "event-2"}))
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("should retry failed handlers", func(t *testing.T) {
+		t.Parallel()
+		c := consumer.New(noBackoff)
+		var attempts []int
+		c.OnUserCreated(func(ctx context.Context, event *eventsV1.UserCreatedEvent) error {
+			md, _ := consumer.MetadataFromContext(ctx)
+			attempts = append(attempts, md.Attempt)
+			if md.Attempt < 2 {
+				return errors.New("temporary failure")
+			}
+			return nil
+		})
+
+		require.NoError(t, consumertest.NewHarness(c).UserCreated(context.Background(), &eventsV1.UserCreatedEvent{}))
+		assert.Equal(t, []int{1, 2}, attempts)
+	})
+
+	t.Run("should send events to the dead letter topic once retries are exhausted", func(t *testing.T) {
+		t.Parallel()
+		bus := memory.NewBus()
+		c := consumer.New(noBackoff, consumer.WithDeadLetterTopic(bus, "user-consumer-deadletter_v1"))
+		calls := 0
+		c.OnUserCreated(func(ctx context.Context, event *eventsV1.UserCreatedEvent) error {
+			calls++
+			return errors.New("permanent failure")
+		})
+		h := consumertest.NewHarness(c)
+
+		event := &eventsV1.UserCreatedEvent{EventId: "event-1"}
+		require.NoError(t, h.UserCreated(context.Background(), event))
+		// a dead-lettered event counts as handled
+		require.NoError(t, h.UserCreated(context.Background(), event))
+		assert.Equal(t, 3, calls)
+
+		msgs := bus.Messages("user-consumer-deadletter_v1")
+		require.Len(t, msgs, 1)
+		deadLetter, ok := msgs[0].Value.(*deadLetterV1.DeadLetter)
+		require.True(t, ok)
+		assert.Equal(t, "user-created_v1", deadLetter.Topic)
+		assert.Equal(t, "events.user.v1.UserCreatedEvent", deadLetter.MessageType)
+		assert.Equal(t, "permanent failure", deadLetter.Error)
+		assert.Equal(t, int32(3), deadLetter.Attempts)
+	})
+
+	t.Run("should dead letter events that can't be decoded without retrying", func(t *testing.T) {
+		t.Parallel()
+		bus := memory.NewBus()
+		c := consumer.New(consumer.WithDeadLetterTopic(bus, "user-consumer-deadletter_v1"))
+		c.OnUserCreated(func(ctx context.Context, event *eventsV1.UserCreatedEvent) error {
+			t.Fatal("handler should not be called")
+			return nil
+		})
+
+		require.NoError(t, consumertest.NewHarness(c).FeedRaw(context.Background(),
+			consumer.Message{Topic: "user-created_v1", Value: []byte("not a proto")}))
+		assert.Len(t, bus.Messages("user-consumer-deadletter_v1"), 1)
+	})
+
+	t.Run("should error when retries are exhausted without a dead letter topic", func(t *testing.T) {
+		t.Parallel()
+		c := consumer.New(noBackoff)
+		c.OnUserCreated(func(ctx context.Context, event *eventsV1.UserCreatedEvent) error {
+			return errors.New("permanent failure")
+		})
+
+		err := consumertest.NewHarness(c).UserCreated(context.Background(), &eventsV1.UserCreatedEvent{})
+		assert.ErrorIs(t, err, consumer.ErrHandlerFailed)
+	})
+
+	t.Run("should stop retrying when the context is done", func(t *testing.T) {
+		t.Parallel()
+		c := consumer.New(consumer.WithRetryPolicy(consumer.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Hour}))
+		c.OnUserCreated(func(ctx context.Context, event *eventsV1.UserCreatedEvent) error {
+			return errors.New("temporary failure")
+		})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		err := consumertest.NewHarness(c).UserCreated(ctx, &eventsV1.UserCreatedEvent{})
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
+
+func TestMemoryIdempotencyStore(t *testing.T) {
+	t.Parallel()
+	store := consumer.NewMemoryIdempotencyStore(2)
+	ctx := context.Background()
+	for _, id := range []string{"event-1", "event-2", "event-3"} {
+		require.NoError(t, store.MarkSeen(ctx, id))
+	}
+
+	seen, err := store.Seen(ctx, "event-1")
+	require.NoError(t, err)
+	assert.False(t, seen, "oldest event should be forgotten")
+	seen, err = store.Seen(ctx, "event-3")
+	require.NoError(t, err)
+	assert.True(t, seen)
+}
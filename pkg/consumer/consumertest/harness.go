@@ -0,0 +1,67 @@
+// Package consumertest feeds events to a consumer without a broker, for testing handlers.
+package consumertest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	eventsV1 "github.com/jacktantram/user-service/build/go/events/user/v1"
+	eventsV2 "github.com/jacktantram/user-service/build/go/events/user/v2"
+	"github.com/jacktantram/user-service/pkg/consumer"
+	"google.golang.org/protobuf/proto"
+)
+
+// Harness feeds events to a consumer as if they were read from a topic.
+// Events go through decoding, retries, idempotency and dead-lettering exactly as they would from Kafka.
+type Harness struct {
+	c *consumer.Consumer
+
+	mu      sync.Mutex
+	offsets map[string]int64
+}
+
+// NewHarness creates a harness for the consumer.
+func NewHarness(c *consumer.Consumer) *Harness {
+	return &Harness{c: c, offsets: map[string]int64{}}
+}
+
+// UserCreated feeds a UserCreatedEvent to the consumer.
+func (h *Harness) UserCreated(ctx context.Context, event *eventsV1.UserCreatedEvent) error {
+	return h.Feed(ctx, h.c.Topics().Created, event)
+}
+
+// UserUpdated feeds a UserUpdatedEvent to the consumer.
+func (h *Harness) UserUpdated(ctx context.Context, event *eventsV1.UserUpdatedEvent) error {
+	return h.Feed(ctx, h.c.Topics().Updated, event)
+}
+
+// UserUpdatedV2 feeds a v2 UserUpdatedEvent to the consumer.
+func (h *Harness) UserUpdatedV2(ctx context.Context, event *eventsV2.UserUpdatedEvent) error {
+	return h.Feed(ctx, h.c.Topics().UpdatedV2, event)
+}
+
+// UserDeleted feeds a UserDeletedEvent to the consumer.
+func (h *Harness) UserDeleted(ctx context.Context, event *eventsV1.UserDeletedEvent) error {
+	return h.Feed(ctx, h.c.Topics().Deleted, event)
+}
+
+// Feed encodes a message and feeds it to the consumer on the given topic.
+// Offsets increase per topic from 0.
+func (h *Harness) Feed(ctx context.Context, topic string, msg proto.Message) error {
+	value, err := proto.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("unable to marshal proto bytes: %w", err)
+	}
+	return h.FeedRaw(ctx, consumer.Message{Topic: topic, Value: value})
+}
+
+// FeedRaw feeds a message as is, allowing malformed payloads to be tested.
+// The offset is assigned by the harness.
+func (h *Harness) FeedRaw(ctx context.Context, msg consumer.Message) error {
+	h.mu.Lock()
+	msg.Offset = h.offsets[msg.Topic]
+	h.offsets[msg.Topic]++
+	h.mu.Unlock()
+	return h.c.Handle(ctx, msg)
+}
@@ -0,0 +1,57 @@
+package consumer
+
+import (
+	"container/list"
+	"context"
+	"sync"
+)
+
+const defaultIdempotencySize = 10000
+
+// IdempotencyStore remembers the IDs of events that have been handled.
+type IdempotencyStore interface {
+	Seen(ctx context.Context, eventID string) (bool, error)
+	MarkSeen(ctx context.Context, eventID string) error
+}
+
+// MemoryIdempotencyStore remembers a bounded number of event IDs, forgetting the oldest first.
+// It only protects against redelivery within a single process, use a shared store when
+// handlers run across several instances.
+type MemoryIdempotencyStore struct {
+	mu    sync.Mutex
+	size  int
+	ids   map[string]*list.Element
+	order *list.List
+}
+
+// NewMemoryIdempotencyStore creates an in-memory store remembering up to size event IDs.
+func NewMemoryIdempotencyStore(size int) *MemoryIdempotencyStore {
+	if size <= 0 {
+		size = defaultIdempotencySize
+	}
+	return &MemoryIdempotencyStore{size: size, ids: map[string]*list.Element{}, order: list.New()}
+}
+
+// Seen reports whether the event has been handled.
+func (s *MemoryIdempotencyStore) Seen(_ context.Context, eventID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.ids[eventID]
+	return ok, nil
+}
+
+// MarkSeen records the event as handled.
+func (s *MemoryIdempotencyStore) MarkSeen(_ context.Context, eventID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.ids[eventID]; ok {
+		return nil
+	}
+	s.ids[eventID] = s.order.PushBack(eventID)
+	if s.order.Len() > s.size {
+		oldest := s.order.Front()
+		s.order.Remove(oldest)
+		delete(s.ids, oldest.Value.(string))
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/Shopify/sarama"
+)
+
+// KafkaConfig configures the Kafka consumer group.
+type KafkaConfig struct {
+	Hosts   []string
+	GroupID string
+}
+
+// RunKafka consumes the topics with a registered handler as part of a consumer group until the context is done.
+// Offsets are committed once a message has been handled. Consumption stops with an error when a
+// message can't be handled and no dead letter topic is configured, leaving its offset uncommitted.
+func (c *Consumer) RunKafka(ctx context.Context, cfg KafkaConfig) error {
+	topics := c.subscriptions()
+	if len(topics) == 0 {
+		return errors.New("no handlers registered")
+	}
+
+	config := sarama.NewConfig()
+	config.Consumer.Offsets.Initial = sarama.OffsetOldest
+	config.Consumer.Offsets.AutoCommit.Enable = false
+
+	group, err := sarama.NewConsumerGroup(cfg.Hosts, cfg.GroupID, config)
+	if err != nil {
+		return fmt.Errorf("unable to create kafka consumer group: %w", err)
+	}
+	defer group.Close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	handler := &groupHandler{c: c, cancel: cancel}
+	for {
+		err = group.Consume(ctx, topics, handler)
+		if handlerErr := handler.Err(); handlerErr != nil {
+			return handlerErr
+		}
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+}
+
+// groupHandler handles the messages of the claimed partitions.
+// The first handling error cancels consumption.
+type groupHandler struct {
+	c      *Consumer
+	cancel context.CancelFunc
+
+	mu  sync.Mutex
+	err error
+}
+
+// Err returns the error that stopped consumption.
+func (h *groupHandler) Err() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.err
+}
+
+func (h *groupHandler) Setup(sarama.ConsumerGroupSession) error { return nil }
+
+func (h *groupHandler) Cleanup(sarama.ConsumerGroupSession) error { return nil }
+
+func (h *groupHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for msg := range claim.Messages() {
+		err := h.c.Handle(session.Context(), Message{
+			Topic:     msg.Topic,
+			Partition: msg.Partition,
+			Offset:    msg.Offset,
+			Key:       string(msg.Key),
+			Value:     msg.Value,
+			Headers:   messageHeaders(msg),
+		})
+		if err != nil {
+			if session.Context().Err() == nil {
+				h.mu.Lock()
+				if h.err == nil {
+					h.err = err
+				}
+				h.mu.Unlock()
+				h.cancel()
+			}
+			return err
+		}
+		session.MarkMessage(msg, "")
+		session.Commit()
+	}
+	return nil
+}
+
+// messageHeaders returns the headers of a message by key, nil if it has none.
+func messageHeaders(msg *sarama.ConsumerMessage) map[string][]byte {
+	if len(msg.Headers) == 0 {
+		return nil
+	}
+	headers := make(map[string][]byte, len(msg.Headers))
+	for _, header := range msg.Headers {
+		if header != nil {
+			headers[string(header.Key)] = header.Value
+		}
+	}
+	return headers
+}
@@ -0,0 +1,105 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	eventsV1 "github.com/jacktantram/user-service/build/go/events/user/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+type fakeSession struct {
+	sarama.ConsumerGroupSession
+	ctx     context.Context
+	marked  []int64
+	commits int
+}
+
+func (s *fakeSession) Context() context.Context { return s.ctx }
+
+func (s *fakeSession) MarkMessage(msg *sarama.ConsumerMessage, _ string) {
+	s.marked = append(s.marked, msg.Offset)
+}
+
+func (s *fakeSession) Commit() { s.commits++ }
+
+type fakeClaim struct {
+	sarama.ConsumerGroupClaim
+	msgs chan *sarama.ConsumerMessage
+}
+
+func (c fakeClaim) Messages() <-chan *sarama.ConsumerMessage { return c.msgs }
+
+func newFakeClaim(t *testing.T, failing ...bool) fakeClaim {
+	claim := fakeClaim{msgs: make(chan *sarama.ConsumerMessage, len(failing))}
+	for i, fail := range failing {
+		event := &eventsV1.UserCreatedEvent{}
+		if fail {
+			event.EventId = "fail"
+		}
+		value, err := proto.Marshal(event)
+		require.NoError(t, err)
+		claim.msgs <- &sarama.ConsumerMessage{Topic: "user-created_v1", Offset: int64(i), Value: value}
+	}
+	close(claim.msgs)
+	return claim
+}
+
+func TestGroupHandler_ConsumeClaim(t *testing.T) {
+	t.Parallel()
+	newConsumer := func() *Consumer {
+		c := New(WithRetryPolicy(RetryPolicy{MaxAttempts: 1}))
+		c.OnUserCreated(func(ctx context.Context, event *eventsV1.UserCreatedEvent) error {
+			if event.EventId == "fail" {
+				return errors.New("permanent failure")
+			}
+			return nil
+		})
+		return c
+	}
+
+	t.Run("should commit offsets after handling each message", func(t *testing.T) {
+		t.Parallel()
+		session := &fakeSession{ctx: context.Background()}
+		h := &groupHandler{c: newConsumer(), cancel: func() {}}
+
+		require.NoError(t, h.ConsumeClaim(session, newFakeClaim(t, false, false)))
+		assert.Equal(t, []int64{0, 1}, session.marked)
+		assert.Equal(t, 2, session.commits)
+	})
+
+	t.Run("should stop without committing a message that failed", func(t *testing.T) {
+		t.Parallel()
+		session := &fakeSession{ctx: context.Background()}
+		cancelled := false
+		h := &groupHandler{c: newConsumer(), cancel: func() { cancelled = true }}
+
+		err := h.ConsumeClaim(session, newFakeClaim(t, false, true, false))
+		assert.ErrorIs(t, err, ErrHandlerFailed)
+		assert.ErrorIs(t, h.Err(), ErrHandlerFailed)
+		assert.True(t, cancelled)
+		assert.Equal(t, []int64{0}, session.marked)
+	})
+	t.Run("should pass the headers of a message to its handler", func(t *testing.T) {
+		t.Parallel()
+		c := New()
+		var got Message
+		c.OnMessage("usercommand-requested_v1", func(ctx context.Context, msg Message) error {
+			got = msg
+			return nil
+		})
+		claim := fakeClaim{msgs: make(chan *sarama.ConsumerMessage, 1)}
+		claim.msgs <- &sarama.ConsumerMessage{Topic: "usercommand-requested_v1", Key: []byte("a-key"), Value: []byte("a-value"),
+			Headers: []*sarama.RecordHeader{{Key: []byte("x-request-id"), Value: []byte("a-request-id")}}}
+		close(claim.msgs)
+
+		h := &groupHandler{c: c, cancel: func() {}}
+		require.NoError(t, h.ConsumeClaim(&fakeSession{ctx: context.Background()}, claim))
+		assert.Equal(t, Message{Topic: "usercommand-requested_v1", Key: "a-key", Value: []byte("a-value"),
+			Headers: map[string][]byte{"x-request-id": []byte("a-request-id")}}, got)
+	})
+}
@@ -0,0 +1,88 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Record is a single line written by the producer.
+type Record struct {
+	Time  time.Time `json:"time"`
+	Topic string    `json:"topic"`
+	Key   string    `json:"key,omitempty"`
+	// Type is the full proto name of the message, empty for tombstones.
+	Type string `json:"type,omitempty"`
+	// Value is the message encoded as proto JSON, null for tombstones.
+	Value json.RawMessage `json:"value"`
+}
+
+// Producer writes messages as newline delimited JSON, useful for debugging events locally.
+type Producer struct {
+	mu     sync.Mutex
+	w      io.Writer
+	offset int64
+}
+
+// NewProducer creates a producer appending to the file at path, creating it if needed.
+func NewProducer(path string) (*Producer, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open event file: %w", err)
+	}
+	return NewWriterProducer(f), nil
+}
+
+// NewWriterProducer creates a producer writing to w.
+func NewWriterProducer(w io.Writer) *Producer {
+	return &Producer{w: w}
+}
+
+// ProduceMessage writes a proto message to a topic.
+// The offset returned is the number of messages written before it.
+func (p *Producer) ProduceMessage(ctx context.Context, topic string, msg proto.Message) (partition int32, offset int64, err error) {
+	return p.ProduceKeyedMessage(ctx, topic, "", msg)
+}
+
+// ProduceKeyedMessage writes a proto message to a topic with a key. A nil message is a tombstone.
+func (p *Producer) ProduceKeyedMessage(ctx context.Context, topic string, key string, msg proto.Message) (partition int32, offset int64, err error) {
+	record := Record{Time: time.Now().UTC(), Topic: topic, Key: key, Value: json.RawMessage("null")}
+	if msg != nil {
+		value, err := protojson.Marshal(msg)
+		if err != nil {
+			return 0, 0, fmt.Errorf("unable to marshal proto json: %w", err)
+		}
+		record.Type = string(msg.ProtoReflect().Descriptor().FullName())
+		record.Value = value
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return 0, 0, fmt.Errorf("unable to marshal record: %w", err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, err = p.w.Write(append(line, '\n')); err != nil {
+		return 0, 0, fmt.Errorf("unable to write record: %w", err)
+	}
+	offset = p.offset
+	p.offset++
+	return 0, offset, nil
+}
+
+// Close closes the underlying writer if it is closable.
+func (p *Producer) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if c, ok := p.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package file_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	v1 "github.com/jacktantram/user-service/build/go/events/user/v1"
+	sharedV1 "github.com/jacktantram/user-service/build/go/shared/user/v1"
+	"github.com/jacktantram/user-service/pkg/driver/v1/file"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProducer_ProduceMessage(t *testing.T) {
+	t.Parallel()
+
+	t.Run("should write messages as newline delimited json", func(t *testing.T) {
+		t.Parallel()
+		buf := &bytes.Buffer{}
+		p := file.NewWriterProducer(buf)
+
+		_, offset, err := p.ProduceMessage(context.Background(), "user-created_v1",
+			&v1.UserCreatedEvent{User: &sharedV1.User{Id: "a-user-id", FirstName: "John"}})
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), offset)
+		_, offset, err = p.ProduceKeyedMessage(context.Background(), "users-state_v1", "a-user-id", nil)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), offset)
+
+		var records []file.Record
+		scanner := bufio.NewScanner(buf)
+		for scanner.Scan() {
+			var record file.Record
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+			records = append(records, record)
+		}
+		require.Len(t, records, 2)
+
+		assert.Equal(t, "user-created_v1", records[0].Topic)
+		assert.Equal(t, "events.user.v1.UserCreatedEvent", records[0].Type)
+		assert.JSONEq(t, `{"user":{"id":"a-user-id","firstName":"John"}}`, string(records[0].Value))
+
+		assert.Equal(t, "users-state_v1", records[1].Topic)
+		assert.Equal(t, "a-user-id", records[1].Key)
+		assert.Empty(t, records[1].Type)
+		assert.Equal(t, "null", string(records[1].Value))
+	})
+
+	t.Run("should append to a file", func(t *testing.T) {
+		t.Parallel()
+		path := filepath.Join(t.TempDir(), "events.ndjson")
+		for i := 0; i < 2; i++ {
+			p, err := file.NewProducer(path)
+			require.NoError(t, err)
+			_, _, err = p.ProduceMessage(context.Background(), "user-created_v1", &v1.UserCreatedEvent{})
+			require.NoError(t, err)
+			require.NoError(t, p.Close())
+		}
+
+		b, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, 2, bytes.Count(b, []byte("\n")))
+	})
+}
@@ -0,0 +1,207 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	// ErrProducerClosed is returned when attempting to produce on a closed producer.
+	ErrProducerClosed = errors.New("producer is closed")
+)
+
+const (
+	defaultBatchSize   = 100
+	defaultLinger      = 10 * time.Millisecond
+	defaultMaxInFlight = 1000
+)
+
+// DeliveryReport describes the outcome of an asynchronously produced message.
+type DeliveryReport struct {
+	Topic     string
+	Partition int32
+	Offset    int64
+	// Err is set when the message could not be delivered.
+	Err error
+}
+
+// AsyncProducerConfig configures batching and delivery reporting for an AsyncProducer.
+type AsyncProducerConfig struct {
+	// BatchSize is the number of buffered messages that triggers a flush to the broker.
+	BatchSize int
+	// BatchBytes is the number of buffered bytes that triggers a flush to the broker.
+	BatchBytes int
+	// Linger is the maximum time a message is buffered before it is flushed.
+	Linger time.Duration
+	// MaxInFlight bounds the number of messages that can be awaiting acknowledgement.
+	// Once reached, ProduceMessage blocks until a delivery report is received.
+	MaxInFlight int
+	// OnSuccess is called for every message acknowledged by the broker.
+	OnSuccess func(report DeliveryReport)
+	// OnError is called for every message that failed to be delivered.
+	OnError func(report DeliveryReport)
+	// Metrics records per topic producer metrics and sarama's internal metrics, optional.
+	Metrics *ProducerMetrics
+}
+
+func (c *AsyncProducerConfig) setDefaults() {
+	if c.BatchSize <= 0 {
+		c.BatchSize = defaultBatchSize
+	}
+	if c.Linger <= 0 {
+		c.Linger = defaultLinger
+	}
+	if c.MaxInFlight <= 0 {
+		c.MaxInFlight = defaultMaxInFlight
+	}
+}
+
+// AsyncProducer writes messages to topics without waiting for broker acknowledgement.
+// Messages are batched by sarama and the outcome is surfaced through the configured
+// delivery report callbacks.
+type AsyncProducer struct {
+	p   sarama.AsyncProducer
+	cfg AsyncProducerConfig
+
+	inFlight chan struct{}
+	done     chan struct{}
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewAsyncProducer creates a new asynchronous producer
+func NewAsyncProducer(cfg AsyncProducerConfig, hosts ...string) (*AsyncProducer, error) {
+	cfg.setDefaults()
+
+	config := sarama.NewConfig()
+	config.Producer.Partitioner = sarama.NewHashPartitioner
+	config.Producer.RequiredAcks = sarama.WaitForAll
+	config.Producer.Return.Successes = true
+	config.Producer.Return.Errors = true
+	config.Producer.Flush.Messages = cfg.BatchSize
+	config.Producer.Flush.Bytes = cfg.BatchBytes
+	config.Producer.Flush.Frequency = cfg.Linger
+	config.ChannelBufferSize = cfg.MaxInFlight
+	if cfg.Metrics != nil {
+		config.MetricRegistry = cfg.Metrics.registry
+	}
+
+	producer, err := sarama.NewAsyncProducer(hosts, config)
+	if err != nil {
+		return nil, err
+	}
+	return newAsyncProducer(producer, cfg), nil
+}
+
+func newAsyncProducer(producer sarama.AsyncProducer, cfg AsyncProducerConfig) *AsyncProducer {
+	cfg.setDefaults()
+	p := &AsyncProducer{
+		p:        producer,
+		cfg:      cfg,
+		inFlight: make(chan struct{}, cfg.MaxInFlight),
+		done:     make(chan struct{}),
+	}
+	go p.report()
+	return p
+}
+
+// ProduceMessage enqueues a proto message to be written to a topic.
+// The partition and offset are not known until the message is acknowledged,
+// so -1 is returned for both; use the delivery report callbacks to observe them.
+func (p *AsyncProducer) ProduceMessage(ctx context.Context, topic string, msg proto.Message) (partition int32, offset int64, err error) {
+	return p.ProduceKeyedMessage(ctx, topic, "", msg)
+}
+
+// ProduceKeyedMessage enqueues a proto message to be written to a topic partitioned by key.
+// A nil message is written as a tombstone, removing the key from compacted topics.
+func (p *AsyncProducer) ProduceKeyedMessage(ctx context.Context, topic string, key string, msg proto.Message) (partition int32, offset int64, err error) {
+	producerMessage, err := newProducerMessage(ctx, topic, key, msg)
+	if err != nil {
+		return 0, 0, err
+	}
+	// the enqueue time is carried through to the delivery report to measure latency
+	producerMessage.Metadata = time.Now()
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return 0, 0, ErrProducerClosed
+	}
+
+	select {
+	case p.inFlight <- struct{}{}:
+	case <-ctx.Done():
+		return 0, 0, ctx.Err()
+	}
+
+	select {
+	case p.p.Input() <- producerMessage:
+	case <-ctx.Done():
+		<-p.inFlight
+		return 0, 0, ctx.Err()
+	}
+	return -1, -1, nil
+}
+
+// Close flushes any buffered messages and waits for their delivery reports.
+// It returns early with the context error if the context is done before the flush completes.
+func (p *AsyncProducer) Close(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		p.p.AsyncClose()
+	}
+	p.mu.Unlock()
+
+	select {
+	case <-p.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *AsyncProducer) report() {
+	defer close(p.done)
+
+	successes, errs := p.p.Successes(), p.p.Errors()
+	for successes != nil || errs != nil {
+		select {
+		case msg, ok := <-successes:
+			if !ok {
+				successes = nil
+				continue
+			}
+			<-p.inFlight
+			p.observe(msg, nil)
+			if p.cfg.OnSuccess != nil {
+				p.cfg.OnSuccess(DeliveryReport{Topic: msg.Topic, Partition: msg.Partition, Offset: msg.Offset})
+			}
+		case perr, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			<-p.inFlight
+			p.observe(perr.Msg, perr.Err)
+			if p.cfg.OnError != nil {
+				p.cfg.OnError(DeliveryReport{Topic: perr.Msg.Topic, Partition: perr.Msg.Partition,
+					Offset: perr.Msg.Offset, Err: perr.Err})
+			}
+		}
+	}
+}
+
+func (p *AsyncProducer) observe(msg *sarama.ProducerMessage, err error) {
+	start, ok := msg.Metadata.(time.Time)
+	if !ok {
+		return
+	}
+	p.cfg.Metrics.observe(msg.Topic, messageSize(msg), start, err)
+}
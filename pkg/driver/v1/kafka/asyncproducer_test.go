@@ -0,0 +1,129 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/Shopify/sarama/mocks"
+	v1 "github.com/jacktantram/user-service/build/go/events/user/v1"
+	"github.com/jacktantram/user-service/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newMockSaramaConfig() *sarama.Config {
+	config := sarama.NewConfig()
+	config.Producer.Return.Successes = true
+	config.Producer.Return.Errors = true
+	return config
+}
+
+func TestAsyncProducer_ProduceMessage(t *testing.T) {
+	t.Parallel()
+
+	t.Run("should report successful and failed deliveries", func(t *testing.T) {
+		t.Parallel()
+		mockProducer := mocks.NewAsyncProducer(t, newMockSaramaConfig())
+		mockProducer.ExpectInputAndSucceed()
+		mockProducer.ExpectInputAndFail(errors.New("broker down"))
+
+		var (
+			mu        sync.Mutex
+			successes []DeliveryReport
+			failures  []DeliveryReport
+		)
+		p := newAsyncProducer(mockProducer, AsyncProducerConfig{
+			OnSuccess: func(report DeliveryReport) {
+				mu.Lock()
+				defer mu.Unlock()
+				successes = append(successes, report)
+			},
+			OnError: func(report DeliveryReport) {
+				mu.Lock()
+				defer mu.Unlock()
+				failures = append(failures, report)
+			},
+		})
+
+		_, _, err := p.ProduceMessage(context.Background(), "user-created_v1", &v1.UserCreatedEvent{})
+		require.NoError(t, err)
+		_, _, err = p.ProduceMessage(context.Background(), "user-created_v1", &v1.UserCreatedEvent{})
+		require.NoError(t, err)
+
+		require.NoError(t, p.Close(context.Background()))
+
+		require.Len(t, successes, 1)
+		assert.Equal(t, "user-created_v1", successes[0].Topic)
+		require.Len(t, failures, 1)
+		assert.EqualError(t, failures[0].Err, "broker down")
+	})
+
+	t.Run("should produce keyed tombstones for nil messages", func(t *testing.T) {
+		t.Parallel()
+		mockProducer := mocks.NewAsyncProducer(t, newMockSaramaConfig())
+		mockProducer.ExpectInputWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+			key, err := msg.Key.Encode()
+			if err != nil {
+				return err
+			}
+			if string(key) != "a-user-id" {
+				return errors.New("unexpected key")
+			}
+			if msg.Value != nil {
+				return errors.New("expected a tombstone")
+			}
+			return nil
+		})
+
+		p := newAsyncProducer(mockProducer, AsyncProducerConfig{})
+		_, _, err := p.ProduceKeyedMessage(context.Background(), "users-state_v1", "a-user-id", nil)
+		require.NoError(t, err)
+		require.NoError(t, p.Close(context.Background()))
+	})
+
+	t.Run("should stamp the request id header", func(t *testing.T) {
+		t.Parallel()
+		mockProducer := mocks.NewAsyncProducer(t, newMockSaramaConfig())
+		mockProducer.ExpectInputWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+			if len(msg.Headers) != 1 || string(msg.Headers[0].Key) != logging.RequestIDHeader ||
+				string(msg.Headers[0].Value) != "a-request-id" {
+				return errors.New("expected the request id header")
+			}
+			return nil
+		})
+
+		p := newAsyncProducer(mockProducer, AsyncProducerConfig{})
+		ctx := logging.WithRequestID(context.Background(), "a-request-id")
+		_, _, err := p.ProduceMessage(ctx, "user-created_v1", &v1.UserCreatedEvent{})
+		require.NoError(t, err)
+		require.NoError(t, p.Close(context.Background()))
+	})
+
+	t.Run("should error when producing on a closed producer", func(t *testing.T) {
+		t.Parallel()
+		p := newAsyncProducer(mocks.NewAsyncProducer(t, newMockSaramaConfig()), AsyncProducerConfig{})
+		require.NoError(t, p.Close(context.Background()))
+
+		_, _, err := p.ProduceMessage(context.Background(), "user-created_v1", &v1.UserCreatedEvent{})
+		assert.ErrorIs(t, err, ErrProducerClosed)
+	})
+
+	t.Run("should return context error when in-flight buffer is full", func(t *testing.T) {
+		t.Parallel()
+		mockProducer := mocks.NewAsyncProducer(t, newMockSaramaConfig())
+		p := newAsyncProducer(mockProducer, AsyncProducerConfig{MaxInFlight: 1})
+		// fill the in-flight buffer without the message ever being acknowledged
+		p.inFlight <- struct{}{}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, _, err := p.ProduceMessage(ctx, "user-created_v1", &v1.UserCreatedEvent{})
+		assert.ErrorIs(t, err, context.Canceled)
+
+		<-p.inFlight
+		require.NoError(t, p.Close(context.Background()))
+	})
+}
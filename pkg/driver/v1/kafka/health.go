@@ -0,0 +1,120 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+const (
+	defaultHealthCheckTimeout = 2 * time.Second
+)
+
+// HealthCheckConfig configures the Kafka health check.
+type HealthCheckConfig struct {
+	Hosts []string
+	// Topics that must exist on the cluster for the check to pass. Optional.
+	Topics []string
+}
+
+// HealthCheck refreshes the cluster metadata and verifies that the controller is reachable.
+// If topics are configured they must also exist. The client is kept between checks rather than
+// dialled on every probe.
+type HealthCheck struct {
+	cfg HealthCheckConfig
+
+	mu     sync.Mutex
+	client sarama.Client
+}
+
+// NewHealthCheck creates a health check, the client is connected by the first check.
+func NewHealthCheck(cfg HealthCheckConfig) *HealthCheck {
+	return &HealthCheck{cfg: cfg}
+}
+
+// Check runs the health check, failing if it doesn't complete before ctx is done.
+func (h *HealthCheck) Check(ctx context.Context) error {
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- h.checkCluster()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("kafka health check timed out: %w", ctx.Err())
+	}
+}
+
+// Close releases the client of the health check.
+func (h *HealthCheck) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.client == nil {
+		return nil
+	}
+	err := h.client.Close()
+	h.client = nil
+	return err
+}
+
+func (h *HealthCheck) checkCluster() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.client == nil {
+		config := sarama.NewConfig()
+		config.Net.DialTimeout = defaultHealthCheckTimeout
+		config.Net.ReadTimeout = defaultHealthCheckTimeout
+		config.Net.WriteTimeout = defaultHealthCheckTimeout
+		config.Metadata.Retry.Max = 0
+		config.Metadata.Full = false
+		// metadata is only refreshed by checks
+		config.Metadata.RefreshFrequency = 0
+		// brokers with auto.create.topics.enable would otherwise create the topics being verified
+		config.Metadata.AllowAutoTopicCreation = false
+
+		client, err := sarama.NewClient(h.cfg.Hosts, config)
+		if err != nil {
+			return fmt.Errorf("unable to connect to kafka: %w", err)
+		}
+		h.client = client
+	}
+	client := h.client
+
+	// unknown topics are reported by name below
+	if err := client.RefreshMetadata(h.cfg.Topics...); err != nil && !errors.Is(err, sarama.ErrUnknownTopicOrPartition) {
+		return fmt.Errorf("unable to refresh kafka metadata: %w", err)
+	}
+	if _, err := client.Controller(); err != nil {
+		return fmt.Errorf("unable to reach kafka controller: %w", err)
+	}
+	if len(h.cfg.Topics) == 0 {
+		return nil
+	}
+
+	topics, err := client.Topics()
+	if err != nil {
+		return fmt.Errorf("unable to list kafka topics: %w", err)
+	}
+	existing := make(map[string]struct{}, len(topics))
+	for _, topic := range topics {
+		existing[topic] = struct{}{}
+	}
+	var missing []string
+	for _, topic := range h.cfg.Topics {
+		if _, ok := existing[topic]; !ok {
+			missing = append(missing, topic)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing kafka topics: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package kafka_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/jacktantram/user-service/pkg/driver/v1/kafka"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newMockBroker(t *testing.T, topics ...string) *sarama.MockBroker {
+	t.Helper()
+	broker := sarama.NewMockBroker(t, 1)
+	t.Cleanup(broker.Close)
+
+	metadata := sarama.NewMockMetadataResponse(t).
+		SetController(broker.BrokerID()).
+		SetBroker(broker.Addr(), broker.BrokerID())
+	for _, topic := range topics {
+		metadata.SetLeader(topic, 0, broker.BrokerID())
+	}
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": metadata,
+	})
+	return broker
+}
+
+func TestNewHealthCheck(t *testing.T) {
+	t.Parallel()
+
+	t.Run("should pass when the controller is reachable", func(t *testing.T) {
+		t.Parallel()
+		broker := newMockBroker(t)
+
+		check := kafka.NewHealthCheck(kafka.HealthCheckConfig{Hosts: []string{broker.Addr()}})
+		assert.NoError(t, check.Check(context.Background()))
+	})
+
+	t.Run("should pass when the configured topics exist", func(t *testing.T) {
+		t.Parallel()
+		broker := newMockBroker(t, "user-created_v1")
+
+		check := kafka.NewHealthCheck(kafka.HealthCheckConfig{
+			Hosts:  []string{broker.Addr()},
+			Topics: []string{"user-created_v1"},
+		})
+		assert.NoError(t, check.Check(context.Background()))
+	})
+
+	t.Run("should fail when a configured topic is missing", func(t *testing.T) {
+		t.Parallel()
+		broker := newMockBroker(t, "user-created_v1")
+
+		check := kafka.NewHealthCheck(kafka.HealthCheckConfig{
+			Hosts:  []string{broker.Addr()},
+			Topics: []string{"user-created_v1", "user-deleted_v1"},
+		})
+		err := check.Check(context.Background())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "user-deleted_v1")
+	})
+
+	t.Run("should not create the topics it verifies", func(t *testing.T) {
+		t.Parallel()
+		broker := newMockBroker(t, "user-created_v1")
+
+		check := kafka.NewHealthCheck(kafka.HealthCheckConfig{
+			Hosts:  []string{broker.Addr()},
+			Topics: []string{"user-created_v1"},
+		})
+		t.Cleanup(func() { _ = check.Close() })
+		require.NoError(t, check.Check(context.Background()))
+		require.NoError(t, check.Check(context.Background()))
+
+		var requests int
+		for _, rr := range broker.History() {
+			if req, ok := rr.Request.(*sarama.MetadataRequest); ok && len(req.Topics) > 0 {
+				requests++
+				assert.False(t, req.AllowAutoTopicCreation)
+			}
+		}
+		assert.Equal(t, 2, requests, "should refresh the metadata on every check")
+	})
+
+	t.Run("should fail when kafka is unreachable", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		check := kafka.NewHealthCheck(kafka.HealthCheckConfig{Hosts: []string{"127.0.0.1:1"}})
+		assert.Error(t, check.Check(ctx))
+	})
+}
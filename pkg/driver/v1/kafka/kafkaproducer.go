@@ -3,45 +3,123 @@ package kafka
 import (
 	"context"
 	"fmt"
+	"time"
+
 	"github.com/Shopify/sarama"
+	"github.com/jacktantram/user-service/pkg/logging"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/protobuf/proto"
 )
 
 // SyncProducer is responsible for writing messages to a particular topic
 type SyncProducer struct {
-	p sarama.SyncProducer
+	p       sarama.SyncProducer
+	metrics *ProducerMetrics
+	tracer  trace.Tracer
 }
 
 type ProducerConfig struct {
+	// Metrics records per topic producer metrics and sarama's internal metrics, optional.
+	Metrics *ProducerMetrics
+	// TracerProvider creates the spans of produced messages, defaults to the global provider.
+	TracerProvider trace.TracerProvider
+	// DisableRetries fails a write on its first error rather than sarama retrying it, leaving retries to the
+	// caller, i.e. a circuit breaker that needs to see each failure.
+	DisableRetries bool
+	// Timeout bounds dialling, reading from and writing to a broker, defaults to sarama's 30s.
+	Timeout time.Duration
 }
 
 // NewSyncProducer creates a new synchronous producer
 func NewSyncProducer(p ProducerConfig, hosts ...string) (SyncProducer, error) {
 	config := sarama.NewConfig()
-	config.Producer.Partitioner = sarama.NewRandomPartitioner
+	config.Producer.Partitioner = sarama.NewHashPartitioner
 	config.Producer.RequiredAcks = sarama.WaitForAll
 	config.Producer.Return.Successes = true
+	if p.Metrics != nil {
+		config.MetricRegistry = p.Metrics.registry
+	}
+	if p.DisableRetries {
+		config.Producer.Retry.Max = 0
+		config.Metadata.Retry.Max = 0
+	}
+	if p.Timeout > 0 {
+		config.Net.DialTimeout = p.Timeout
+		config.Net.ReadTimeout = p.Timeout
+		config.Net.WriteTimeout = p.Timeout
+	}
 
 	producer, err := sarama.NewSyncProducer(hosts, config)
 	if err != nil {
 		return SyncProducer{}, err
 	}
-	return SyncProducer{p: producer}, err
+	return newSyncProducer(producer, p), nil
+}
+
+func newSyncProducer(producer sarama.SyncProducer, p ProducerConfig) SyncProducer {
+	if p.TracerProvider == nil {
+		p.TracerProvider = otel.GetTracerProvider()
+	}
+	return SyncProducer{p: producer, metrics: p.Metrics, tracer: p.TracerProvider.Tracer(instrumentationName)}
 }
 
 // ProduceMessage provides functionality for writing a proto message to a topic
-// Could be improved by adding restrictions on topic name, i.e. $domain.$entity-$action_v$version
+// Topic names are expected to follow $domain.$entity-$action_v$version, see ValidateTopicName.
 func (p SyncProducer) ProduceMessage(ctx context.Context, topic string, msg proto.Message) (partition int32, offset int64, err error) {
-	protoBytes, err := proto.Marshal(msg)
+	return p.ProduceKeyedMessage(ctx, topic, "", msg)
+}
+
+// ProduceKeyedMessage writes a proto message to a topic partitioned by key.
+// A nil message is written as a tombstone, removing the key from compacted topics.
+// The write is traced by a producer span, whose context is injected into the message headers.
+func (p SyncProducer) ProduceKeyedMessage(ctx context.Context, topic string, key string, msg proto.Message) (partition int32, offset int64, err error) {
+	ctx, span := startProducerSpan(ctx, p.tracer, topic, key)
+	defer span.End()
+
+	producerMessage, err := newProducerMessage(ctx, topic, key, msg)
 	if err != nil {
-		return 0, 0, fmt.Errorf("unable to marshal proto bytes: %w", err)
+		endProducerSpan(span, 0, 0, err)
+		return 0, 0, err
 	}
-	partition, offset, err = p.p.SendMessage(&sarama.ProducerMessage{
-		Topic: topic,
-		Value: sarama.ByteEncoder(protoBytes),
-	})
+	start := time.Now()
+	partition, offset, err = p.p.SendMessage(producerMessage)
+	p.metrics.observe(topic, messageSize(producerMessage), start, err)
+	endProducerSpan(span, partition, offset, err)
 	if err != nil {
 		return partition, offset, err
 	}
 	return partition, offset, nil
 }
+
+// newProducerMessage builds a message for a topic, an empty key is left unset so the message is randomly partitioned.
+// The ID of the request producing the message is stamped in the x-request-id header, and its trace context in the
+// traceparent header.
+func newProducerMessage(ctx context.Context, topic string, key string, msg proto.Message) (*sarama.ProducerMessage, error) {
+	producerMessage := &sarama.ProducerMessage{Topic: topic}
+	if key != "" {
+		producerMessage.Key = sarama.StringEncoder(key)
+	}
+	if id := logging.RequestIDFromContext(ctx); id != "" {
+		producerMessage.Headers = append(producerMessage.Headers,
+			sarama.RecordHeader{Key: []byte(logging.RequestIDHeader), Value: []byte(id)})
+	}
+	otel.GetTextMapPropagator().Inject(ctx, headerCarrier{msg: producerMessage})
+	if msg == nil {
+		return producerMessage, nil
+	}
+	protoBytes, err := proto.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal proto bytes: %w", err)
+	}
+	producerMessage.Value = sarama.ByteEncoder(protoBytes)
+	return producerMessage, nil
+}
+
+// messageSize returns the size of a message's value, zero for tombstones.
+func messageSize(msg *sarama.ProducerMessage) int {
+	if msg.Value == nil {
+		return 0
+	}
+	return msg.Value.Length()
+}
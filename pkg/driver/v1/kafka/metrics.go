@@ -0,0 +1,145 @@
+package kafka
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rcrowley/go-metrics"
+)
+
+// summaryQuantiles are the quantiles exported for sarama histograms.
+var summaryQuantiles = []float64{0.5, 0.75, 0.95, 0.99}
+
+// invalidMetricChars matches characters that are not allowed in Prometheus metric names.
+var invalidMetricChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// ProducerMetrics records per topic metrics for produced messages and bridges sarama's
+// internal go-metrics registry into Prometheus. It is a prometheus.Collector.
+type ProducerMetrics struct {
+	produced *prometheus.CounterVec
+	bytes    *prometheus.CounterVec
+	failures *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+
+	// registry is the sarama metric registry, exported with the sarama_ prefix.
+	registry metrics.Registry
+}
+
+// NewProducerMetrics creates producer metrics, register them with prometheus.MustRegister and
+// pass them in the producer config.
+func NewProducerMetrics() *ProducerMetrics {
+	return &ProducerMetrics{
+		produced: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "kafka_producer_messages_total",
+			Help: "Number of messages acknowledged by the broker.",
+		}, []string{"topic"}),
+		bytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "kafka_producer_bytes_total",
+			Help: "Number of message value bytes acknowledged by the broker.",
+		}, []string{"topic"}),
+		failures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "kafka_producer_failures_total",
+			Help: "Number of messages that could not be produced.",
+		}, []string{"topic"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "kafka_producer_latency_seconds",
+			Help:    "Time taken for a message to be acknowledged or to fail.",
+			Buckets: []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5},
+		}, []string{"topic"}),
+		registry: metrics.NewRegistry(),
+	}
+}
+
+// observe records the outcome of producing a message. It is safe to call on nil metrics.
+func (m *ProducerMetrics) observe(topic string, size int, start time.Time, err error) {
+	if m == nil {
+		return
+	}
+	m.latency.WithLabelValues(topic).Observe(time.Since(start).Seconds())
+	if err != nil {
+		m.failures.WithLabelValues(topic).Inc()
+		return
+	}
+	m.produced.WithLabelValues(topic).Inc()
+	m.bytes.WithLabelValues(topic).Add(float64(size))
+}
+
+// Describe implements prometheus.Collector. The sarama metrics are created lazily by
+// sarama so they are left undescribed, making this an unchecked collector for them.
+func (m *ProducerMetrics) Describe(ch chan<- *prometheus.Desc) {
+	m.produced.Describe(ch)
+	m.bytes.Describe(ch)
+	m.failures.Describe(ch)
+	m.latency.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (m *ProducerMetrics) Collect(ch chan<- prometheus.Metric) {
+	m.produced.Collect(ch)
+	m.bytes.Collect(ch)
+	m.failures.Collect(ch)
+	m.latency.Collect(ch)
+	m.collectSarama(ch)
+}
+
+// collectSarama converts the sarama go-metrics into Prometheus metrics. Meters and counters are
+// exported as counters, gauges as gauges and histograms as summaries.
+func (m *ProducerMetrics) collectSarama(ch chan<- prometheus.Metric) {
+	m.registry.Each(func(name string, i interface{}) {
+		name, labels := saramaMetricName(name)
+		help := "sarama metric " + name
+		var (
+			metric prometheus.Metric
+			err    error
+		)
+		switch v := i.(type) {
+		case metrics.Meter:
+			metric, err = prometheus.NewConstMetric(prometheus.NewDesc(name+"_total", help, nil, labels),
+				prometheus.CounterValue, float64(v.Snapshot().Count()))
+		case metrics.Counter:
+			metric, err = prometheus.NewConstMetric(prometheus.NewDesc(name+"_total", help, nil, labels),
+				prometheus.CounterValue, float64(v.Snapshot().Count()))
+		case metrics.Gauge:
+			metric, err = prometheus.NewConstMetric(prometheus.NewDesc(name, help, nil, labels),
+				prometheus.GaugeValue, float64(v.Snapshot().Value()))
+		case metrics.GaugeFloat64:
+			metric, err = prometheus.NewConstMetric(prometheus.NewDesc(name, help, nil, labels),
+				prometheus.GaugeValue, v.Snapshot().Value())
+		case metrics.Histogram:
+			snapshot := v.Snapshot()
+			values := snapshot.Percentiles(summaryQuantiles)
+			quantiles := make(map[float64]float64, len(summaryQuantiles))
+			for i, q := range summaryQuantiles {
+				quantiles[q] = values[i]
+			}
+			metric, err = prometheus.NewConstSummary(prometheus.NewDesc(name, help, nil, labels),
+				uint64(snapshot.Count()), float64(snapshot.Sum()), quantiles)
+		default:
+			return
+		}
+		if err != nil {
+			return
+		}
+		ch <- metric
+	})
+}
+
+// saramaMetricName converts a sarama metric name into a valid Prometheus metric name. Sarama
+// suffixes per topic and per broker metrics, i.e. record-send-rate-for-topic-user-created_v1,
+// which are moved into topic and broker labels.
+func saramaMetricName(name string) (string, prometheus.Labels) {
+	var labels prometheus.Labels
+	for _, suffix := range []struct{ sep, label string }{
+		{"-for-topic-", "topic"},
+		{"-for-broker-", "broker"},
+	} {
+		if i := strings.Index(name, suffix.sep); i >= 0 {
+			labels = prometheus.Labels{suffix.label: name[i+len(suffix.sep):]}
+			name = name[:i]
+			break
+		}
+	}
+	return "sarama_" + strings.Trim(invalidMetricChars.ReplaceAllString(name, "_"), "_"), labels
+}
@@ -0,0 +1,111 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Shopify/sarama/mocks"
+	v1 "github.com/jacktantram/user-service/build/go/events/user/v1"
+	sharedV1 "github.com/jacktantram/user-service/build/go/shared/user/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rcrowley/go-metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProducerMetrics(t *testing.T) {
+	t.Parallel()
+	event := &v1.UserCreatedEvent{User: &sharedV1.User{Id: "a-user-id"}}
+
+	t.Run("should record sync produced messages per topic", func(t *testing.T) {
+		t.Parallel()
+		m := NewProducerMetrics()
+		mockProducer := mocks.NewSyncProducer(t, newMockSaramaConfig())
+		mockProducer.ExpectSendMessageAndSucceed()
+		mockProducer.ExpectSendMessageAndFail(errors.New("broker down"))
+		p := SyncProducer{p: mockProducer, metrics: m}
+
+		_, _, err := p.ProduceMessage(context.Background(), "user-created_v1", event)
+		require.NoError(t, err)
+		_, _, err = p.ProduceMessage(context.Background(), "user-created_v1", event)
+		require.Error(t, err)
+
+		assert.Equal(t, float64(1), testutil.ToFloat64(m.produced.WithLabelValues("user-created_v1")))
+		assert.Equal(t, float64(1), testutil.ToFloat64(m.failures.WithLabelValues("user-created_v1")))
+		assert.Greater(t, testutil.ToFloat64(m.bytes.WithLabelValues("user-created_v1")), float64(0))
+		assert.Equal(t, 1, testutil.CollectAndCount(m.latency))
+	})
+
+	t.Run("should record async delivery reports per topic", func(t *testing.T) {
+		t.Parallel()
+		m := NewProducerMetrics()
+		mockProducer := mocks.NewAsyncProducer(t, newMockSaramaConfig())
+		mockProducer.ExpectInputAndSucceed()
+		mockProducer.ExpectInputAndFail(errors.New("broker down"))
+		p := newAsyncProducer(mockProducer, AsyncProducerConfig{Metrics: m})
+
+		_, _, err := p.ProduceMessage(context.Background(), "user-created_v1", event)
+		require.NoError(t, err)
+		_, _, err = p.ProduceKeyedMessage(context.Background(), "users-state_v1", "a-user-id", nil)
+		require.NoError(t, err)
+		require.NoError(t, p.Close(context.Background()))
+
+		assert.Equal(t, float64(1), testutil.ToFloat64(m.produced.WithLabelValues("user-created_v1")))
+		assert.Equal(t, float64(1), testutil.ToFloat64(m.failures.WithLabelValues("users-state_v1")))
+		assert.Equal(t, 2, testutil.CollectAndCount(m.latency))
+	})
+
+	t.Run("should bridge sarama metrics", func(t *testing.T) {
+		t.Parallel()
+		m := NewProducerMetrics()
+		metrics.GetOrRegisterMeter("record-send-rate-for-topic-user-created_v1", m.registry).Mark(3)
+		metrics.GetOrRegisterHistogram("request-latency-in-ms", m.registry, metrics.NewUniformSample(10)).Update(5)
+
+		registry := prometheus.NewRegistry()
+		require.NoError(t, registry.Register(m))
+		families, err := registry.Gather()
+		require.NoError(t, err)
+
+		byName := map[string]float64{}
+		for _, family := range families {
+			for _, metric := range family.GetMetric() {
+				switch {
+				case metric.GetCounter() != nil:
+					byName[family.GetName()] = metric.GetCounter().GetValue()
+					for _, label := range metric.GetLabel() {
+						assert.Equal(t, "topic", label.GetName())
+						assert.Equal(t, "user-created_v1", label.GetValue())
+					}
+				case metric.GetSummary() != nil:
+					byName[family.GetName()] = metric.GetSummary().GetSampleSum()
+				}
+			}
+		}
+		assert.Equal(t, float64(3), byName["sarama_record_send_rate_total"])
+		assert.Equal(t, float64(5), byName["sarama_request_latency_in_ms"])
+	})
+}
+
+func TestSaramaMetricName(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		metric     string
+		wantName   string
+		wantLabels prometheus.Labels
+	}{
+		{"record-send-rate", "sarama_record_send_rate", nil},
+		{"batch-size-for-topic-user-created_v1", "sarama_batch_size", prometheus.Labels{"topic": "user-created_v1"}},
+		{"request-latency-in-ms-for-broker-1", "sarama_request_latency_in_ms", prometheus.Labels{"broker": "1"}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.metric, func(t *testing.T) {
+			t.Parallel()
+			name, labels := saramaMetricName(tt.metric)
+			assert.Equal(t, tt.wantName, name)
+			assert.Equal(t, tt.wantLabels, labels)
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package kafka_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	v1 "github.com/jacktantram/user-service/build/go/events/user/v1"
+	"github.com/jacktantram/user-service/pkg/driver/v1/kafka"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSyncProducer_DisableRetries(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name         string
+		cfg          kafka.ProducerConfig
+		wantRequests int
+	}{
+		{
+			name:         "should retry failed writes by default",
+			wantRequests: 4,
+		},
+		{
+			name:         "should fail on the first error with retries disabled",
+			cfg:          kafka.ProducerConfig{DisableRetries: true},
+			wantRequests: 1,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			broker := newMockBroker(t)
+			broker.SetHandlerByMap(map[string]sarama.MockResponse{
+				"MetadataRequest": sarama.NewMockMetadataResponse(t).
+					SetController(broker.BrokerID()).
+					SetBroker(broker.Addr(), broker.BrokerID()).
+					SetLeader("user-created_v1", 0, broker.BrokerID()),
+				"ProduceRequest": sarama.NewMockProduceResponse(t).SetVersion(3).
+					SetError("user-created_v1", 0, sarama.ErrNotEnoughReplicas),
+			})
+
+			producer, err := kafka.NewSyncProducer(tt.cfg, broker.Addr())
+			require.NoError(t, err)
+			_, _, err = producer.ProduceMessage(context.Background(), "user-created_v1", &v1.UserCreatedEvent{})
+			require.ErrorIs(t, err, sarama.ErrNotEnoughReplicas)
+
+			var requests int
+			for _, rr := range broker.History() {
+				if _, ok := rr.Request.(*sarama.ProduceRequest); ok {
+					requests++
+				}
+			}
+			assert.Equal(t, tt.wantRequests, requests)
+		})
+	}
+}
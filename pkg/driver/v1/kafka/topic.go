@@ -0,0 +1,85 @@
+package kafka
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+// topicNameRegex enforces the $domain.$entity-$action_v$version naming convention.
+// Any number of dot separated domain (or environment) prefixes are permitted, including none.
+var topicNameRegex = regexp.MustCompile(`^([a-z0-9]+(-[a-z0-9]+)*\.)*[a-z0-9]+-[a-z0-9]+_v[1-9][0-9]*$`)
+
+// ValidateTopicName validates that a topic name follows the $domain.$entity-$action_v$version convention.
+func ValidateTopicName(name string) error {
+	if !topicNameRegex.MatchString(name) {
+		return fmt.Errorf("topic name %q must follow the $domain.$entity-$action_v$version convention", name)
+	}
+	return nil
+}
+
+// TopicConfig describes a topic to be provisioned.
+type TopicConfig struct {
+	Name              string
+	Partitions        int32
+	ReplicationFactor int16
+	// Retention is how long messages are kept for. Zero uses the broker default.
+	Retention time.Duration
+	// Compact enables log compaction, retaining the latest message per key instead of deleting by age.
+	Compact bool
+}
+
+func (t TopicConfig) detail() *sarama.TopicDetail {
+	detail := &sarama.TopicDetail{
+		NumPartitions:     t.Partitions,
+		ReplicationFactor: t.ReplicationFactor,
+		ConfigEntries:     map[string]*string{},
+	}
+	if t.Compact {
+		cleanupPolicy := "compact"
+		detail.ConfigEntries["cleanup.policy"] = &cleanupPolicy
+		return detail
+	}
+	if t.Retention > 0 {
+		retention := strconv.FormatInt(t.Retention.Milliseconds(), 10)
+		detail.ConfigEntries["retention.ms"] = &retention
+	}
+	return detail
+}
+
+// EnsureTopics creates any of the given topics that do not already exist.
+// Existing topics are left untouched.
+func EnsureTopics(hosts []string, topics ...TopicConfig) error {
+	for _, topic := range topics {
+		if err := ValidateTopicName(topic.Name); err != nil {
+			return err
+		}
+	}
+
+	admin, err := sarama.NewClusterAdmin(hosts, sarama.NewConfig())
+	if err != nil {
+		return fmt.Errorf("unable to create kafka cluster admin: %w", err)
+	}
+	defer admin.Close()
+
+	existing, err := admin.ListTopics()
+	if err != nil {
+		return fmt.Errorf("unable to list kafka topics: %w", err)
+	}
+	for _, topic := range topics {
+		if _, ok := existing[topic.Name]; ok {
+			continue
+		}
+		if err = admin.CreateTopic(topic.Name, topic.detail(), false); err != nil {
+			if errors.Is(err, sarama.ErrTopicAlreadyExists) {
+				continue
+			}
+			return fmt.Errorf("unable to create kafka topic %s: %w", topic.Name, err)
+		}
+	}
+	return nil
+}
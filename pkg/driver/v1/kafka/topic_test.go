@@ -0,0 +1,90 @@
+package kafka_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/jacktantram/user-service/pkg/driver/v1/kafka"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateTopicName(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		topic   string
+		wantErr bool
+	}{
+		{"entity and action", "user-created_v1", false},
+		{"domain prefix", "users.user-created_v1", false},
+		{"environment and domain prefix", "staging.users.user-created_v12", false},
+		{"hyphenated prefix", "eu-west.user-created_v1", false},
+		{"missing version", "user-created", true},
+		{"zero version", "user-created_v0", true},
+		{"missing action", "user_v1", true},
+		{"upper case", "User-Created_v1", true},
+		{"trailing dot prefix", ".user-created_v1", true},
+		{"empty", "", true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			err := kafka.ValidateTopicName(tt.topic)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestEnsureTopics(t *testing.T) {
+	t.Parallel()
+
+	t.Run("should create missing topics", func(t *testing.T) {
+		t.Parallel()
+		broker := sarama.NewMockBroker(t, 1)
+		defer broker.Close()
+
+		broker.SetHandlerByMap(map[string]sarama.MockResponse{
+			"MetadataRequest": sarama.NewMockMetadataResponse(t).
+				SetController(broker.BrokerID()).
+				SetBroker(broker.Addr(), broker.BrokerID()).
+				SetLeader("user-created_v1", 0, broker.BrokerID()),
+			"DescribeConfigsRequest": sarama.NewMockDescribeConfigsResponse(t),
+			"CreateTopicsRequest":    sarama.NewMockCreateTopicsResponse(t),
+		})
+
+		require.NoError(t, kafka.EnsureTopics([]string{broker.Addr()},
+			kafka.TopicConfig{Name: "user-created_v1", Partitions: 3, ReplicationFactor: 1},
+			kafka.TopicConfig{Name: "user-deleted_v1", Partitions: 3, ReplicationFactor: 1, Retention: time.Hour},
+		))
+
+		var created []*sarama.CreateTopicsRequest
+		for _, rr := range broker.History() {
+			if req, ok := rr.Request.(*sarama.CreateTopicsRequest); ok {
+				created = append(created, req)
+			}
+		}
+		require.Len(t, created, 1)
+		require.Len(t, created[0].TopicDetails, 1)
+		detail, ok := created[0].TopicDetails["user-deleted_v1"]
+		require.True(t, ok)
+		assert.Equal(t, int32(3), detail.NumPartitions)
+		assert.Equal(t, "3600000", *detail.ConfigEntries["retention.ms"])
+	})
+
+	t.Run("should reject invalid topic names", func(t *testing.T) {
+		t.Parallel()
+		err := kafka.EnsureTopics([]string{"127.0.0.1:1"}, kafka.TopicConfig{
+			Name:       "not a topic",
+			Partitions: 1, ReplicationFactor: 1, Retention: time.Hour,
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "convention")
+	})
+}
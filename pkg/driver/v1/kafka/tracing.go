@@ -0,0 +1,75 @@
+package kafka
+
+import (
+	"context"
+
+	"github.com/Shopify/sarama"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies the spans created by the package.
+const instrumentationName = "github.com/jacktantram/user-service/pkg/driver/v1/kafka"
+
+// startProducerSpan starts a span for a message produced to topic, named "$topic publish".
+// A nil tracer falls back to the global provider.
+func startProducerSpan(ctx context.Context, tracer trace.Tracer, topic string, key string) (context.Context, trace.Span) {
+	if tracer == nil {
+		tracer = otel.Tracer(instrumentationName)
+	}
+	attrs := []attribute.KeyValue{
+		semconv.MessagingSystem("kafka"),
+		semconv.MessagingDestinationName(topic),
+		semconv.MessagingOperationPublish,
+	}
+	if key != "" {
+		attrs = append(attrs, semconv.MessagingKafkaMessageKey(key))
+	}
+	return tracer.Start(ctx, topic+" publish", trace.WithSpanKind(trace.SpanKindProducer), trace.WithAttributes(attrs...))
+}
+
+// endProducerSpan records where the message was written, or the error writing it.
+func endProducerSpan(span trace.Span, partition int32, offset int64, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return
+	}
+	span.SetAttributes(semconv.MessagingKafkaDestinationPartition(int(partition)),
+		attribute.Int64("messaging.kafka.message.offset", offset))
+}
+
+// headerCarrier injects trace context into the headers of a message.
+type headerCarrier struct {
+	msg *sarama.ProducerMessage
+}
+
+func (c headerCarrier) Get(key string) string {
+	for _, header := range c.msg.Headers {
+		if string(header.Key) == key {
+			return string(header.Value)
+		}
+	}
+	return ""
+}
+
+func (c headerCarrier) Set(key string, value string) {
+	for i, header := range c.msg.Headers {
+		if string(header.Key) == key {
+			c.msg.Headers[i].Value = []byte(value)
+			return
+		}
+	}
+	c.msg.Headers = append(c.msg.Headers, sarama.RecordHeader{Key: []byte(key), Value: []byte(value)})
+}
+
+func (c headerCarrier) Keys() []string {
+	keys := make([]string, 0, len(c.msg.Headers))
+	for _, header := range c.msg.Headers {
+		keys = append(keys, string(header.Key))
+	}
+	return keys
+}
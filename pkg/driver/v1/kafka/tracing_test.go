@@ -0,0 +1,72 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/Shopify/sarama/mocks"
+	v1 "github.com/jacktantram/user-service/build/go/events/user/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestSyncProducer_Tracing(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	t.Run("should produce within a producer span injected into the headers", func(t *testing.T) {
+		recorder := tracetest.NewSpanRecorder()
+		provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+		ctx, parent := provider.Tracer("test").Start(context.Background(), "DeleteUser")
+
+		var traceParent string
+		mockProducer := mocks.NewSyncProducer(t, newMockSaramaConfig())
+		mockProducer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+			traceParent = headerCarrier{msg: msg}.Get("traceparent")
+			return nil
+		})
+		p := newSyncProducer(mockProducer, ProducerConfig{TracerProvider: provider})
+		_, _, err := p.ProduceKeyedMessage(ctx, "user-deleted_v1", "a-user-id", &v1.UserDeletedEvent{})
+		require.NoError(t, err)
+		parent.End()
+
+		spans := recorder.Ended()
+		require.Len(t, spans, 2)
+		span := spans[0]
+		assert.Equal(t, "user-deleted_v1 publish", span.Name())
+		assert.Equal(t, trace.SpanKindProducer, span.SpanKind())
+		assert.Equal(t, parent.SpanContext().SpanID(), span.Parent().SpanID())
+		assert.Contains(t, span.Attributes(), attribute.String("messaging.destination.name", "user-deleted_v1"))
+		assert.Contains(t, span.Attributes(), attribute.String("messaging.kafka.message.key", "a-user-id"))
+
+		// the consumer continues the trace from the producer span
+		remote := trace.SpanContextFromContext(otel.GetTextMapPropagator().Extract(context.Background(),
+			propagation.MapCarrier{"traceparent": traceParent}))
+		assert.Equal(t, span.SpanContext().TraceID(), remote.TraceID())
+		assert.Equal(t, span.SpanContext().SpanID(), remote.SpanID())
+	})
+
+	t.Run("should record the error producing a message", func(t *testing.T) {
+		recorder := tracetest.NewSpanRecorder()
+		mockProducer := mocks.NewSyncProducer(t, newMockSaramaConfig())
+		mockProducer.ExpectSendMessageAndFail(errors.New("broker down"))
+		p := newSyncProducer(mockProducer, ProducerConfig{
+			TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))})
+
+		_, _, err := p.ProduceMessage(context.Background(), "user-deleted_v1", &v1.UserDeletedEvent{})
+		require.Error(t, err)
+
+		spans := recorder.Ended()
+		require.Len(t, spans, 1)
+		assert.Equal(t, codes.Error, spans[0].Status().Code)
+		assert.Equal(t, "broker down", spans[0].Status().Description)
+	})
+}
@@ -0,0 +1,127 @@
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Message is a message produced to the bus.
+type Message struct {
+	Topic string
+	Key   string
+	// Value is nil for tombstones.
+	Value proto.Message
+}
+
+// defaultRetention is the number of messages a bus retains unless configured otherwise.
+const defaultRetention = 10000
+
+// Bus is an in-memory event bus. The most recent messages are retained and messages are delivered
+// synchronously to subscribers, making it suitable for local development and tests.
+type Bus struct {
+	mu          sync.RWMutex
+	messages    []Message
+	retention   int
+	produced    int64
+	subscribers map[string]map[int]func(msg Message)
+	nextID      int
+}
+
+// Option configures a Bus.
+type Option func(b *Bus)
+
+// WithRetention sets the number of most recent messages retained for Messages, defaults to 10,000.
+// Zero retains none, for long running buses that only deliver to subscribers.
+func WithRetention(n int) Option {
+	return func(b *Bus) {
+		b.retention = n
+	}
+}
+
+// NewBus creates a new in-memory bus
+func NewBus(opts ...Option) *Bus {
+	b := &Bus{retention: defaultRetention, subscribers: map[string]map[int]func(msg Message){}}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// ProduceMessage writes a proto message to a topic.
+// The offset returned is the position of the message within the bus.
+func (b *Bus) ProduceMessage(ctx context.Context, topic string, msg proto.Message) (partition int32, offset int64, err error) {
+	return b.ProduceKeyedMessage(ctx, topic, "", msg)
+}
+
+// ProduceKeyedMessage writes a proto message to a topic with a key. A nil message is a tombstone.
+func (b *Bus) ProduceKeyedMessage(ctx context.Context, topic string, key string, msg proto.Message) (partition int32, offset int64, err error) {
+	if err = ctx.Err(); err != nil {
+		return 0, 0, err
+	}
+	message := Message{Topic: topic, Key: key}
+	if msg != nil {
+		message.Value = proto.Clone(msg)
+	}
+
+	b.mu.Lock()
+	offset = b.produced
+	b.produced++
+	if b.retention > 0 {
+		b.messages = append(b.messages, message)
+		if len(b.messages) > b.retention {
+			b.messages = b.messages[len(b.messages)-b.retention:]
+		}
+	}
+	handlers := make([]func(msg Message), 0, len(b.subscribers[topic]))
+	for _, handler := range b.subscribers[topic] {
+		handlers = append(handlers, handler)
+	}
+	b.mu.Unlock()
+
+	for _, handler := range handlers {
+		handler(message)
+	}
+	return 0, offset, nil
+}
+
+// Subscribe registers a handler for messages produced to a topic from now on.
+// The returned function removes the subscription.
+func (b *Bus) Subscribe(topic string, handler func(msg Message)) (unsubscribe func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	id := b.nextID
+	b.nextID++
+	if b.subscribers[topic] == nil {
+		b.subscribers[topic] = map[int]func(msg Message){}
+	}
+	b.subscribers[topic][id] = handler
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subscribers[topic], id)
+	}
+}
+
+// Messages returns the retained messages produced to a topic in the order they were produced.
+func (b *Bus) Messages(topic string) []Message {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	messages := make([]Message, 0)
+	for _, msg := range b.messages {
+		if msg.Topic == topic {
+			messages = append(messages, msg)
+		}
+	}
+	return messages
+}
+
+// Reset removes every retained message, subscriptions are kept.
+func (b *Bus) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.messages = nil
+	b.produced = 0
+}
@@ -0,0 +1,100 @@
+package memory_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	v1 "github.com/jacktantram/user-service/build/go/events/user/v1"
+	sharedV1 "github.com/jacktantram/user-service/build/go/shared/user/v1"
+	"github.com/jacktantram/user-service/pkg/driver/v1/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestBus_ProduceMessage(t *testing.T) {
+	t.Parallel()
+
+	t.Run("should retain messages per topic", func(t *testing.T) {
+		t.Parallel()
+		bus := memory.NewBus()
+		event := &v1.UserCreatedEvent{User: &sharedV1.User{Id: "a-user-id"}}
+
+		_, offset, err := bus.ProduceMessage(context.Background(), "user-created_v1", event)
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), offset)
+		_, offset, err = bus.ProduceKeyedMessage(context.Background(), "users-state_v1", "a-user-id", nil)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), offset)
+
+		created := bus.Messages("user-created_v1")
+		require.Len(t, created, 1)
+		assert.True(t, proto.Equal(event, created[0].Value))
+
+		state := bus.Messages("users-state_v1")
+		require.Len(t, state, 1)
+		assert.Equal(t, memory.Message{Topic: "users-state_v1", Key: "a-user-id"}, state[0])
+
+		bus.Reset()
+		assert.Empty(t, bus.Messages("user-created_v1"))
+	})
+
+	t.Run("should only retain the most recent messages", func(t *testing.T) {
+		t.Parallel()
+		bus := memory.NewBus(memory.WithRetention(2))
+		for i := 0; i < 3; i++ {
+			_, offset, err := bus.ProduceKeyedMessage(context.Background(), "users-state_v1", fmt.Sprint(i), nil)
+			require.NoError(t, err)
+			assert.Equal(t, int64(i), offset)
+		}
+
+		state := bus.Messages("users-state_v1")
+		require.Len(t, state, 2)
+		assert.Equal(t, "1", state[0].Key)
+		assert.Equal(t, "2", state[1].Key)
+	})
+
+	t.Run("should deliver messages without retaining them", func(t *testing.T) {
+		t.Parallel()
+		bus := memory.NewBus(memory.WithRetention(0))
+		var received []memory.Message
+		bus.Subscribe("user-created_v1", func(msg memory.Message) {
+			received = append(received, msg)
+		})
+
+		_, _, err := bus.ProduceMessage(context.Background(), "user-created_v1", &v1.UserCreatedEvent{})
+		require.NoError(t, err)
+		assert.Len(t, received, 1)
+		assert.Empty(t, bus.Messages("user-created_v1"))
+	})
+
+	t.Run("should deliver messages to subscribers until unsubscribed", func(t *testing.T) {
+		t.Parallel()
+		bus := memory.NewBus()
+
+		var received []memory.Message
+		unsubscribe := bus.Subscribe("user-created_v1", func(msg memory.Message) {
+			received = append(received, msg)
+		})
+
+		_, _, err := bus.ProduceMessage(context.Background(), "user-created_v1", &v1.UserCreatedEvent{})
+		require.NoError(t, err)
+		_, _, err = bus.ProduceMessage(context.Background(), "user-deleted_v1", &v1.UserDeletedEvent{})
+		require.NoError(t, err)
+		unsubscribe()
+		_, _, err = bus.ProduceMessage(context.Background(), "user-created_v1", &v1.UserCreatedEvent{})
+		require.NoError(t, err)
+
+		require.Len(t, received, 1)
+		assert.Equal(t, "user-created_v1", received[0].Topic)
+	})
+
+	t.Run("should not produce on a cancelled context", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, _, err := memory.NewBus().ProduceMessage(ctx, "user-created_v1", &v1.UserCreatedEvent{})
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
@@ -0,0 +1,92 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	natsgo "github.com/nats-io/nats.go"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// HeaderKey is the header holding the message key.
+	HeaderKey = "Key"
+	// HeaderType is the header holding the full proto name of the message, unset for tombstones.
+	HeaderType = "Type"
+)
+
+// Producer publishes messages to JetStream, using the topic as the subject.
+type Producer struct {
+	conn *natsgo.Conn
+	js   natsgo.JetStreamContext
+}
+
+// NewProducer connects to the NATS server at url and creates a JetStream producer.
+func NewProducer(url string) (*Producer, error) {
+	conn, err := natsgo.Connect(url)
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to nats: %w", err)
+	}
+	js, err := conn.JetStream()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("unable to create jetstream context: %w", err)
+	}
+	return &Producer{conn: conn, js: js}, nil
+}
+
+// EnsureStream creates the stream capturing the given subjects if it does not already exist.
+func (p *Producer) EnsureStream(name string, subjects ...string) error {
+	_, err := p.js.StreamInfo(name)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, natsgo.ErrStreamNotFound) {
+		return fmt.Errorf("unable to get stream %s: %w", name, err)
+	}
+	if _, err = p.js.AddStream(&natsgo.StreamConfig{Name: name, Subjects: subjects}); err != nil {
+		return fmt.Errorf("unable to create stream %s: %w", name, err)
+	}
+	return nil
+}
+
+// ProduceMessage publishes a proto message to a topic.
+// The offset returned is the stream sequence of the message.
+func (p *Producer) ProduceMessage(ctx context.Context, topic string, msg proto.Message) (partition int32, offset int64, err error) {
+	return p.ProduceKeyedMessage(ctx, topic, "", msg)
+}
+
+// ProduceKeyedMessage publishes a proto message to a topic with the key set as a header.
+// A nil message is published with empty data as a tombstone.
+func (p *Producer) ProduceKeyedMessage(ctx context.Context, topic string, key string, msg proto.Message) (partition int32, offset int64, err error) {
+	natsMsg := natsgo.NewMsg(topic)
+	if key != "" {
+		natsMsg.Header.Set(HeaderKey, key)
+	}
+	if msg != nil {
+		natsMsg.Data, err = proto.Marshal(msg)
+		if err != nil {
+			return 0, 0, fmt.Errorf("unable to marshal proto bytes: %w", err)
+		}
+		natsMsg.Header.Set(HeaderType, string(msg.ProtoReflect().Descriptor().FullName()))
+	}
+	ack, err := p.js.PublishMsg(natsMsg, natsgo.Context(ctx))
+	if err != nil {
+		return 0, 0, fmt.Errorf("unable to publish message: %w", err)
+	}
+	return 0, int64(ack.Sequence), nil
+}
+
+// HealthCheck verifies the connection to the NATS server is established.
+func (p *Producer) HealthCheck(ctx context.Context) error {
+	if !p.conn.IsConnected() {
+		return fmt.Errorf("nats connection is %s", p.conn.Status())
+	}
+	return p.conn.FlushWithContext(ctx)
+}
+
+// Close drains any pending messages and closes the connection.
+func (p *Producer) Close() error {
+	return p.conn.Drain()
+}
@@ -0,0 +1,45 @@
+//go:build integration
+// +build integration
+
+package nats_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	v1 "github.com/jacktantram/user-service/build/go/events/user/v1"
+	"github.com/jacktantram/user-service/pkg/driver/v1/nats"
+	natsgo "github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestProducer_ProduceKeyedMessage(t *testing.T) {
+	url := os.Getenv("NATS_URL")
+	if url == "" {
+		url = natsgo.DefaultURL
+	}
+	p, err := nats.NewProducer(url)
+	require.NoError(t, err)
+	defer p.Close()
+	require.NoError(t, p.EnsureStream("USERS_TEST", "test.user-created_v1"))
+
+	_, offset, err := p.ProduceKeyedMessage(context.Background(), "test.user-created_v1", "a-user-id", &v1.UserCreatedEvent{})
+	require.NoError(t, err)
+	assert.Greater(t, offset, int64(0))
+
+	conn, err := natsgo.Connect(url)
+	require.NoError(t, err)
+	defer conn.Close()
+	js, err := conn.JetStream()
+	require.NoError(t, err)
+	msg, err := js.GetMsg("USERS_TEST", uint64(offset))
+	require.NoError(t, err)
+	assert.Equal(t, "a-user-id", msg.Header.Get(nats.HeaderKey))
+	assert.Equal(t, "events.user.v1.UserCreatedEvent", msg.Header.Get(nats.HeaderType))
+
+	var event v1.UserCreatedEvent
+	require.NoError(t, proto.Unmarshal(msg.Data, &event))
+}
@@ -0,0 +1,49 @@
+// Package logging carries a request-scoped logger and the ID of the request it was created for in a context,
+// so log lines and events from every layer handling a request can be correlated.
+package logging
+
+import (
+	"context"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// RequestIDHeader is the gRPC metadata and Kafka header key of the request ID.
+	RequestIDHeader = "x-request-id"
+	// RequestIDField is the log field of the request ID.
+	RequestIDField = "request_id"
+	// TraceIDField is the log field of the trace ID, correlating log lines with the spans of a call.
+	TraceIDField = "trace_id"
+)
+
+type (
+	loggerKey    struct{}
+	requestIDKey struct{}
+)
+
+// WithRequestID returns a context carrying the request ID and a logger with the request_id field.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	ctx = context.WithValue(ctx, requestIDKey{}, id)
+	return WithLogger(ctx, FromContext(ctx).WithField(RequestIDField, id))
+}
+
+// RequestIDFromContext returns the ID of the request, empty if there is none.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// WithLogger returns a context carrying the logger.
+func WithLogger(ctx context.Context, logger *log.Entry) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext returns the logger of the request, or the standard logger if there is none. Either is bound to
+// ctx so hooks can read it.
+func FromContext(ctx context.Context) *log.Entry {
+	if logger, ok := ctx.Value(loggerKey{}).(*log.Entry); ok && logger != nil {
+		return logger.WithContext(ctx)
+	}
+	return log.WithContext(ctx)
+}
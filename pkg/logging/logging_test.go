@@ -0,0 +1,32 @@
+package logging_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jacktantram/user-service/pkg/logging"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromContext(t *testing.T) {
+	t.Parallel()
+
+	t.Run("should return the standard logger without a request", func(t *testing.T) {
+		t.Parallel()
+		logger := logging.FromContext(context.Background())
+		assert.Empty(t, logger.Data)
+		assert.Empty(t, logging.RequestIDFromContext(context.Background()))
+	})
+
+	t.Run("should return the logger of the request with its ID", func(t *testing.T) {
+		t.Parallel()
+		ctx := logging.WithLogger(context.Background(), log.WithField("method", "GetUser"))
+		ctx = logging.WithRequestID(ctx, "a-request-id")
+
+		logger := logging.FromContext(ctx)
+		assert.Equal(t, log.Fields{"method": "GetUser", logging.RequestIDField: "a-request-id"}, logger.Data)
+		assert.Equal(t, ctx, logger.Context)
+		assert.Equal(t, "a-request-id", logging.RequestIDFromContext(ctx))
+	})
+}
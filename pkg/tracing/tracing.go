@@ -0,0 +1,103 @@
+// Package tracing configures OpenTelemetry tracing, exporting spans over OTLP or to stdout.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+)
+
+// Exporters spans can be sent to.
+const (
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+)
+
+// Config configures where spans are exported.
+type Config struct {
+	// ServiceName identifies the service in its spans.
+	ServiceName string
+	// Exporter is one of ExporterOTLP, ExporterStdout or ExporterNone, which doesn't export spans or sample
+	// the traces started by the service.
+	Exporter string
+	// Endpoint is the host:port of the OTLP gRPC collector.
+	Endpoint string
+	// Insecure sends spans to the collector without TLS.
+	Insecure bool
+	// SampleRatio is the fraction of traces started by the service that are recorded, between 0 and 1.
+	// Traces started by callers follow their sampling decision.
+	SampleRatio float64
+	// Writer receives the spans of the stdout exporter, defaults to os.Stdout.
+	Writer io.Writer
+}
+
+// NewTracerProvider creates a tracer provider exporting spans as configured. It is registered as the global
+// provider, along with the W3C trace context and baggage propagators, so instrumentation picks it up.
+// The provider should be shut down on exit to flush the remaining spans.
+func NewTracerProvider(ctx context.Context, cfg Config) (*sdktrace.TracerProvider, error) {
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(cfg.ServiceName)))
+	if err != nil {
+		return nil, fmt.Errorf("unable to create resource: %w", err)
+	}
+	opts := []sdktrace.TracerProviderOption{sdktrace.WithResource(res)}
+
+	switch cfg.Exporter {
+	case ExporterNone, "":
+		// nothing is exported, but the sampling decision of callers is kept so the trace context passed on
+		// to the services called still carries it
+		opts = append(opts, sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.NeverSample())))
+	case ExporterOTLP:
+		clientOpts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(cfg.Endpoint)}
+		if cfg.Insecure {
+			clientOpts = append(clientOpts, otlptracegrpc.WithInsecure())
+		}
+		exporter, err := otlptracegrpc.New(ctx, clientOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("unable to create otlp exporter: %w", err)
+		}
+		opts = append(opts, sdktrace.WithBatcher(exporter), sampler(cfg))
+	case ExporterStdout:
+		w := cfg.Writer
+		if w == nil {
+			w = os.Stdout
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(w))
+		if err != nil {
+			return nil, fmt.Errorf("unable to create stdout exporter: %w", err)
+		}
+		// spans are written as they end so they are seen straight away locally
+		opts = append(opts, sdktrace.WithSyncer(exporter), sampler(cfg))
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q, expected %s, %s or %s", cfg.Exporter,
+			ExporterOTLP, ExporterStdout, ExporterNone)
+	}
+
+	provider := sdktrace.NewTracerProvider(opts...)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{},
+		propagation.Baggage{}))
+	return provider, nil
+}
+
+func sampler(cfg Config) sdktrace.TracerProviderOption {
+	return sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio)))
+}
+
+// Shutdown flushes the spans of provider, waiting up to timeout.
+func Shutdown(provider *sdktrace.TracerProvider, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return provider.Shutdown(ctx)
+}
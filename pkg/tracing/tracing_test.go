@@ -0,0 +1,64 @@
+package tracing_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jacktantram/user-service/pkg/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestNewTracerProvider(t *testing.T) {
+	t.Run("should write spans to stdout", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		provider, err := tracing.NewTracerProvider(context.Background(), tracing.Config{ServiceName: "user-service",
+			Exporter: tracing.ExporterStdout, SampleRatio: 1, Writer: buf})
+		require.NoError(t, err)
+
+		_, span := otel.Tracer("test").Start(context.Background(), "DeleteUser")
+		span.End()
+		require.NoError(t, tracing.Shutdown(provider, time.Second))
+		assert.Contains(t, buf.String(), `"Name":"DeleteUser"`)
+		assert.Contains(t, buf.String(), `"Value":"user-service"`)
+	})
+
+	t.Run("should not record spans without an exporter", func(t *testing.T) {
+		provider, err := tracing.NewTracerProvider(context.Background(), tracing.Config{Exporter: tracing.ExporterNone})
+		require.NoError(t, err)
+
+		_, span := otel.Tracer("test").Start(context.Background(), "DeleteUser")
+		assert.False(t, span.IsRecording())
+		span.End()
+		require.NoError(t, tracing.Shutdown(provider, time.Second))
+	})
+
+	t.Run("should keep the sampling decision of callers without an exporter", func(t *testing.T) {
+		provider, err := tracing.NewTracerProvider(context.Background(), tracing.Config{Exporter: tracing.ExporterNone})
+		require.NoError(t, err)
+
+		parent := trace.NewSpanContext(trace.SpanContextConfig{TraceID: trace.TraceID{1}, SpanID: trace.SpanID{1},
+			TraceFlags: trace.FlagsSampled, Remote: true})
+		ctx, span := otel.Tracer("test").Start(trace.ContextWithRemoteSpanContext(context.Background(), parent),
+			"DeleteUser")
+		carrier := propagation.MapCarrier{}
+		otel.GetTextMapPropagator().Inject(ctx, carrier)
+		span.End()
+		require.NoError(t, tracing.Shutdown(provider, time.Second))
+
+		assert.True(t, span.SpanContext().IsSampled())
+		assert.Equal(t, parent.TraceID(), span.SpanContext().TraceID())
+		assert.True(t, strings.HasSuffix(carrier.Get("traceparent"), "-01"), carrier.Get("traceparent"))
+	})
+
+	t.Run("should error for an unknown exporter", func(t *testing.T) {
+		_, err := tracing.NewTracerProvider(context.Background(), tracing.Config{Exporter: "jaeger"})
+		assert.Error(t, err)
+	})
+}